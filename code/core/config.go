@@ -1,6 +1,9 @@
 package core
 
-import "gorm.io/gorm/schema"
+import (
+	"box/code/tool/storage"
+	"gorm.io/gorm/schema"
+)
 
 const (
 	//authentication key of cookie
@@ -28,6 +31,10 @@ type Config interface {
 	SqliteFolder() string
 	//files storage location.
 	MatterPath() string
+	//storage driver of files. local or s3
+	StorageDriver() string
+	//configs of s3 storage driver.
+	S3Config() storage.S3Config
 	//table name strategy
 	NamingStrategy() schema.NamingStrategy
 	//when installed by user. Write configs to tank.json
@@ -2,6 +2,7 @@ package core
 
 import (
 	"box/code/tool/cache"
+	"box/code/tool/storage"
 	"gorm.io/gorm"
 	"net/http"
 )
@@ -14,6 +15,9 @@ type Context interface {
 
 	GetBean(bean Bean) Bean
 
+	//get the storage driver. all the matter files will use this
+	GetStorage() storage.Driver
+
 	//get the global session cache
 	GetSessionCache() *cache.Table
 
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+	"strings"
+)
+
+type AlbumController struct {
+	BaseController
+	albumDao      *dao.AlbumDao
+	matterDao     *dao.MatterDao
+	albumService  *service.AlbumService
+	matterService *service.MatterService
+}
+
+func (this *AlbumController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.albumDao)
+	if b, ok := b.(*dao.AlbumDao); ok {
+		this.albumDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.albumService)
+	if b, ok := b.(*service.AlbumService); ok {
+		this.albumService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*service.MatterService); ok {
+		this.matterService = b
+	}
+
+}
+
+func (this *AlbumController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/album/list"] = this.Wrap(this.List, model.USER_ROLE_USER)
+	routeMap["/api/album/create"] = this.Wrap(this.Create, model.USER_ROLE_USER)
+	routeMap["/api/album/rename"] = this.Wrap(this.Rename, model.USER_ROLE_USER)
+	routeMap["/api/album/delete"] = this.Wrap(this.Delete, model.USER_ROLE_USER)
+	routeMap["/api/album/add"] = this.Wrap(this.Add, model.USER_ROLE_USER)
+	routeMap["/api/album/remove"] = this.Wrap(this.Remove, model.USER_ROLE_USER)
+	routeMap["/api/album/photo/page"] = this.Wrap(this.PhotoPage, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// list the albums of a space. if matterUuid given, list the albums containing the matter.
+func (this *AlbumController) List(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuid := util.ExtractRequestOptionalString(request, "matterUuid", "")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+
+	if matterUuid != "" {
+		matter := this.matterDao.CheckByUuid(matterUuid)
+		if matter.SpaceUuid != space.Uuid {
+			panic(result.UNAUTHORIZED)
+		}
+		return this.Success(this.albumDao.ListByMatterUuid(matter.Uuid))
+	}
+
+	albums := this.albumService.List(request, space)
+
+	return this.Success(albums)
+}
+
+func (this *AlbumController) Create(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	name := util.ExtractRequestString(request, "name")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	album := this.albumService.Create(request, user, space, name)
+
+	return this.Success(album)
+}
+
+func (this *AlbumController) Rename(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+	name := util.ExtractRequestString(request, "name")
+
+	user := this.CheckUser(request)
+	album := this.albumDao.CheckByUuid(uuid)
+	this.spaceService.CheckWritableByUuid(request, user, album.SpaceUuid)
+
+	album = this.albumService.Rename(request, album, name)
+
+	return this.Success(album)
+}
+
+// delete an album. the matters in it are not deleted.
+func (this *AlbumController) Delete(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	album := this.albumDao.CheckByUuid(uuid)
+	this.spaceService.CheckWritableByUuid(request, user, album.SpaceUuid)
+
+	this.albumService.Delete(request, album)
+
+	return this.Success("OK")
+}
+
+// put matters into an album in bulk. uuids are separated by ','.
+func (this *AlbumController) Add(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+	matterUuids := util.ExtractRequestString(request, "matterUuids")
+
+	user := this.CheckUser(request)
+	album := this.albumDao.CheckByUuid(uuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, album.SpaceUuid)
+
+	matters := this.matterService.CheckByUuidsInSpace(strings.Split(matterUuids, ","), space)
+
+	this.albumService.Add(request, album, matters)
+
+	return this.Success("OK")
+}
+
+// take matters out of an album in bulk.
+func (this *AlbumController) Remove(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+	matterUuids := util.ExtractRequestString(request, "matterUuids")
+
+	user := this.CheckUser(request)
+	album := this.albumDao.CheckByUuid(uuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, album.SpaceUuid)
+
+	matters := this.matterService.CheckByUuidsInSpace(strings.Split(matterUuids, ","), space)
+
+	this.albumService.Remove(request, album, matters)
+
+	return this.Success("OK")
+}
+
+// page the matters of an album with thumbnails. ir is the size of thumbnails.
+func (this *AlbumController) PhotoPage(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 200)
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	album := this.albumDao.CheckByUuid(uuid)
+	this.spaceService.CheckReadableByUuid(request, user, album.SpaceUuid)
+
+	pager := this.albumService.PagePhotos(request, page, pageSize, album)
+
+	return this.Success(pager)
+}
@@ -25,6 +25,7 @@ type AlienController struct {
 	alienService       *service.AlienService
 	shareService       *service.ShareService
 	spaceMemberService *service.SpaceMemberService
+	recentService      *service.RecentService
 }
 
 func (this *AlienController) Init() {
@@ -79,6 +80,11 @@ func (this *AlienController) Init() {
 	if b, ok := b.(*service.SpaceMemberService); ok {
 		this.spaceMemberService = b
 	}
+
+	b = core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*service.RecentService); ok {
+		this.recentService = b
+	}
 }
 
 func (this *AlienController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
@@ -171,6 +177,7 @@ func (this *AlienController) FetchUploadToken(writer http.ResponseWriter, reques
 
 	uploadToken := &model.UploadToken{
 		UserUuid:   user.Uuid,
+		SpaceUuid:  space.Uuid,
 		FolderUuid: dirMatter.Uuid,
 		MatterUuid: "",
 		ExpireTime: expireTime,
@@ -248,6 +255,7 @@ func (this *AlienController) Upload(writer http.ResponseWriter, request *http.Re
 	dirMatter := this.matterDao.CheckWithRootByUuid(uploadToken.FolderUuid, space)
 
 	matter := this.matterService.Upload(request, file, handler, user, space, dirMatter, uploadToken.Filename, uploadToken.Privacy)
+	this.recentService.Record(request, user, matter, model.RECENT_ACTION_UPLOAD)
 
 	//expire the upload token.
 	uploadToken.ExpireTime = time.Now()
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+	"strings"
+)
+
+type DuplicateController struct {
+	BaseController
+	duplicateService *service.DuplicateService
+}
+
+func (this *DuplicateController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.duplicateService)
+	if b, ok := b.(*service.DuplicateService); ok {
+		this.duplicateService = b
+	}
+
+}
+
+func (this *DuplicateController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/duplicate/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+	routeMap["/api/duplicate/clean"] = this.Wrap(this.Clean, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// the space to search. all the spaces for administrators if all is true, empty uuid returned.
+func (this *DuplicateController) checkSpaceUuid(request *http.Request, user *model.User) string {
+
+	if util.ExtractRequestOptionalBool(request, "all", false) {
+		if user.Role != model.USER_ROLE_ADMINISTRATOR {
+			panic(result.UNAUTHORIZED)
+		}
+		return ""
+	}
+
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckAdminAbleByUuid(request, user, spaceUuid)
+	return space.Uuid
+}
+
+// page the groups of duplicate files in a space or all the spaces. most wasted first.
+func (this *DuplicateController) Page(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 20)
+	//ignore small files. in bytes.
+	minSize := util.ExtractRequestOptionalInt64(request, "minSize", 0)
+
+	user := this.CheckUser(request)
+	spaceUuid := this.checkSpaceUuid(request, user)
+
+	pager := this.duplicateService.Page(request, page, pageSize, spaceUuid, minSize)
+
+	return this.Success(pager)
+}
+
+// keep the chosen copies and move the other copies of the same contents to the recycle bin.
+func (this *DuplicateController) Clean(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	keepUuids := util.ExtractRequestString(request, "keepUuids")
+
+	user := this.CheckUser(request)
+	spaceUuid := this.checkSpaceUuid(request, user)
+
+	matters := this.duplicateService.Clean(request, user, spaceUuid, strings.Split(keepUuids, ","))
+
+	return this.Success(matters)
+}
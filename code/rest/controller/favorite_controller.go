@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+)
+
+type FavoriteController struct {
+	BaseController
+	matterDao       *dao.MatterDao
+	favoriteService *service.FavoriteService
+}
+
+func (this *FavoriteController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.favoriteService)
+	if b, ok := b.(*service.FavoriteService); ok {
+		this.favoriteService = b
+	}
+
+}
+
+func (this *FavoriteController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/favorite/star"] = this.Wrap(this.Star, model.USER_ROLE_USER)
+	routeMap["/api/favorite/unstar"] = this.Wrap(this.Unstar, model.USER_ROLE_USER)
+	routeMap["/api/favorite/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+func (this *FavoriteController) Star(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuid := util.ExtractRequestString(request, "matterUuid")
+
+	user := this.CheckUser(request)
+	matter := this.matterDao.CheckByUuid(matterUuid)
+	this.spaceService.CheckReadableByUuid(request, user, matter.SpaceUuid)
+
+	favorite := this.favoriteService.Star(request, user, matter)
+
+	return this.Success(favorite)
+}
+
+func (this *FavoriteController) Unstar(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuid := util.ExtractRequestString(request, "matterUuid")
+
+	user := this.CheckUser(request)
+
+	this.favoriteService.Unstar(request, user, matterUuid)
+
+	return this.Success("OK")
+}
+
+// page the current user's favorites across all the spaces. spaceUuid is optional.
+func (this *FavoriteController) Page(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 200)
+	orderCreateTime := util.ExtractRequestOptionalString(request, "orderCreateTime", model.DIRECTION_DESC)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", "")
+
+	user := this.CheckUser(request)
+
+	sortArray := []builder.OrderPair{
+		{Key: "create_time", Value: orderCreateTime},
+	}
+
+	pager := this.favoriteService.Page(request, page, pageSize, user, spaceUuid, sortArray)
+
+	return this.Success(pager)
+}
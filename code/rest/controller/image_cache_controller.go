@@ -41,6 +41,9 @@ func (this *ImageCacheController) RegisterRoutes() map[string]func(writer http.R
 	routeMap["/api/image/cache/delete/batch"] = this.Wrap(this.DeleteBatch, model.USER_ROLE_USER)
 	routeMap["/api/image/cache/detail"] = this.Wrap(this.Detail, model.USER_ROLE_USER)
 	routeMap["/api/image/cache/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+	routeMap["/api/image/cache/backfill"] = this.Wrap(this.Backfill, model.USER_ROLE_ADMINISTRATOR)
+	routeMap["/api/image/cache/backfill/progress"] = this.Wrap(this.BackfillProgress, model.USER_ROLE_ADMINISTRATOR)
+	routeMap["/api/image/cache/stats"] = this.Wrap(this.Stats, model.USER_ROLE_ADMINISTRATOR)
 
 	return routeMap
 }
@@ -159,3 +162,26 @@ func (this *ImageCacheController) DeleteBatch(writer http.ResponseWriter, reques
 
 	return this.Success("OK")
 }
+
+// generate the thumbnail presets for existing images in background.
+func (this *ImageCacheController) Backfill(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	this.imageCacheService.Backfill(request)
+
+	return this.Success("OK")
+}
+
+func (this *ImageCacheController) BackfillProgress(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	progress := this.imageCacheService.BackfillProgress()
+
+	return this.Success(progress)
+}
+
+// hit/miss statistics and sizes of the image caches.
+func (this *ImageCacheController) Stats(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	stats := this.imageCacheService.Stats()
+
+	return this.Success(stats)
+}
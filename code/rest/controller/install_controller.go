@@ -72,17 +72,28 @@ func (this *InstallController) Init() {
 	}
 
 	this.tableNames = []interface{}{
+		&model.Album{},
+		&model.AlbumMatter{},
+		&model.Blob{},
 		&model.Dashboard{},
 		&model.Bridge{},
 		&model.DownloadToken{},
+		&model.Favorite{},
 		&model.Footprint{},
 		&model.ImageCache{},
+		&model.Job{},
 		&model.Matter{},
+		&model.MatterMedia{},
+		&model.MatterTag{},
+		&model.MatterVersion{},
 		&model.Preference{},
+		&model.Recent{},
+		&model.ScanReport{},
 		&model.Session{},
 		&model.Share{},
 		&model.Space{},
 		&model.SpaceMember{},
+		&model.Tag{},
 		&model.UploadToken{},
 		&model.User{},
 	}
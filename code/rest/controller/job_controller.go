@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+)
+
+type JobController struct {
+	BaseController
+	jobDao     *dao.JobDao
+	jobService *service.JobService
+}
+
+func (this *JobController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.jobDao)
+	if b, ok := b.(*dao.JobDao); ok {
+		this.jobDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.jobService)
+	if b, ok := b.(*service.JobService); ok {
+		this.jobService = b
+	}
+
+}
+
+func (this *JobController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/job/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+	routeMap["/api/job/detail"] = this.Wrap(this.Detail, model.USER_ROLE_USER)
+	routeMap["/api/job/cancel"] = this.Wrap(this.Cancel, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// page the jobs. users see their own jobs, administrators see all the jobs.
+func (this *JobController) Page(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 20)
+	jobType := util.ExtractRequestOptionalString(request, "type", "")
+	status := util.ExtractRequestOptionalString(request, "status", "")
+
+	user := this.CheckUser(request)
+	userUuid := user.Uuid
+	if user.Role == model.USER_ROLE_ADMINISTRATOR {
+		userUuid = util.ExtractRequestOptionalString(request, "userUuid", "")
+	}
+
+	sortArray := []builder.OrderPair{
+		{Key: "sort", Value: model.DIRECTION_DESC},
+	}
+
+	pager := this.jobDao.Page(page, pageSize, userUuid, jobType, status, sortArray)
+
+	return this.Success(pager)
+}
+
+func (this *JobController) Detail(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	job := this.checkJob(user, uuid)
+
+	return this.Success(job)
+}
+
+func (this *JobController) Cancel(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	job := this.checkJob(user, uuid)
+	if job.Finished() {
+		panic(result.BadRequest("job %s is %s. cannot cancel it.", job.Uuid, job.Status))
+	}
+
+	this.jobService.Cancel(request, job)
+
+	return this.Success(this.jobDao.CheckByUuid(uuid))
+}
+
+// only the submitter and the administrators can see a job.
+func (this *JobController) checkJob(user *model.User, uuid string) *model.Job {
+	job := this.jobDao.CheckByUuid(uuid)
+	if job.UserUuid != user.Uuid && user.Role != model.USER_ROLE_ADMINISTRATOR {
+		panic(result.UNAUTHORIZED)
+	}
+	return job
+}
@@ -5,26 +5,31 @@ import (
 	"box/code/rest/dao"
 	"box/code/rest/model"
 	"box/code/rest/service"
+	"box/code/tool/archive"
+	"box/code/tool/builder"
 	"box/code/tool/i18n"
 	"box/code/tool/result"
 	"box/code/tool/util"
 	"net/http"
-	"sort"
 	"strings"
 )
 
 type MatterController struct {
 	BaseController
-	matterDao         *dao.MatterDao
-	matterService     *service.MatterService
-	preferenceService *service.PreferenceService
-	downloadTokenDao  *dao.DownloadTokenDao
-	imageCacheDao     *dao.ImageCacheDao
-	shareDao          *dao.ShareDao
-	spaceDao          *dao.SpaceDao
-	shareService      *service.ShareService
-	bridgeDao         *dao.BridgeDao
-	imageCacheService *service.ImageCacheService
+	matterDao           *dao.MatterDao
+	matterService       *service.MatterService
+	preferenceService   *service.PreferenceService
+	downloadTokenDao    *dao.DownloadTokenDao
+	imageCacheDao       *dao.ImageCacheDao
+	shareDao            *dao.ShareDao
+	spaceDao            *dao.SpaceDao
+	shareService        *service.ShareService
+	bridgeDao           *dao.BridgeDao
+	imageCacheService   *service.ImageCacheService
+	matterSearchService *service.MatterSearchService
+	recentService       *service.RecentService
+	matterMediaService  *service.MatterMediaService
+	jobService          *service.JobService
 }
 
 func (this *MatterController) Init() {
@@ -74,14 +79,37 @@ func (this *MatterController) Init() {
 	if b, ok := b.(*service.ImageCacheService); ok {
 		this.imageCacheService = b
 	}
+
+	b = core.CONTEXT.GetBean(this.matterSearchService)
+	if b, ok := b.(*service.MatterSearchService); ok {
+		this.matterSearchService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*service.RecentService); ok {
+		this.recentService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterMediaService)
+	if b, ok := b.(*service.MatterMediaService); ok {
+		this.matterMediaService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.jobService)
+	if b, ok := b.(*service.JobService); ok {
+		this.jobService = b
+	}
 }
 
 func (this *MatterController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
 
 	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
 	routeMap["/api/matter/detail"] = this.Wrap(this.Detail, model.USER_ROLE_USER)
+	routeMap["/api/matter/preview/text"] = this.Wrap(this.PreviewText, model.USER_ROLE_USER)
 	routeMap["/api/matter/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
 	routeMap["/api/matter/search"] = this.Wrap(this.Search, model.USER_ROLE_USER)
+	routeMap["/api/matter/search/rebuild"] = this.Wrap(this.RebuildSearchIndex, model.USER_ROLE_ADMINISTRATOR)
+	routeMap["/api/matter/media/backfill"] = this.Wrap(this.BackfillMedia, model.USER_ROLE_ADMINISTRATOR)
 
 	routeMap["/api/matter/create/directory"] = this.Wrap(this.CreateDirectory, model.USER_ROLE_USER)
 	routeMap["/api/matter/upload"] = this.Wrap(this.Upload, model.USER_ROLE_USER)
@@ -96,10 +124,12 @@ func (this *MatterController) RegisterRoutes() map[string]func(writer http.Respo
 	routeMap["/api/matter/rename"] = this.Wrap(this.Rename, model.USER_ROLE_USER)
 	routeMap["/api/matter/change/privacy"] = this.Wrap(this.ChangePrivacy, model.USER_ROLE_USER)
 	routeMap["/api/matter/move"] = this.Wrap(this.Move, model.USER_ROLE_USER)
+	routeMap["/api/matter/copy"] = this.Wrap(this.Copy, model.USER_ROLE_USER)
 
 	//mirror local files.
 	routeMap["/api/matter/mirror"] = this.Wrap(this.Mirror, model.USER_ROLE_USER)
 	routeMap["/api/matter/zip"] = this.Wrap(this.Zip, model.USER_ROLE_USER)
+	routeMap["/api/matter/extract"] = this.Wrap(this.Extract, model.USER_ROLE_USER)
 
 	return routeMap
 }
@@ -128,6 +158,37 @@ func (this *MatterController) Detail(writer http.ResponseWriter, request *http.R
 
 }
 
+// preview the first lines or a byte range of a text matter in UTF-8.
+func (this *MatterController) PreviewText(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+
+	matter := this.matterDao.CheckByUuid(uuid)
+	if matter.SpaceUuid != space.Uuid {
+		panic(result.UNAUTHORIZED)
+	}
+
+	//a byte range if length is given, otherwise the first lines.
+	offset := util.ExtractRequestOptionalInt64(request, "offset", 0)
+	length := util.ExtractRequestOptionalInt64(request, "length", 0)
+	lines := 0
+	if length == 0 {
+		lines = util.ExtractRequestOptionalInt(request, "lines", model.TEXT_PREVIEW_DEFAULT_LINES)
+		if lines <= 0 || lines > model.TEXT_PREVIEW_MAX_LINES {
+			panic(result.BadRequest("lines should between 1 and %d", model.TEXT_PREVIEW_MAX_LINES))
+		}
+	} else if length < 0 || length > model.TEXT_PREVIEW_MAX_BYTES {
+		panic(result.BadRequest("length should between 1 and %d", model.TEXT_PREVIEW_MAX_BYTES))
+	}
+
+	preview := this.matterService.PreviewText(matter, lines, offset, length)
+
+	return this.Success(preview)
+}
+
 func (this *MatterController) Page(writer http.ResponseWriter, request *http.Request) *result.WebResult {
 
 	page := util.ExtractRequestOptionalInt(request, "page", 0)
@@ -179,53 +240,93 @@ func (this *MatterController) Page(writer http.ResponseWriter, request *http.Req
 	return this.Success(pager)
 }
 
-// DFS search.
+// search matters in a space or under a directory. keyword is optional.
 func (this *MatterController) Search(writer http.ResponseWriter, request *http.Request) *result.WebResult {
 
-	limit := util.ExtractRequestOptionalInt(request, "limit", 200)
-	puuid := util.ExtractRequestString(request, "puuid")
-	keyword := util.ExtractRequestString(request, "keyword")
-	deletedStr := util.ExtractRequestOptionalString(request, "deleted", "")
-	var deleted = false
-	if deletedStr == model.TRUE {
-		deleted = true
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 200)
+	orderCreateTime := util.ExtractRequestOptionalString(request, "orderCreateTime", "")
+	orderUpdateTime := util.ExtractRequestOptionalString(request, "orderUpdateTime", "")
+	orderDeleteTime := util.ExtractRequestOptionalString(request, "orderDeleteTime", "")
+	orderTimes := util.ExtractRequestOptionalString(request, "orderTimes", "")
+	orderDir := util.ExtractRequestOptionalString(request, "orderDir", "")
+	orderSize := util.ExtractRequestOptionalString(request, "orderSize", "")
+	orderName := util.ExtractRequestOptionalString(request, "orderName", "")
+
+	puuid := util.ExtractRequestOptionalString(request, "puuid", model.MATTER_ROOT)
+	extensionsStr := util.ExtractRequestOptionalString(request, "extensions", "")
+	categoriesStr := util.ExtractRequestOptionalString(request, "categories", "")
+
+	filter := &model.MatterFilter{
+		Keyword:         util.ExtractRequestOptionalString(request, "keyword", ""),
+		SizeMin:         util.ExtractRequestOptionalInt64(request, "sizeMin", -1),
+		SizeMax:         util.ExtractRequestOptionalInt64(request, "sizeMax", -1),
+		CreateTimeStart: util.ExtractRequestOptionalTime(request, "createTimeStart"),
+		CreateTimeEnd:   util.ExtractRequestOptionalTime(request, "createTimeEnd"),
+		UpdateTimeStart: util.ExtractRequestOptionalTime(request, "updateTimeStart"),
+		UpdateTimeEnd:   util.ExtractRequestOptionalTime(request, "updateTimeEnd"),
+		UserUuid:        util.ExtractRequestOptionalString(request, "userUuid", ""),
+		Dir:             util.ExtractRequestOptionalString(request, "dir", ""),
+		Privacy:         util.ExtractRequestOptionalString(request, "privacy", ""),
+		//not deleted by default. other values like "all" mean both.
+		Deleted:        util.ExtractRequestOptionalString(request, "deleted", model.FALSE),
+		CameraMake:     util.ExtractRequestOptionalString(request, "cameraMake", ""),
+		CameraModel:    util.ExtractRequestOptionalString(request, "cameraModel", ""),
+		TakenTimeStart: util.ExtractRequestOptionalTime(request, "takenTimeStart"),
+		TakenTimeEnd:   util.ExtractRequestOptionalTime(request, "takenTimeEnd"),
+		Gps:            util.ExtractRequestOptionalString(request, "gps", ""),
+		Artist:         util.ExtractRequestOptionalString(request, "artist", ""),
+		Album:          util.ExtractRequestOptionalString(request, "album", ""),
+	}
+	if extensionsStr != "" {
+		for _, extension := range strings.Split(extensionsStr, ",") {
+			filter.Extensions = append(filter.Extensions, strings.TrimPrefix(strings.ToLower(strings.TrimSpace(extension)), "."))
+		}
+	}
+	if categoriesStr != "" {
+		filter.Categories = strings.Split(categoriesStr, ",")
+	}
+
+	sortArray := []builder.OrderPair{
+		{Key: "dir", Value: orderDir},
+		{Key: "create_time", Value: orderCreateTime},
+		{Key: "update_time", Value: orderUpdateTime},
+		{Key: "delete_time", Value: orderDeleteTime},
+		{Key: "size", Value: orderSize},
+		{Key: "name", Value: orderName},
+		{Key: "times", Value: orderTimes},
 	}
 
 	user := this.CheckUser(request)
 	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
-	this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
 
-	matters := this.matterService.DfsSearch(
-		request,
-		limit,
-		puuid,
-		keyword,
-		spaceUuid,
-		deleted,
-	)
+	dirMatter := this.matterDao.CheckWithRootByUuid(puuid, space)
+	if dirMatter.SpaceUuid != space.Uuid {
+		panic(result.UNAUTHORIZED)
+	}
 
-	//sort.
-	//从小到大自定义排序
-	sort.Slice(matters, func(i, j int) bool {
-		matter1 := matters[i]
-		matter2 := matters[j]
-		if matter1.Dir {
-			return true
-		} else if matter2.Dir {
-			return false
-		} else {
-			cmp := strings.Compare(matter1.Name, matter2.Name)
-			if cmp < 0 {
-				return true
-			} else if cmp == 0 {
-				return true
-			} else {
-				return false
-			}
-		}
-	})
+	pager := this.matterSearchService.Search(request, page, pageSize, space, dirMatter, filter, sortArray)
 
-	return this.Success(matters)
+	return this.Success(pager)
+}
+
+// rebuild the search index of all matters.
+func (this *MatterController) RebuildSearchIndex(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	count := this.matterSearchService.Rebuild(request)
+
+	return this.Success(count)
+}
+
+// extract the media metadata of existing files in background.
+func (this *MatterController) BackfillMedia(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	force := util.ExtractRequestOptionalBool(request, "force", false)
+
+	this.matterMediaService.Backfill(request, force)
+
+	return this.Success("OK")
 }
 
 func (this *MatterController) CreateDirectory(writer http.ResponseWriter, request *http.Request) *result.WebResult {
@@ -275,17 +376,19 @@ func (this *MatterController) Upload(writer http.ResponseWriter, request *http.R
 
 	//support upload simultaneously
 	matter := this.matterService.Upload(request, file, handler, user, space, dirMatter, fileName, privacy)
+	this.recentService.Record(request, user, matter, model.RECENT_ACTION_UPLOAD)
 
 	return this.Success(matter)
 }
 
-// crawl a file by url.
+// crawl a file by url. async crawls in a job and returns the job.
 func (this *MatterController) Crawl(writer http.ResponseWriter, request *http.Request) *result.WebResult {
 
 	url := util.ExtractRequestString(request, "url")
 	destPath := util.ExtractRequestOptionalString(request, "destPath", "")
 	puuid := util.ExtractRequestOptionalString(request, "puuid", "")
 	filename := util.ExtractRequestString(request, "filename")
+	async := util.ExtractRequestOptionalBool(request, "async", false)
 
 	user := this.CheckUser(request)
 	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
@@ -311,6 +414,11 @@ func (this *MatterController) Crawl(writer http.ResponseWriter, request *http.Re
 		panic(" url must start with  http:// or https://")
 	}
 
+	if async {
+		job := this.jobService.Submit(user, space.Uuid, model.JOB_TYPE_CRAWL, &model.JobParams{DestUuid: dirMatter.Uuid, Name: filename, Url: url, Privacy: true})
+		return this.Success(job)
+	}
+
 	matter := this.matterService.AtomicCrawl(request, url, filename, user, space, dirMatter, true)
 
 	return this.Success(matter)
@@ -556,7 +664,7 @@ func (this *MatterController) Move(writer http.ResponseWriter, request *http.Req
 	for _, uuid := range srcUuids {
 		srcMatter := this.matterDao.CheckByUuid(uuid)
 
-		if srcMatter.Puuid == destMatter.Uuid {
+		if srcMatter.Puuid == destMatter.Uuid && srcMatter.SpaceUuid == destMatter.SpaceUuid {
 			panic(result.BadRequest("no move, invalid operation"))
 		}
 
@@ -565,14 +673,13 @@ func (this *MatterController) Move(writer http.ResponseWriter, request *http.Req
 		}
 
 		//check whether there are files with the same name.
-		count := this.matterDao.CountByUserUuidAndPuuidAndDirAndName(user.Uuid, destMatter.Uuid, srcMatter.Dir, srcMatter.Name)
-
-		if count > 0 {
+		if this.existInDir(space, destMatter, srcMatter.Dir, srcMatter.Name) {
 			panic(result.BadRequestI18n(request, i18n.MatterExist, srcMatter.Name))
 		}
 
+		//moving out of a space changes it.
 		if srcMatter.SpaceUuid != destMatter.SpaceUuid {
-			panic("space not the same")
+			this.spaceService.CheckWritableByUuid(request, user, srcMatter.SpaceUuid)
 		}
 
 		srcMatters = append(srcMatters, srcMatter)
@@ -583,17 +690,84 @@ func (this *MatterController) Move(writer http.ResponseWriter, request *http.Req
 	return this.Success(nil)
 }
 
-// mirror local files to EyeblueTank
+// copy a matter in a job. return the job.
+func (this *MatterController) Copy(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+	srcUuid := util.ExtractRequestString(request, "srcUuid")
+	destUuid := util.ExtractRequestString(request, "destUuid")
+	name := util.ExtractRequestOptionalString(request, "name", "")
+	overwrite := util.ExtractRequestOptionalBool(request, "overwrite", false)
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	var destMatter = this.matterDao.CheckWithRootByUuid(destUuid, space)
+	if !destMatter.Dir {
+		panic(result.BadRequest("destination is not a directory"))
+	}
+
+	if destMatter.SpaceUuid != space.Uuid {
+		panic(result.UNAUTHORIZED)
+	}
+
+	if destMatter.Deleted {
+		panic(result.BadRequest("dest matter has been deleted. Cannot copy."))
+	}
+
+	srcMatter := this.matterDao.CheckByUuid(srcUuid)
+	if srcMatter.Deleted {
+		panic(result.BadRequest("src matter has been deleted. Cannot copy."))
+	}
+
+	//copying from a space only reads it.
+	if srcMatter.SpaceUuid != destMatter.SpaceUuid {
+		this.spaceService.CheckReadableByUuid(request, user, srcMatter.SpaceUuid)
+	}
+
+	if name == "" {
+		name = srcMatter.Name
+	}
+	name = model.CheckMatterName(request, name)
+
+	//a directory cannot be copied into itself.
+	if srcMatter.Dir && srcMatter.SpaceUuid == destMatter.SpaceUuid && (destMatter.Path == srcMatter.Path || strings.HasPrefix(destMatter.Path, srcMatter.Path+"/")) {
+		panic(result.BadRequest("cannot copy a directory into itself."))
+	}
+
+	if !overwrite && this.existInDir(space, destMatter, srcMatter.Dir, name) {
+		panic(result.BadRequestI18n(request, i18n.MatterExist, name))
+	}
+
+	job := this.jobService.Submit(user, space.Uuid, model.JOB_TYPE_COPY, &model.JobParams{SrcUuid: srcMatter.Uuid, DestUuid: destMatter.Uuid, Name: name, Overwrite: overwrite})
+
+	return this.Success(job)
+}
+
+// whether the directory has a matter with the name. root directories of all the spaces share the same uuid.
+func (this *MatterController) existInDir(space *model.Space, dirMatter *model.Matter, dir bool, name string) bool {
+	dirStr := model.FALSE
+	if dir {
+		dirStr = model.TRUE
+	}
+	return this.matterDao.FindBySpaceNameAndPuuidAndDirAndName(space.Name, dirMatter.Uuid, dirStr, name) != nil
+}
+
+// mirror local files to EyeblueTank. async mirrors in a job and returns the job.
 func (this *MatterController) Mirror(writer http.ResponseWriter, request *http.Request) *result.WebResult {
 
 	srcPath := util.ExtractRequestString(request, "srcPath")
 	destPath := util.ExtractRequestString(request, "destPath")
 	overwrite := util.ExtractRequestOptionalBool(request, "overwrite", false)
+	async := util.ExtractRequestOptionalBool(request, "async", false)
 
 	user := this.CheckUser(request)
 	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
 	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
 
+	if async {
+		job := this.jobService.Submit(user, space.Uuid, model.JOB_TYPE_MIRROR, &model.JobParams{SrcPath: srcPath, DestPath: destPath, Overwrite: overwrite})
+		return this.Success(job)
+	}
+
 	this.matterService.AtomicMirror(request, srcPath, destPath, overwrite, user, space)
 
 	return this.Success(nil)
@@ -603,6 +777,7 @@ func (this *MatterController) Mirror(writer http.ResponseWriter, request *http.R
 // download zip.
 func (this *MatterController) Zip(writer http.ResponseWriter, request *http.Request) *result.WebResult {
 	uuids := util.ExtractRequestString(request, "uuids")
+	format := util.ExtractRequestOptionalString(request, "format", archive.FORMAT_ZIP)
 	user := this.CheckUser(request)
 	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
 	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
@@ -631,7 +806,33 @@ func (this *MatterController) Zip(writer http.ResponseWriter, request *http.Requ
 		}
 	}
 
-	this.matterService.DownloadZip(writer, request, matters)
+	this.matterService.DownloadArchive(writer, request, matters, format)
 
 	return nil
 }
+
+// extract a zip, tar or tar.gz file. extract to where the file is if destUuid not specified.
+func (this *MatterController) Extract(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+	uuid := util.ExtractRequestString(request, "uuid")
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	matter := this.matterDao.CheckByUuid(uuid)
+	if matter.SpaceUuid != space.Uuid {
+		panic(result.UNAUTHORIZED)
+	}
+
+	destUuid := util.ExtractRequestOptionalString(request, "destUuid", matter.Puuid)
+	destMatter := this.matterDao.CheckWithRootByUuid(destUuid, space)
+	if !destMatter.Dir {
+		panic(result.BadRequest("destination is not a directory"))
+	}
+	if destMatter.SpaceUuid != space.Uuid {
+		panic(result.UNAUTHORIZED)
+	}
+
+	matters := this.matterService.AtomicExtract(request, matter, destMatter, user, space)
+
+	return this.Success(matters)
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+)
+
+type MatterVersionController struct {
+	BaseController
+	matterDao            *dao.MatterDao
+	matterVersionDao     *dao.MatterVersionDao
+	matterVersionService *service.MatterVersionService
+}
+
+func (this *MatterVersionController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterVersionDao)
+	if b, ok := b.(*dao.MatterVersionDao); ok {
+		this.matterVersionDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterVersionService)
+	if b, ok := b.(*service.MatterVersionService); ok {
+		this.matterVersionService = b
+	}
+
+}
+
+func (this *MatterVersionController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/matter/version/list"] = this.Wrap(this.List, model.USER_ROLE_USER)
+	routeMap["/api/matter/version/download"] = this.Wrap(this.Download, model.USER_ROLE_USER)
+	routeMap["/api/matter/version/restore"] = this.Wrap(this.Restore, model.USER_ROLE_USER)
+	routeMap["/api/matter/version/delete"] = this.Wrap(this.Delete, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// list the versions of a file. the first one is the current content.
+func (this *MatterVersionController) List(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuid := util.ExtractRequestString(request, "matterUuid")
+
+	user := this.CheckUser(request)
+	matter := this.matterDao.CheckByUuid(matterUuid)
+	this.spaceService.CheckReadableByUuid(request, user, matter.SpaceUuid)
+
+	versions := this.matterVersionService.List(matter)
+
+	return this.Success(versions)
+}
+
+func (this *MatterVersionController) Download(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	version := this.matterVersionDao.CheckByUuid(uuid)
+	matter := this.matterDao.CheckByUuid(version.MatterUuid)
+	this.spaceService.CheckReadableByUuid(request, user, matter.SpaceUuid)
+
+	this.matterVersionService.Download(writer, request, version, matter)
+
+	return nil
+}
+
+// restore a version. the current content will be kept as a version too.
+func (this *MatterVersionController) Restore(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	version := this.matterVersionDao.CheckByUuid(uuid)
+	matter := this.matterDao.CheckByUuid(version.MatterUuid)
+	if matter.Deleted {
+		panic(result.BadRequest("matter has been deleted. Cannot restore."))
+	}
+	space := this.spaceService.CheckWritableByUuid(request, user, matter.SpaceUuid)
+
+	matter = this.matterVersionService.AtomicRestore(request, version, matter, user, space)
+
+	return this.Success(matter)
+}
+
+func (this *MatterVersionController) Delete(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	version := this.matterVersionDao.CheckByUuid(uuid)
+	this.spaceService.CheckWritableByUuid(request, user, version.SpaceUuid)
+
+	this.matterVersionService.Delete(request, version)
+
+	return this.Success("OK")
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+)
+
+type PhotoController struct {
+	BaseController
+	photoService *service.PhotoService
+}
+
+func (this *PhotoController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.photoService)
+	if b, ok := b.(*service.PhotoService); ok {
+		this.photoService = b
+	}
+
+}
+
+func (this *PhotoController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/photo/timeline"] = this.Wrap(this.Timeline, model.USER_ROLE_USER)
+	routeMap["/api/photo/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// count the images of a space by year, month or day of capture.
+func (this *PhotoController) Timeline(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	granularity := util.ExtractRequestOptionalString(request, "granularity", model.PHOTO_GRANULARITY_MONTH)
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+
+	buckets := this.photoService.Timeline(request, space, granularity)
+
+	return this.Success(buckets)
+}
+
+// page the images of a space, newest first. key is a bucket of the timeline. ir is the size of thumbnails.
+func (this *PhotoController) Page(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 200)
+	key := util.ExtractRequestOptionalString(request, "key", "")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+
+	pager := this.photoService.Page(request, page, pageSize, space, key)
+
+	return this.Success(pager)
+}
@@ -11,6 +11,7 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type PreferenceController struct {
@@ -18,8 +19,12 @@ type PreferenceController struct {
 	preferenceDao     *dao.PreferenceDao
 	matterDao         *dao.MatterDao
 	matterService     *service.MatterService
+	scanService       *service.ScanService
+	watchService      *service.WatchService
 	preferenceService *service.PreferenceService
 	taskService       *service.TaskService
+	jobService        *service.JobService
+	imageCacheService *service.ImageCacheService
 }
 
 func (this *PreferenceController) Init() {
@@ -34,6 +39,17 @@ func (this *PreferenceController) Init() {
 	if b, ok := b.(*dao.MatterDao); ok {
 		this.matterDao = b
 	}
+
+	b = core.CONTEXT.GetBean(this.scanService)
+	if b, ok := b.(*service.ScanService); ok {
+		this.scanService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.watchService)
+	if b, ok := b.(*service.WatchService); ok {
+		this.watchService = b
+	}
+
 	b = core.CONTEXT.GetBean(this.matterService)
 	if b, ok := b.(*service.MatterService); ok {
 		this.matterService = b
@@ -48,6 +64,16 @@ func (this *PreferenceController) Init() {
 		this.taskService = b
 	}
 
+	b = core.CONTEXT.GetBean(this.jobService)
+	if b, ok := b.(*service.JobService); ok {
+		this.jobService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.imageCacheService)
+	if b, ok := b.(*service.ImageCacheService); ok {
+		this.imageCacheService = b
+	}
+
 }
 
 func (this *PreferenceController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
@@ -60,6 +86,8 @@ func (this *PreferenceController) RegisterRoutes() map[string]func(writer http.R
 	routeMap["/api/preference/edit/preview/config"] = this.Wrap(this.EditPreviewConfig, model.USER_ROLE_ADMINISTRATOR)
 	routeMap["/api/preference/edit/scan/config"] = this.Wrap(this.EditScanConfig, model.USER_ROLE_ADMINISTRATOR)
 	routeMap["/api/preference/scan/once"] = this.Wrap(this.ScanOnce, model.USER_ROLE_ADMINISTRATOR)
+	routeMap["/api/preference/scan/reports"] = this.Wrap(this.ScanReports, model.USER_ROLE_ADMINISTRATOR)
+	routeMap["/api/preference/scan/apply"] = this.Wrap(this.ScanApply, model.USER_ROLE_ADMINISTRATOR)
 	routeMap["/api/preference/system/cleanup"] = this.Wrap(this.SystemCleanup, model.USER_ROLE_ADMINISTRATOR)
 
 	return routeMap
@@ -144,6 +172,34 @@ func (this *PreferenceController) Edit(writer http.ResponseWriter, request *http
 	}
 
 	preference := this.preferenceDao.Fetch()
+
+	//0 means keep forever.
+	recentKeepDays := util.ExtractRequestOptionalInt64(request, "recentKeepDays", preference.RecentKeepDays)
+	if recentKeepDays < 0 {
+		panic(result.BadRequest("recentKeepDays cannot less than 0"))
+	}
+
+	//ir separated by ','. empty means no eager thumbnails.
+	thumbnailPresets := util.ExtractRequestOptionalString(request, "thumbnailPresets", preference.ThumbnailPresets)
+	var presets []string
+	for _, preset := range strings.Split(thumbnailPresets, ",") {
+		if preset = strings.TrimSpace(preset); preset != "" {
+			//panic if the format is wrong.
+			this.imageCacheService.ParseIr(preset)
+			presets = append(presets, preset)
+		}
+	}
+
+	//bytes. -1 means no limit.
+	imageCacheMaxSize := util.ExtractRequestOptionalInt64(request, "imageCacheMaxSize", preference.ImageCacheMaxSize)
+	if imageCacheMaxSize < -1 {
+		panic(result.BadRequest("imageCacheMaxSize cannot less than -1"))
+	}
+	imageCacheSpaceMaxSize := util.ExtractRequestOptionalInt64(request, "imageCacheSpaceMaxSize", preference.ImageCacheSpaceMaxSize)
+	if imageCacheSpaceMaxSize < -1 {
+		panic(result.BadRequest("imageCacheSpaceMaxSize cannot less than -1"))
+	}
+
 	oldDeletedKeepDays := preference.DeletedKeepDays
 	preference.Name = name
 	preference.LogoUrl = logoUrl
@@ -155,9 +211,16 @@ func (this *PreferenceController) Edit(writer http.ResponseWriter, request *http
 	preference.DefaultTotalSizeLimit = defaultTotalSizeLimit
 	preference.AllowRegister = allowRegister
 	preference.DeletedKeepDays = deletedKeepDays
+	preference.RecentKeepDays = recentKeepDays
+	preference.ThumbnailPresets = strings.Join(presets, ",")
+	preference.ImageCacheMaxSize = imageCacheMaxSize
+	preference.ImageCacheSpaceMaxSize = imageCacheSpaceMaxSize
 
 	preference = this.preferenceService.Save(preference)
 
+	//the limits may be lowered.
+	this.imageCacheService.TriggerEvict()
+
 	//if changed the bin strategy. then trigger once.
 	if oldDeletedKeepDays != deletedKeepDays {
 		this.matterService.CleanExpiredDeletedMatters()
@@ -213,20 +276,53 @@ func (this *PreferenceController) EditScanConfig(writer http.ResponseWriter, req
 		}
 	}
 
+	if scanConfig.MaxRemovePercent < 0 || scanConfig.MaxRemovePercent > 100 {
+		panic(result.BadRequest("maxRemovePercent should between 0 and 100"))
+	}
+
 	preference.ScanConfig = scanConfigStr
 	preference = this.preferenceService.Save(preference)
 
 	//reinit the scan task.
 	this.taskService.InitScanTask()
 
+	//reload the watchers.
+	this.watchService.Reload()
+
 	return this.Success(preference)
 }
 
-// scan immediately according the current config.
+// scan in a job according the current config. the reports are the result of the job.
 func (this *PreferenceController) ScanOnce(writer http.ResponseWriter, request *http.Request) *result.WebResult {
 
-	this.taskService.DoScanTask()
-	return this.Success("OK")
+	full := util.ExtractRequestOptionalBool(request, "full", false)
+	dryRun := util.ExtractRequestOptionalBool(request, "dryRun", false)
+
+	user := this.CheckUser(request)
+	job := this.jobService.Submit(user, "", model.JOB_TYPE_SCAN, &model.JobParams{Full: full, DryRun: dryRun})
+
+	return this.Success(job)
+}
+
+// the last scan report of every space.
+func (this *PreferenceController) ScanReports(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	reports := this.scanService.Reports()
+
+	return this.Success(reports)
+}
+
+// make the changes found by a dry run.
+func (this *PreferenceController) ScanApply(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := request.FormValue("uuid")
+	if uuid == "" {
+		panic(result.BadRequest("uuid cannot be null"))
+	}
+
+	report := this.scanService.Apply(request, uuid)
+
+	return this.Success(report)
 }
 
 // cleanup system data.
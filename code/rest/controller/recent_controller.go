@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+)
+
+type RecentController struct {
+	BaseController
+	recentService *service.RecentService
+}
+
+func (this *RecentController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*service.RecentService); ok {
+		this.recentService = b
+	}
+
+}
+
+func (this *RecentController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/recent/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// page the files recently used by the current user. the latest first.
+func (this *RecentController) Page(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 200)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", "")
+	action := util.ExtractRequestOptionalString(request, "action", "")
+
+	user := this.CheckUser(request)
+
+	sortArray := []builder.OrderPair{
+		{Key: "visit_time", Value: model.DIRECTION_DESC},
+	}
+
+	pager := this.recentService.Page(request, page, pageSize, user, spaceUuid, action, sortArray)
+
+	return this.Success(pager)
+}
@@ -5,6 +5,7 @@ import (
 	"box/code/rest/dao"
 	"box/code/rest/model"
 	"box/code/rest/service"
+	"box/code/tool/archive"
 	"box/code/tool/builder"
 	"box/code/tool/i18n"
 	"box/code/tool/result"
@@ -346,6 +347,7 @@ func (this *ShareController) Zip(writer http.ResponseWriter, request *http.Reque
 
 	puuid := request.FormValue("puuid")
 	rootUuid := request.FormValue("rootUuid")
+	format := util.ExtractRequestOptionalString(request, "format", archive.FORMAT_ZIP)
 
 	user := this.FindUser(request)
 
@@ -359,14 +361,14 @@ func (this *ShareController) Zip(writer http.ResponseWriter, request *http.Reque
 			matterUuids = append(matterUuids, bridge.MatterUuid)
 		}
 		matters := this.matterDao.FindByUuids(matterUuids, nil)
-		this.matterService.DownloadZip(writer, request, matters)
+		this.matterService.DownloadArchive(writer, request, matters, format)
 
 	} else {
 
 		//download a folder.
 		matter := this.matterDao.CheckByUuid(puuid)
 		this.shareService.ValidateMatter(request, shareUuid, code, user, rootUuid, matter)
-		this.matterService.DownloadZip(writer, request, []*model.Matter{matter})
+		this.matterService.DownloadArchive(writer, request, []*model.Matter{matter}, format)
 	}
 
 	return nil
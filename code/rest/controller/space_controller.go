@@ -16,11 +16,17 @@ type SpaceController struct {
 	BaseController
 	spaceDao           *dao.SpaceDao
 	spaceMemberDao     *dao.SpaceMemberDao
+	tagDao             *dao.TagDao
+	albumDao           *dao.AlbumDao
+	favoriteDao        *dao.FavoriteDao
+	recentDao          *dao.RecentDao
+	scanReportDao      *dao.ScanReportDao
 	spaceMemberService *service.SpaceMemberService
 	matterDao          *dao.MatterDao
 	matterService      *service.MatterService
 	spaceService       *service.SpaceService
 	userService        *service.UserService
+	jobService         *service.JobService
 }
 
 func (this *SpaceController) Init() {
@@ -36,6 +42,31 @@ func (this *SpaceController) Init() {
 		this.spaceMemberDao = b
 	}
 
+	b = core.CONTEXT.GetBean(this.tagDao)
+	if b, ok := b.(*dao.TagDao); ok {
+		this.tagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.albumDao)
+	if b, ok := b.(*dao.AlbumDao); ok {
+		this.albumDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.favoriteDao)
+	if b, ok := b.(*dao.FavoriteDao); ok {
+		this.favoriteDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentDao)
+	if b, ok := b.(*dao.RecentDao); ok {
+		this.recentDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.scanReportDao)
+	if b, ok := b.(*dao.ScanReportDao); ok {
+		this.scanReportDao = b
+	}
+
 	b = core.CONTEXT.GetBean(this.spaceMemberService)
 	if b, ok := b.(*service.SpaceMemberService); ok {
 		this.spaceMemberService = b
@@ -61,6 +92,11 @@ func (this *SpaceController) Init() {
 		this.userService = b
 	}
 
+	b = core.CONTEXT.GetBean(this.jobService)
+	if b, ok := b.(*service.JobService); ok {
+		this.jobService = b
+	}
+
 }
 
 func (this *SpaceController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
@@ -72,6 +108,7 @@ func (this *SpaceController) RegisterRoutes() map[string]func(writer http.Respon
 	routeMap["/api/space/delete"] = this.Wrap(this.Delete, model.USER_ROLE_ADMINISTRATOR)
 	routeMap["/api/space/detail"] = this.Wrap(this.Detail, model.USER_ROLE_USER)
 	routeMap["/api/space/page"] = this.Wrap(this.Page, model.USER_ROLE_USER)
+	routeMap["/api/space/compute/size"] = this.Wrap(this.ComputeSize, model.USER_ROLE_USER)
 	return routeMap
 }
 
@@ -95,7 +132,11 @@ func (this *SpaceController) Edit(writer http.ResponseWriter, request *http.Requ
 	totalSizeLimit := util.ExtractRequestInt64(request, "totalSizeLimit")
 
 	user := this.CheckUser(request)
-	space := this.spaceService.Edit(request, user, uuid, sizeLimit, totalSizeLimit)
+	space := this.spaceDao.CheckByUuid(uuid)
+	versionLimit := util.ExtractRequestOptionalInt64(request, "versionLimit", space.VersionLimit)
+	versionKeepDays := util.ExtractRequestOptionalInt64(request, "versionKeepDays", space.VersionKeepDays)
+
+	space = this.spaceService.Edit(request, user, uuid, sizeLimit, totalSizeLimit, versionLimit, versionKeepDays)
 
 	return this.Success(space)
 }
@@ -114,6 +155,21 @@ func (this *SpaceController) Delete(writer http.ResponseWriter, request *http.Re
 
 	//TODO: when space has files, cannot delete.
 
+	//delete the tags.
+	this.tagDao.DeleteBySpaceUuid(space.Uuid)
+
+	//delete the albums.
+	this.albumDao.DeleteBySpaceUuid(space.Uuid)
+
+	//delete the favorites.
+	this.favoriteDao.DeleteBySpaceUuid(space.Uuid)
+
+	//delete the recent files.
+	this.recentDao.DeleteBySpaceUuid(space.Uuid)
+
+	//delete the scan reports.
+	this.scanReportDao.DeleteBySpaceUuid(space.Uuid)
+
 	//delete the space.
 	this.spaceDao.Delete(space)
 
@@ -164,3 +220,16 @@ func (this *SpaceController) Page(writer http.ResponseWriter, request *http.Requ
 
 	return this.Success(pager)
 }
+
+// recompute the sizes of all the directories in a job. return the job.
+func (this *SpaceController) ComputeSize(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	space := this.spaceService.CheckAdminAbleByUuid(request, user, uuid)
+
+	job := this.jobService.Submit(user, space.Uuid, model.JOB_TYPE_COMPUTE_DIR_SIZE, &model.JobParams{})
+
+	return this.Success(job)
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"net/http"
+	"strings"
+)
+
+type TagController struct {
+	BaseController
+	tagDao        *dao.TagDao
+	matterDao     *dao.MatterDao
+	tagService    *service.TagService
+	matterService *service.MatterService
+}
+
+func (this *TagController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.tagDao)
+	if b, ok := b.(*dao.TagDao); ok {
+		this.tagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.tagService)
+	if b, ok := b.(*service.TagService); ok {
+		this.tagService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*service.MatterService); ok {
+		this.matterService = b
+	}
+
+}
+
+func (this *TagController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	routeMap["/api/tag/list"] = this.Wrap(this.List, model.USER_ROLE_USER)
+	routeMap["/api/tag/create"] = this.Wrap(this.Create, model.USER_ROLE_USER)
+	routeMap["/api/tag/rename"] = this.Wrap(this.Rename, model.USER_ROLE_USER)
+	routeMap["/api/tag/delete"] = this.Wrap(this.Delete, model.USER_ROLE_USER)
+	routeMap["/api/tag/add"] = this.Wrap(this.Add, model.USER_ROLE_USER)
+	routeMap["/api/tag/remove"] = this.Wrap(this.Remove, model.USER_ROLE_USER)
+	routeMap["/api/tag/matter/page"] = this.Wrap(this.MatterPage, model.USER_ROLE_USER)
+
+	return routeMap
+}
+
+// the tags of uuids in the space.
+func (this *TagController) checkTags(uuids string, space *model.Space) []*model.Tag {
+
+	tags := make([]*model.Tag, 0)
+	for _, uuid := range strings.Split(uuids, ",") {
+
+		tag := this.tagDao.CheckByUuid(uuid)
+		if tag.SpaceUuid != space.Uuid {
+			panic(result.UNAUTHORIZED)
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// list the tags of a space. if matterUuid given, list the tags of the matter.
+func (this *TagController) List(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuid := util.ExtractRequestOptionalString(request, "matterUuid", "")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+
+	if matterUuid != "" {
+		matter := this.matterDao.CheckByUuid(matterUuid)
+		if matter.SpaceUuid != space.Uuid {
+			panic(result.UNAUTHORIZED)
+		}
+		return this.Success(this.tagDao.ListByMatterUuid(matter.Uuid))
+	}
+
+	tags := this.tagService.List(request, space)
+
+	return this.Success(tags)
+}
+
+func (this *TagController) Create(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	name := util.ExtractRequestString(request, "name")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	tag := this.tagService.Create(request, user, space, name)
+
+	return this.Success(tag)
+}
+
+func (this *TagController) Rename(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+	name := util.ExtractRequestString(request, "name")
+
+	user := this.CheckUser(request)
+	tag := this.tagDao.CheckByUuid(uuid)
+	this.spaceService.CheckWritableByUuid(request, user, tag.SpaceUuid)
+
+	tag = this.tagService.Rename(request, tag, name)
+
+	return this.Success(tag)
+}
+
+// delete a tag from the vocabulary. matters will lose the tag.
+func (this *TagController) Delete(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	uuid := util.ExtractRequestString(request, "uuid")
+
+	user := this.CheckUser(request)
+	tag := this.tagDao.CheckByUuid(uuid)
+	this.spaceService.CheckWritableByUuid(request, user, tag.SpaceUuid)
+
+	this.tagService.Delete(request, tag)
+
+	return this.Success("OK")
+}
+
+// add tags to matters in bulk. names are separated by ','.
+func (this *TagController) Add(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuids := util.ExtractRequestString(request, "matterUuids")
+	names := util.ExtractRequestString(request, "names")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	matters := this.matterService.CheckByUuidsInSpace(strings.Split(matterUuids, ","), space)
+
+	tags := this.tagService.Add(request, user, space, matters, strings.Split(names, ","))
+
+	return this.Success(tags)
+}
+
+// remove tags from matters in bulk.
+func (this *TagController) Remove(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	matterUuids := util.ExtractRequestString(request, "matterUuids")
+	tagUuids := util.ExtractRequestString(request, "tagUuids")
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	matters := this.matterService.CheckByUuidsInSpace(strings.Split(matterUuids, ","), space)
+	tags := this.checkTags(tagUuids, space)
+
+	this.tagService.Remove(request, space, matters, tags)
+
+	return this.Success("OK")
+}
+
+// page the matters with all (mode=and) or any (mode=or) of the tags.
+func (this *TagController) MatterPage(writer http.ResponseWriter, request *http.Request) *result.WebResult {
+
+	page := util.ExtractRequestOptionalInt(request, "page", 0)
+	pageSize := util.ExtractRequestOptionalInt(request, "pageSize", 200)
+	orderCreateTime := util.ExtractRequestOptionalString(request, "orderCreateTime", "")
+	orderUpdateTime := util.ExtractRequestOptionalString(request, "orderUpdateTime", "")
+	orderDir := util.ExtractRequestOptionalString(request, "orderDir", "")
+	orderSize := util.ExtractRequestOptionalString(request, "orderSize", "")
+	orderName := util.ExtractRequestOptionalString(request, "orderName", "")
+
+	tagUuids := util.ExtractRequestString(request, "tagUuids")
+	mode := util.ExtractRequestOptionalString(request, "mode", model.TAG_MODE_OR)
+	deleted := util.ExtractRequestOptionalString(request, "deleted", model.FALSE)
+
+	user := this.CheckUser(request)
+	spaceUuid := util.ExtractRequestOptionalString(request, "spaceUuid", user.SpaceUuid)
+	space := this.spaceService.CheckReadableByUuid(request, user, spaceUuid)
+
+	tags := this.checkTags(tagUuids, space)
+
+	sortArray := []builder.OrderPair{
+		{Key: "dir", Value: orderDir},
+		{Key: "create_time", Value: orderCreateTime},
+		{Key: "update_time", Value: orderUpdateTime},
+		{Key: "size", Value: orderSize},
+		{Key: "name", Value: orderName},
+	}
+
+	pager := this.tagService.PageMatters(request, page, pageSize, space, tags, mode, deleted, sortArray)
+
+	return this.Success(pager)
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/rest/service"
+	"box/code/tool/i18n"
+	"box/code/tool/result"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	TUS_VERSION   = "1.0.0"
+	TUS_EXTENSION = "creation,termination,expiration"
+	TUS_PREFIX    = "/api/tus"
+)
+
+/**
+ * resumable upload with tus 1.0 protocol.
+ * https://tus.io/protocols/resumable-upload
+ */
+type TusController struct {
+	BaseController
+	tusService *service.TusService
+}
+
+func (this *TusController) Init() {
+	this.BaseController.Init()
+
+	b := core.CONTEXT.GetBean(this.tusService)
+	if b, ok := b.(*service.TusService); ok {
+		this.tusService = b
+	}
+}
+
+func (this *TusController) RegisterRoutes() map[string]func(writer http.ResponseWriter, request *http.Request) {
+
+	routeMap := make(map[string]func(writer http.ResponseWriter, request *http.Request))
+
+	//guest can upload with uploadTokenUuid in metadata.
+	routeMap[TUS_PREFIX] = this.WrapPure(this.Create, model.USER_ROLE_GUEST)
+
+	return routeMap
+}
+
+// handle some special routes, eg. params in the url.
+func (this *TusController) HandleRoutes(writer http.ResponseWriter, request *http.Request) (func(writer http.ResponseWriter, request *http.Request), bool) {
+
+	path := request.URL.Path
+
+	//match /api/tus/{uuid}
+	reg := regexp.MustCompile(`^` + TUS_PREFIX + `/([^/]+)$`)
+	strs := reg.FindStringSubmatch(path)
+	if len(strs) == 2 {
+		var f = func(writer http.ResponseWriter, request *http.Request) {
+			this.Upload(writer, request, strs[1])
+		}
+		return f, true
+	}
+
+	return nil, false
+}
+
+// common headers of tus. false means the request has been responded.
+func (this *TusController) prepare(writer http.ResponseWriter, request *http.Request) bool {
+
+	//browser clients need to read the tus headers.
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.Header().Set("Access-Control-Allow-Methods", "POST, HEAD, PATCH, OPTIONS, DELETE")
+	writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Upload-Length, Upload-Offset, Upload-Metadata, Tus-Resumable, X-HTTP-Method-Override")
+	writer.Header().Set("Access-Control-Expose-Headers", "Location, Upload-Length, Upload-Offset, Upload-Expires, Tus-Resumable, Tus-Version, Tus-Extension, Tank-Matter-Uuid")
+	writer.Header().Set("Tus-Resumable", TUS_VERSION)
+
+	//some environments only allow GET and POST.
+	if method := request.Header.Get("X-HTTP-Method-Override"); method != "" {
+		request.Method = strings.ToUpper(method)
+	}
+
+	if request.Method == http.MethodOptions {
+		writer.Header().Set("Tus-Version", TUS_VERSION)
+		writer.Header().Set("Tus-Extension", TUS_EXTENSION)
+		writer.WriteHeader(http.StatusNoContent)
+		return false
+	}
+
+	if request.Header.Get("Tus-Resumable") != TUS_VERSION {
+		writer.Header().Set("Tus-Version", TUS_VERSION)
+		panic(result.StatusCodeWebResult(http.StatusPreconditionFailed, fmt.Sprintf("only tus %s is supported", TUS_VERSION)))
+	}
+
+	return true
+}
+
+// create an upload. (creation extension)
+func (this *TusController) Create(writer http.ResponseWriter, request *http.Request) {
+
+	if !this.prepare(writer, request) {
+		return
+	}
+	if request.Method != http.MethodPost {
+		panic(result.ConstWebResult(result.METHOD_NOT_ALLOWED))
+	}
+
+	if request.Header.Get("Upload-Defer-Length") != "" {
+		panic(result.BadRequest("Upload-Defer-Length is not supported"))
+	}
+	uploadLength := this.parseHeaderInt(request, "Upload-Length")
+	metadata := this.parseMetadata(request.Header.Get("Upload-Metadata"))
+
+	var uploadToken *model.UploadToken
+	if uploadTokenUuid := metadata["uploadTokenUuid"]; uploadTokenUuid != "" {
+		uploadToken = this.tusService.CreateWithToken(request, uploadTokenUuid, uploadLength, metadata)
+	} else {
+		user := this.CheckUser(request)
+		if user.Status == model.USER_STATUS_DISABLED {
+			panic(result.CustomWebResultI18n(request, result.USER_DISABLED, i18n.UserDisabled))
+		}
+		uploadToken = this.tusService.Create(request, user, uploadLength, metadata)
+	}
+
+	writer.Header().Set("Location", TUS_PREFIX+"/"+uploadToken.Uuid)
+	this.writeUploadHeaders(writer, uploadToken)
+	writer.WriteHeader(http.StatusCreated)
+}
+
+// HEAD PATCH DELETE of an upload. the uuid of the upload is the credential.
+func (this *TusController) Upload(writer http.ResponseWriter, request *http.Request, uuid string) {
+
+	if !this.prepare(writer, request) {
+		return
+	}
+
+	uploadToken := this.tusService.CheckUpload(uuid)
+
+	switch request.Method {
+	case http.MethodHead:
+		writer.Header().Set("Cache-Control", "no-store")
+		this.writeUploadHeaders(writer, uploadToken)
+		writer.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		if request.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			panic(result.ConstWebResult(result.UNSUPPORTED_MEDIA_TYPE))
+		}
+		offset := this.parseHeaderInt(request, "Upload-Offset")
+		uploadToken = this.tusService.Patch(request, uploadToken, offset, request.Body)
+		this.writeUploadHeaders(writer, uploadToken)
+		writer.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		this.tusService.Terminate(request, uploadToken)
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		panic(result.ConstWebResult(result.METHOD_NOT_ALLOWED))
+	}
+}
+
+func (this *TusController) writeUploadHeaders(writer http.ResponseWriter, uploadToken *model.UploadToken) {
+	writer.Header().Set("Upload-Offset", strconv.FormatInt(uploadToken.Offset, 10))
+	writer.Header().Set("Upload-Length", strconv.FormatInt(uploadToken.Size, 10))
+	if uploadToken.MatterUuid != "" {
+		writer.Header().Set("Tank-Matter-Uuid", uploadToken.MatterUuid)
+	} else {
+		writer.Header().Set("Upload-Expires", uploadToken.ExpireTime.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (this *TusController) parseHeaderInt(request *http.Request, name string) int64 {
+	value, err := strconv.ParseInt(request.Header.Get(name), 10, 64)
+	if err != nil || value < 0 {
+		panic(result.BadRequest("%s is not a valid number", name))
+	}
+	return value
+}
+
+// Upload-Metadata: key base64(value),key2 base64(value2)
+func (this *TusController) parseMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+		value := ""
+		if len(fields) > 1 {
+			bytes, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				panic(result.BadRequest("Upload-Metadata of %s is not base64 encoded", fields[0]))
+			}
+			value = string(bytes)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata
+}
@@ -21,7 +21,7 @@ type UserController struct {
 	userService       *service.UserService
 	spaceDao          *dao.SpaceDao
 	spaceService      *service.SpaceService
-	matterService     *service.MatterService
+	scanService       *service.ScanService
 }
 
 func (this *UserController) Init() {
@@ -45,9 +45,9 @@ func (this *UserController) Init() {
 	if b, ok := b.(*service.SpaceService); ok {
 		this.spaceService = b
 	}
-	b = core.CONTEXT.GetBean(this.matterService)
-	if b, ok := b.(*service.MatterService); ok {
-		this.matterService = b
+	b = core.CONTEXT.GetBean(this.scanService)
+	if b, ok := b.(*service.ScanService); ok {
+		this.scanService = b
 	}
 
 }
@@ -276,7 +276,8 @@ func (this *UserController) Edit(writer http.ResponseWriter, request *http.Reque
 	currentUser = this.userDao.Save(currentUser)
 
 	//edit user's private space info.
-	space := this.spaceService.Edit(request, operator, currentUser.SpaceUuid, sizeLimit, totalSizeLimit)
+	space := this.spaceDao.CheckByUuid(currentUser.SpaceUuid)
+	space = this.spaceService.Edit(request, operator, currentUser.SpaceUuid, sizeLimit, totalSizeLimit, space.VersionLimit, space.VersionKeepDays)
 
 	//remove cache user.
 	this.userService.RemoveCacheUserByUuid(currentUser.Uuid)
@@ -457,10 +458,11 @@ func (this *UserController) Scan(writer http.ResponseWriter, request *http.Reque
 	uuid := request.FormValue("uuid")
 	currentUser := this.userDao.CheckByUuid(uuid)
 	space := this.spaceDao.CheckByUuid(currentUser.SpaceUuid)
-	this.matterService.DeleteByPhysics(request, currentUser, space)
-	this.matterService.ScanPhysics(request, currentUser, space)
+	full := util.ExtractRequestOptionalBool(request, "full", false)
+	dryRun := util.ExtractRequestOptionalBool(request, "dryRun", false)
+	report := this.scanService.Scan(request, currentUser, space, full, dryRun)
 
-	return this.Success("OK")
+	return this.Success(report)
 }
 
 func (this *UserController) Delete(writer http.ResponseWriter, request *http.Request) *result.WebResult {
@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type AlbumDao struct {
+	BaseDao
+	albumMatterDao *AlbumMatterDao
+}
+
+func (this *AlbumDao) Init() {
+	this.BaseDao.Init()
+
+	b := core.CONTEXT.GetBean(this.albumMatterDao)
+	if b, ok := b.(*AlbumMatterDao); ok {
+		this.albumMatterDao = b
+	}
+}
+
+// find by uuid. if not found return nil.
+func (this *AlbumDao) FindByUuid(uuid string) *model.Album {
+	var entity = &model.Album{}
+	db := core.CONTEXT.GetDB().Where("uuid = ?", uuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// find by uuid. if not found panic NotFound error
+func (this *AlbumDao) CheckByUuid(uuid string) *model.Album {
+	entity := this.FindByUuid(uuid)
+	if entity == nil {
+		panic(result.NotFound("not found record with uuid = %s", uuid))
+	}
+	return entity
+}
+
+// list all the albums of a space, ordered by name.
+func (this *AlbumDao) ListBySpaceUuid(spaceUuid string) []*model.Album {
+	var albums = []*model.Album{}
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Order("name asc").Find(&albums)
+	this.PanicError(db.Error)
+	return albums
+}
+
+// list the albums containing a matter, ordered by name.
+func (this *AlbumDao) ListByMatterUuid(matterUuid string) []*model.Album {
+	var albums = []*model.Album{}
+	subQuery := core.CONTEXT.GetDB().Model(&model.AlbumMatter{}).Select("album_uuid").Where("matter_uuid = ?", matterUuid)
+	db := core.CONTEXT.GetDB().Where("uuid IN (?)", subQuery).Order("name asc").Find(&albums)
+	this.PanicError(db.Error)
+	return albums
+}
+
+func (this *AlbumDao) Create(album *model.Album) *model.Album {
+
+	timeUUID, _ := uuid.NewV4()
+	album.Uuid = string(timeUUID.String())
+	album.CreateTime = time.Now()
+	album.UpdateTime = time.Now()
+	album.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(album)
+	this.PanicError(db.Error)
+
+	return album
+}
+
+func (this *AlbumDao) Save(album *model.Album) *model.Album {
+
+	album.UpdateTime = time.Now()
+	db := core.CONTEXT.GetDB().Save(album)
+	this.PanicError(db.Error)
+
+	return album
+}
+
+// delete an album. the matters stay where they are.
+func (this *AlbumDao) Delete(album *model.Album) {
+
+	this.albumMatterDao.DeleteByAlbumUuid(album.Uuid)
+
+	db := core.CONTEXT.GetDB().Delete(&album)
+	this.PanicError(db.Error)
+}
+
+func (this *AlbumDao) DeleteBySpaceUuid(spaceUuid string) {
+
+	this.albumMatterDao.DeleteBySpaceUuid(spaceUuid)
+
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.Album{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *AlbumDao) Cleanup() {
+	this.Logger.Info("[AlbumDao] clean up. Delete all Album")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Album{})
+	this.PanicError(db.Error)
+}
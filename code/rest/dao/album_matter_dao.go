@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type AlbumMatterDao struct {
+	BaseDao
+}
+
+// find by albumUuid and matterUuid. if not found return nil.
+func (this *AlbumMatterDao) FindByAlbumUuidAndMatterUuid(albumUuid string, matterUuid string) *model.AlbumMatter {
+	var entity = &model.AlbumMatter{}
+	db := core.CONTEXT.GetDB().Where("album_uuid = ? AND matter_uuid = ?", albumUuid, matterUuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// count the undeleted matters of each album in a space. albumUuid -> count
+func (this *AlbumMatterDao) CountBySpaceUuid(spaceUuid string) map[string]int64 {
+
+	type albumCount struct {
+		AlbumUuid string
+		Count     int64
+	}
+	var albumCounts []*albumCount
+	db := core.CONTEXT.GetDB().Model(&model.AlbumMatter{}).
+		Select("album_uuid, COUNT(*) AS count").
+		Where("space_uuid = ? AND matter_uuid IN (?)", spaceUuid, core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("deleted = ?", false)).
+		Group("album_uuid").
+		Scan(&albumCounts)
+	this.PanicError(db.Error)
+
+	countMap := make(map[string]int64)
+	for _, item := range albumCounts {
+		countMap[item.AlbumUuid] = item.Count
+	}
+	return countMap
+}
+
+func (this *AlbumMatterDao) Create(albumMatter *model.AlbumMatter) *model.AlbumMatter {
+
+	timeUUID, _ := uuid.NewV4()
+	albumMatter.Uuid = string(timeUUID.String())
+	albumMatter.CreateTime = time.Now()
+	albumMatter.UpdateTime = time.Now()
+	albumMatter.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(albumMatter)
+	this.PanicError(db.Error)
+
+	return albumMatter
+}
+
+func (this *AlbumMatterDao) DeleteByAlbumUuidAndMatterUuid(albumUuid string, matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("album_uuid = ? AND matter_uuid = ?", albumUuid, matterUuid).Delete(model.AlbumMatter{})
+	this.PanicError(db.Error)
+}
+
+func (this *AlbumMatterDao) DeleteByAlbumUuid(albumUuid string) {
+	db := core.CONTEXT.GetDB().Where("album_uuid = ?", albumUuid).Delete(model.AlbumMatter{})
+	this.PanicError(db.Error)
+}
+
+func (this *AlbumMatterDao) DeleteByMatterUuid(matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Delete(model.AlbumMatter{})
+	this.PanicError(db.Error)
+}
+
+func (this *AlbumMatterDao) DeleteBySpaceUuid(spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.AlbumMatter{})
+	this.PanicError(db.Error)
+}
+
+// delete the album items of all the user's matters.
+func (this *AlbumMatterDao) DeleteByMatterUserUuid(userUuid string) {
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("user_uuid = ?", userUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", subQuery).Delete(model.AlbumMatter{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *AlbumMatterDao) Cleanup() {
+	this.Logger.Info("[AlbumMatterDao] clean up. Delete all AlbumMatter")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.AlbumMatter{})
+	this.PanicError(db.Error)
+}
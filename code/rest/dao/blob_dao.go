@@ -0,0 +1,164 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/storage"
+	"box/code/tool/uuid"
+	"gorm.io/gorm"
+	"path"
+	"sync"
+	"time"
+)
+
+type BlobDao struct {
+	BaseDao
+	//guard the reference count and the file on disk.
+	lock sync.Mutex
+}
+
+// find by uuid. if not found return nil.
+func (this *BlobDao) FindByUuid(uuid string) *model.Blob {
+	var entity = &model.Blob{}
+	db := core.CONTEXT.GetDB().Where("uuid = ?", uuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// find by uuid. if not found panic NotFound error
+func (this *BlobDao) CheckByUuid(uuid string) *model.Blob {
+	entity := this.FindByUuid(uuid)
+	if entity == nil {
+		panic(result.NotFound("not found record with uuid = %s", uuid))
+	}
+	return entity
+}
+
+// find by md5. if not found return nil.
+func (this *BlobDao) FindByMd5(md5 string) *model.Blob {
+	var entity = &model.Blob{}
+	db := core.CONTEXT.GetDB().Where("md5 = ?", md5).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+func (this *BlobDao) Create(blob *model.Blob) *model.Blob {
+
+	timeUUID, _ := uuid.NewV4()
+	blob.Uuid = string(timeUUID.String())
+	blob.CreateTime = time.Now()
+	blob.UpdateTime = time.Now()
+	blob.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(blob)
+	this.PanicError(db.Error)
+
+	return blob
+}
+
+// commit a hashed temp file. if the same content exists, reuse it and delete the temp file.
+// otherwise move the temp file into the blob directory. reference count of the returned blob has been increased.
+func (this *BlobDao) Commit(md5 string, size int64, tempPath string) *model.Blob {
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	driver := core.CONTEXT.GetStorage()
+
+	blob := this.FindByMd5(md5)
+	if blob != nil && storage.Exists(driver, blob.StoragePath()) {
+
+		this.increaseRefCount(blob.Uuid, 1)
+		blob.RefCount = blob.RefCount + 1
+
+		err := driver.Remove(tempPath)
+		if err != nil {
+			this.Logger.Error("occur error when deleting temp blob. %v", err)
+		}
+
+		return blob
+	}
+
+	relativePath := model.GetBlobRelativePath(md5)
+	err := driver.Rename(tempPath, model.GetBlobStoragePath(md5))
+	this.PanicError(err)
+
+	if blob != nil {
+		//the record exists but the file lost. recover it with the new content.
+		this.Logger.Error("blob %s lost on disk. recover it.", md5)
+		this.increaseRefCount(blob.Uuid, 1)
+		blob.RefCount = blob.RefCount + 1
+		return blob
+	}
+
+	blob = &model.Blob{
+		Md5:      md5,
+		Size:     size,
+		RefCount: 1,
+		Path:     relativePath,
+	}
+
+	return this.Create(blob)
+}
+
+// one more matter refers to this blob.
+func (this *BlobDao) Retain(blobUuid string) {
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.increaseRefCount(blobUuid, 1)
+}
+
+// one matter no longer refers to this blob. when nobody refers to it, delete it from db and disk.
+func (this *BlobDao) Release(blobUuid string) {
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.increaseRefCount(blobUuid, -1)
+
+	blob := this.FindByUuid(blobUuid)
+	if blob == nil || blob.RefCount > 0 {
+		return
+	}
+
+	db := core.CONTEXT.GetDB().Delete(blob)
+	this.PanicError(db.Error)
+
+	driver := core.CONTEXT.GetStorage()
+	err := driver.Remove(blob.StoragePath())
+	if err != nil {
+		this.Logger.Error("occur error when deleting blob. %v", err)
+	}
+
+	//delete the two levels of directories if empty.
+	dirPath := path.Dir(blob.StoragePath())
+	if storage.RemoveEmptyDir(driver, dirPath) {
+		storage.RemoveEmptyDir(driver, path.Dir(dirPath))
+	}
+}
+
+func (this *BlobDao) increaseRefCount(blobUuid string, delta int) {
+	db := core.CONTEXT.GetDB().Model(&model.Blob{}).Where("uuid = ?", blobUuid).Updates(map[string]interface{}{"ref_count": gorm.Expr("ref_count + ?", delta), "update_time": time.Now()})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *BlobDao) Cleanup() {
+	this.Logger.Info("[BlobDao] clean up. Delete all Blob record in db.")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Blob{})
+	this.PanicError(db.Error)
+}
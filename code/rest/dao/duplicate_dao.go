@@ -0,0 +1,60 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"fmt"
+)
+
+// queries of the files sharing the same content hash.
+type DuplicateDao struct {
+	BaseDao
+}
+
+func (this *DuplicateDao) from() string {
+	return fmt.Sprintf("`%smatter`", core.TABLE_PREFIX)
+}
+
+// undeleted files with content hash. spaceUuid empty means all the spaces.
+func (this *DuplicateDao) where(spaceUuid string) *builder.WherePair {
+
+	wp := &builder.WherePair{Query: "dir = ? AND deleted = ? AND md5 IS NOT NULL AND md5 != ''", Args: []interface{}{0, 0}}
+	if spaceUuid != "" {
+		wp = wp.And(&builder.WherePair{Query: "space_uuid = ?", Args: []interface{}{spaceUuid}})
+	}
+	return wp
+}
+
+// page the groups of duplicate files not smaller than minSize. most wasted first.
+func (this *DuplicateDao) PageGroups(page int, pageSize int, spaceUuid string, minSize int64) (int, []*model.DuplicateGroup) {
+
+	wp := this.where(spaceUuid).And(&builder.WherePair{Query: "size >= ?", Args: []interface{}{minSize}})
+	groupBy := "GROUP BY md5 HAVING COUNT(*) > 1"
+
+	var count int64
+	db := core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT COUNT(*) FROM (SELECT md5 FROM %s WHERE %s %s) t", this.from(), wp.Query, groupBy), wp.Args...).Scan(&count)
+	this.PanicError(db.Error)
+
+	groups := []*model.DuplicateGroup{}
+	queryArgs := append(append([]interface{}{}, wp.Args...), pageSize, page*pageSize)
+	db = core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT md5, MAX(size) AS size, COUNT(*) AS count, MAX(size) * (COUNT(*) - 1) AS wasted_size FROM %s WHERE %s %s ORDER BY wasted_size DESC, md5 ASC LIMIT ? OFFSET ?", this.from(), wp.Query, groupBy), queryArgs...).Scan(&groups)
+	this.PanicError(db.Error)
+
+	return int(count), groups
+}
+
+// the copies of the contents. oldest first.
+func (this *DuplicateDao) ListByMd5s(md5s []string, spaceUuid string) []*model.Matter {
+
+	var matters []*model.Matter
+	if len(md5s) == 0 {
+		return matters
+	}
+
+	wp := this.where(spaceUuid).And(&builder.WherePair{Query: "md5 IN (?)", Args: []interface{}{md5s}})
+	db := core.CONTEXT.GetDB().Where(wp.Query, wp.Args...).Order("create_time ASC").Find(&matters)
+	this.PanicError(db.Error)
+
+	return matters
+}
@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type FavoriteDao struct {
+	BaseDao
+}
+
+// find by userUuid and matterUuid. if not found return nil.
+func (this *FavoriteDao) FindByUserUuidAndMatterUuid(userUuid string, matterUuid string) *model.Favorite {
+	var entity = &model.Favorite{}
+	db := core.CONTEXT.GetDB().Where("user_uuid = ? AND matter_uuid = ?", userUuid, matterUuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// get pager of a user's favorites. the matters in recycle bin are excluded.
+func (this *FavoriteDao) PlainPage(page int, pageSize int, user *model.User, spaceUuid string, sortArray []builder.OrderPair) (int, []*model.Favorite) {
+
+	var wp = &builder.WherePair{Query: "user_uuid = ?", Args: []interface{}{user.Uuid}}
+
+	//the matters may be moved into a space the user cannot read.
+	if readableWp := whereReadableSpace(user); readableWp != nil {
+		wp = wp.And(readableWp)
+	}
+
+	if spaceUuid != "" {
+		wp = wp.And(&builder.WherePair{Query: "space_uuid = ?", Args: []interface{}{spaceUuid}})
+	}
+
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("deleted = ?", false)
+	conditionDB := core.CONTEXT.GetDB().Model(&model.Favorite{}).Where(wp.Query, wp.Args...).Where("matter_uuid IN (?)", subQuery)
+
+	var count int64 = 0
+	db := conditionDB.Count(&count)
+	this.PanicError(db.Error)
+
+	var favorites []*model.Favorite
+	db = conditionDB.Order(this.GetSortString(sortArray)).Offset(page * pageSize).Limit(pageSize).Find(&favorites)
+	this.PanicError(db.Error)
+
+	return int(count), favorites
+}
+
+func (this *FavoriteDao) Create(favorite *model.Favorite) *model.Favorite {
+
+	timeUUID, _ := uuid.NewV4()
+	favorite.Uuid = string(timeUUID.String())
+	favorite.CreateTime = time.Now()
+	favorite.UpdateTime = time.Now()
+	favorite.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(favorite)
+	this.PanicError(db.Error)
+
+	return favorite
+}
+
+func (this *FavoriteDao) Delete(favorite *model.Favorite) {
+	db := core.CONTEXT.GetDB().Delete(&favorite)
+	this.PanicError(db.Error)
+}
+
+func (this *FavoriteDao) DeleteByMatterUuid(matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
+
+// the matter is moved into another space.
+func (this *FavoriteDao) UpdateSpaceUuidByMatterUuid(matterUuid string, spaceUuid string) {
+	db := core.CONTEXT.GetDB().Model(&model.Favorite{}).Where("matter_uuid = ?", matterUuid).Update("space_uuid", spaceUuid)
+	this.PanicError(db.Error)
+}
+
+// the matter is moved into another space. the users who cannot read that space lose it.
+func (this *FavoriteDao) DeleteByMatterUuidAndNotSpaceReader(matterUuid string, spaceUuid string) {
+	wp := whereSpaceReader(spaceUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Where("NOT "+wp.Query, wp.Args...).Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
+
+func (this *FavoriteDao) DeleteByUserUuid(userUuid string) {
+	db := core.CONTEXT.GetDB().Where("user_uuid = ?", userUuid).Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
+
+// the user cannot read the space anymore.
+func (this *FavoriteDao) DeleteByUserUuidAndSpaceUuid(userUuid string, spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("user_uuid = ? AND space_uuid = ?", userUuid, spaceUuid).Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
+
+func (this *FavoriteDao) DeleteBySpaceUuid(spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
+
+// delete the favorites of all the user's matters.
+func (this *FavoriteDao) DeleteByMatterUserUuid(userUuid string) {
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("user_uuid = ?", userUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", subQuery).Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *FavoriteDao) Cleanup() {
+	this.Logger.Info("[FavoriteDao] clean up. Delete all Favorite")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Favorite{})
+	this.PanicError(db.Error)
+}
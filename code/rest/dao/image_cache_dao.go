@@ -64,6 +64,13 @@ func (this *ImageCacheDao) FindByMatterUuidAndMode(matterUuid string, mode strin
 	return imageCache
 }
 
+func (this *ImageCacheDao) FindByMatterUuid(matterUuid string) []*model.ImageCache {
+	var imageCaches []*model.ImageCache
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Find(&imageCaches)
+	this.PanicError(db.Error)
+	return imageCaches
+}
+
 func (this *ImageCacheDao) CheckByUuidAndUserUuid(uuid string, userUuid string) *model.ImageCache {
 
 	// Read
@@ -121,6 +128,7 @@ func (this *ImageCacheDao) Create(imageCache *model.ImageCache) *model.ImageCach
 	imageCache.CreateTime = time.Now()
 	imageCache.UpdateTime = time.Now()
 	imageCache.Sort = time.Now().UnixNano() / 1e6
+	imageCache.VisitTime = time.Now()
 	db := core.CONTEXT.GetDB().Create(imageCache)
 	this.PanicError(db.Error)
 
@@ -136,6 +144,65 @@ func (this *ImageCacheDao) Save(imageCache *model.ImageCache) *model.ImageCache
 	return imageCache
 }
 
+// the cache is served.
+func (this *ImageCacheDao) Touch(uuid string) {
+	db := core.CONTEXT.GetDB().Model(&model.ImageCache{}).Where("uuid = ?", uuid).UpdateColumn("visit_time", time.Now())
+	this.PanicError(db.Error)
+}
+
+// total bytes of the caches. spaceUuid empty means all.
+func (this *ImageCacheDao) SumSize(spaceUuid string) int64 {
+	var size int64
+	conditionDB := core.CONTEXT.GetDB().Model(&model.ImageCache{})
+	if spaceUuid != "" {
+		conditionDB = conditionDB.Where("space_uuid = ?", spaceUuid)
+	}
+	db := conditionDB.Select("COALESCE(SUM(size), 0)").Scan(&size)
+	this.PanicError(db.Error)
+	return size
+}
+
+// total bytes of the caches of each space. spaceUuid -> bytes
+func (this *ImageCacheDao) SumSizeGroupBySpaceUuid() map[string]int64 {
+
+	type spaceSize struct {
+		SpaceUuid string
+		Size      int64
+	}
+	var spaceSizes []*spaceSize
+	db := core.CONTEXT.GetDB().Model(&model.ImageCache{}).
+		Select("space_uuid, SUM(size) AS size").
+		Where("space_uuid IS NOT NULL AND space_uuid != ''").
+		Group("space_uuid").
+		Scan(&spaceSizes)
+	this.PanicError(db.Error)
+
+	sizeMap := make(map[string]int64)
+	for _, item := range spaceSizes {
+		sizeMap[item.SpaceUuid] = item.Size
+	}
+	return sizeMap
+}
+
+// the least recently served caches. spaceUuid empty means all.
+func (this *ImageCacheDao) FindLeastVisited(spaceUuid string, limit int) []*model.ImageCache {
+	var imageCaches []*model.ImageCache
+	conditionDB := core.CONTEXT.GetDB().Model(&model.ImageCache{})
+	if spaceUuid != "" {
+		conditionDB = conditionDB.Where("space_uuid = ?", spaceUuid)
+	}
+	db := conditionDB.Order("visit_time ASC").Limit(limit).Find(&imageCaches)
+	this.PanicError(db.Error)
+	return imageCaches
+}
+
+func (this *ImageCacheDao) Count() int64 {
+	var count int64
+	db := core.CONTEXT.GetDB().Model(&model.ImageCache{}).Count(&count)
+	this.PanicError(db.Error)
+	return count
+}
+
 func (this *ImageCacheDao) deleteFileAndDir(imageCache *model.ImageCache) {
 
 	filePath := model.GetSpaceCacheRootDir(imageCache.Username) + imageCache.Path
@@ -0,0 +1,143 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type JobDao struct {
+	BaseDao
+}
+
+// find by uuid. if not found return nil.
+func (this *JobDao) FindByUuid(uuid string) *model.Job {
+	var entity = &model.Job{}
+	db := core.CONTEXT.GetDB().Where("uuid = ?", uuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// find by uuid. if not found panic NotFound error
+func (this *JobDao) CheckByUuid(uuid string) *model.Job {
+	entity := this.FindByUuid(uuid)
+	if entity == nil {
+		panic(result.NotFound("not found record with uuid = %s", uuid))
+	}
+	return entity
+}
+
+func (this *JobDao) Page(page int, pageSize int, userUuid string, jobType string, status string, sortArray []builder.OrderPair) *model.Pager {
+
+	var wp = &builder.WherePair{}
+
+	if userUuid != "" {
+		wp = wp.And(&builder.WherePair{Query: "user_uuid = ?", Args: []interface{}{userUuid}})
+	}
+
+	if jobType != "" {
+		wp = wp.And(&builder.WherePair{Query: "type = ?", Args: []interface{}{jobType}})
+	}
+
+	if status != "" {
+		wp = wp.And(&builder.WherePair{Query: "status = ?", Args: []interface{}{status}})
+	}
+
+	var conditionDB *gorm.DB
+	conditionDB = core.CONTEXT.GetDB().Model(&model.Job{}).Where(wp.Query, wp.Args...)
+
+	var count int64 = 0
+	db := conditionDB.Count(&count)
+	this.PanicError(db.Error)
+
+	var jobs []*model.Job
+	db = conditionDB.Order(this.GetSortString(sortArray)).Offset(page * pageSize).Limit(pageSize).Find(&jobs)
+	this.PanicError(db.Error)
+
+	return model.NewPager(page, pageSize, int(count), jobs)
+}
+
+// the earliest queued job. nil if there is none.
+func (this *JobDao) FindFirstQueued() *model.Job {
+	var entity = &model.Job{}
+	db := core.CONTEXT.GetDB().Where("status = ?", model.JOB_STATUS_QUEUED).Order("sort ASC").First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+func (this *JobDao) FindByStatus(status string) []*model.Job {
+	var jobs []*model.Job
+	db := core.CONTEXT.GetDB().Where("status = ?", status).Order("sort ASC").Find(&jobs)
+	this.PanicError(db.Error)
+	return jobs
+}
+
+func (this *JobDao) CountByTypeAndStatus(jobType string, status string) int64 {
+	var count int64
+	db := core.CONTEXT.GetDB().
+		Model(&model.Job{}).
+		Where("type = ? AND status = ?", jobType, status).
+		Count(&count)
+	this.PanicError(db.Error)
+	return count
+}
+
+func (this *JobDao) Create(job *model.Job) *model.Job {
+
+	timeUUID, _ := uuid.NewV4()
+	job.Uuid = string(timeUUID.String())
+	job.CreateTime = time.Now()
+	job.UpdateTime = time.Now()
+	job.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(job)
+	this.PanicError(db.Error)
+
+	return job
+}
+
+// change the status only if it is still fromStatus. false if someone else changed it first.
+func (this *JobDao) UpdateStatus(uuid string, fromStatus string, toStatus string, columns map[string]interface{}) bool {
+	if columns == nil {
+		columns = make(map[string]interface{})
+	}
+	columns["status"] = toStatus
+	columns["update_time"] = time.Now()
+	db := core.CONTEXT.GetDB().Model(&model.Job{}).Where("uuid = ? AND status = ?", uuid, fromStatus).UpdateColumns(columns)
+	this.PanicError(db.Error)
+	return db.RowsAffected > 0
+}
+
+// the status is not touched, so that a cancel is not overwritten.
+func (this *JobDao) UpdateProgress(uuid string, percent int64, message string) {
+	db := core.CONTEXT.GetDB().Model(&model.Job{}).Where("uuid = ?", uuid).UpdateColumns(map[string]interface{}{"percent": percent, "message": message, "update_time": time.Now()})
+	this.PanicError(db.Error)
+}
+
+func (this *JobDao) DeleteByUserUuid(userUuid string) {
+	db := core.CONTEXT.GetDB().Where("user_uuid = ?", userUuid).Delete(model.Job{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *JobDao) Cleanup() {
+	this.Logger.Info("[JobDao] clean up. Delete all Job")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Job{})
+	this.PanicError(db.Error)
+}
@@ -5,7 +5,7 @@ import (
 	"box/code/rest/model"
 	"box/code/tool/builder"
 	"box/code/tool/result"
-	"box/code/tool/util"
+	"box/code/tool/storage"
 	"box/code/tool/uuid"
 	"gorm.io/gorm"
 	"math"
@@ -15,8 +15,16 @@ import (
 
 type MatterDao struct {
 	BaseDao
-	imageCacheDao *ImageCacheDao
-	bridgeDao     *BridgeDao
+	imageCacheDao    *ImageCacheDao
+	bridgeDao        *BridgeDao
+	blobDao          *BlobDao
+	matterVersionDao *MatterVersionDao
+	matterSearchDao  *MatterSearchDao
+	matterTagDao     *MatterTagDao
+	favoriteDao      *FavoriteDao
+	recentDao        *RecentDao
+	matterMediaDao   *MatterMediaDao
+	albumMatterDao   *AlbumMatterDao
 }
 
 func (this *MatterDao) Init() {
@@ -32,6 +40,46 @@ func (this *MatterDao) Init() {
 		this.bridgeDao = b
 	}
 
+	b = core.CONTEXT.GetBean(this.blobDao)
+	if b, ok := b.(*BlobDao); ok {
+		this.blobDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterVersionDao)
+	if b, ok := b.(*MatterVersionDao); ok {
+		this.matterVersionDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterSearchDao)
+	if b, ok := b.(*MatterSearchDao); ok {
+		this.matterSearchDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterTagDao)
+	if b, ok := b.(*MatterTagDao); ok {
+		this.matterTagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.favoriteDao)
+	if b, ok := b.(*FavoriteDao); ok {
+		this.favoriteDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentDao)
+	if b, ok := b.(*RecentDao); ok {
+		this.recentDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterMediaDao)
+	if b, ok := b.(*MatterMediaDao); ok {
+		this.matterMediaDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.albumMatterDao)
+	if b, ok := b.(*AlbumMatterDao); ok {
+		this.albumMatterDao = b
+	}
+
 }
 
 func (this *MatterDao) FindByUuid(uuid string) *model.Matter {
@@ -356,6 +404,113 @@ func (this *MatterDao) FindByUuids(uuids []string, sortArray []builder.OrderPair
 	return matters
 }
 
+// all the children of a directory, including the deleted ones.
+func (this *MatterDao) FindByPuuidAndSpaceUuid(puuid string, spaceUuid string) []*model.Matter {
+	var matters []*model.Matter
+	db := core.CONTEXT.GetDB().Where("puuid = ? AND space_uuid = ?", puuid, spaceUuid).Find(&matters)
+	this.PanicError(db.Error)
+	return matters
+}
+
+// an undeleted matter whose physics file has the inode.
+func (this *MatterDao) FindBySpaceUuidAndFileInode(spaceUuid string, inode int64) *model.Matter {
+	var matter = &model.Matter{}
+	db := core.CONTEXT.GetDB().Where("space_uuid = ? AND file_inode = ? AND deleted = ?", spaceUuid, inode, false).First(matter)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return matter
+}
+
+// an undeleted matter by its path in a space.
+func (this *MatterDao) FindBySpaceUuidAndPath(spaceUuid string, path string) *model.Matter {
+	var matter = &model.Matter{}
+	db := core.CONTEXT.GetDB().Where("space_uuid = ? AND path = ? AND deleted = ?", spaceUuid, path, false).First(matter)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return matter
+}
+
+// a matter by its path in a space, deleted or not.
+func (this *MatterDao) FindBySpaceUuidAndPathIncludeDeleted(spaceUuid string, path string) *model.Matter {
+	var matter = &model.Matter{}
+	db := core.CONTEXT.GetDB().Where("space_uuid = ? AND path = ?", spaceUuid, path).First(matter)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return matter
+}
+
+// count the undeleted matters. path is a directory including itself.
+func (this *MatterDao) CountBySpaceUuidAndPath(spaceUuid string, path string) int64 {
+
+	var wp = &builder.WherePair{Query: "space_uuid = ? AND deleted = ? AND (path = ? OR path LIKE ?)", Args: []interface{}{spaceUuid, false, path, path + "/%"}}
+
+	var count int64
+	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where(wp.Query, wp.Args...).Count(&count)
+	this.PanicError(db.Error)
+
+	return count
+}
+
+// total and largest size of the undeleted files in a matter. path is the matter's, including its children.
+func (this *MatterDao) SumSizeBySpaceUuidAndPath(spaceUuid string, path string) (totalSize int64, largestSize int64) {
+
+	var wp = &builder.WherePair{Query: "space_uuid = ? AND dir = ? AND deleted = ? AND (path = ? OR path LIKE ?)", Args: []interface{}{spaceUuid, false, false, path, path + "/%"}}
+
+	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where(wp.Query, wp.Args...).Select("COALESCE(SUM(size), 0), COALESCE(MAX(size), 0)")
+	this.PanicError(db.Error)
+	err := db.Row().Scan(&totalSize, &largestSize)
+	core.PanicError(err)
+
+	return totalSize, largestSize
+}
+
+// count the undeleted directories of a space.
+func (this *MatterDao) CountDirBySpaceUuid(spaceUuid string) int64 {
+
+	var count int64
+	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where("space_uuid = ? AND dir = ? AND deleted = ?", spaceUuid, true, false).Count(&count)
+	this.PanicError(db.Error)
+
+	return count
+}
+
+// count the undeleted matters kept on disk. path is a directory including itself, "" for the whole space.
+func (this *MatterDao) CountPhysicsBySpaceUuidAndPath(spaceUuid string, path string) int64 {
+
+	var wp = &builder.WherePair{Query: "space_uuid = ? AND deleted = ?", Args: []interface{}{spaceUuid, false}}
+	wp = wp.And(&builder.WherePair{Query: "(dir = ? OR COALESCE(blob_uuid, '') = '' OR COALESCE(md5, '') = '')", Args: []interface{}{true}})
+	if path != "" {
+		wp = wp.And(&builder.WherePair{Query: "(path = ? OR path LIKE ?)", Args: []interface{}{path, path + "/%"}})
+	}
+
+	var count int64
+	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where(wp.Query, wp.Args...).Count(&count)
+	this.PanicError(db.Error)
+
+	return count
+}
+
+// record the modify time and inode of the physics file. update time is not touched.
+func (this *MatterDao) UpdateFileInfo(matterUuid string, fileModTime int64, fileInode int64) {
+	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where("uuid = ?", matterUuid).UpdateColumns(map[string]interface{}{"file_mod_time": fileModTime, "file_inode": fileInode})
+	this.PanicError(db.Error)
+}
+
 // pagination is 0 base.
 func (this *MatterDao) PlainPage(
 	page int,
@@ -427,6 +582,44 @@ func (this *MatterDao) PlainPage(
 
 	return int(count), matters
 }
+
+// get pager of the matters with tags. mode is TAG_MODE_AND or TAG_MODE_OR.
+func (this *MatterDao) PageByTagUuids(page int, pageSize int, spaceUuid string, tagUuids []string, mode string, deleted string, sortArray []builder.OrderPair) *model.Pager {
+
+	//a duplicated uuid would never be matched in AND mode.
+	uniqueTagUuids := make([]string, 0, len(tagUuids))
+	tagUuidMap := make(map[string]bool)
+	for _, tagUuid := range tagUuids {
+		if !tagUuidMap[tagUuid] {
+			tagUuidMap[tagUuid] = true
+			uniqueTagUuids = append(uniqueTagUuids, tagUuid)
+		}
+	}
+	tagUuids = uniqueTagUuids
+
+	subQuery := core.CONTEXT.GetDB().Model(&model.MatterTag{}).Select("matter_uuid").Where("tag_uuid IN (?)", tagUuids)
+	if mode == model.TAG_MODE_AND {
+		subQuery = subQuery.Group("matter_uuid").Having("COUNT(DISTINCT tag_uuid) = ?", len(tagUuids))
+	}
+
+	conditionDB := core.CONTEXT.GetDB().Model(&model.Matter{}).Where("space_uuid = ? AND uuid IN (?)", spaceUuid, subQuery)
+	if deleted == model.TRUE {
+		conditionDB = conditionDB.Where("deleted = ?", 1)
+	} else if deleted == model.FALSE {
+		conditionDB = conditionDB.Where("deleted = ?", 0)
+	}
+
+	var count int64 = 0
+	db := conditionDB.Count(&count)
+	this.PanicError(db.Error)
+
+	var matters []*model.Matter
+	db = conditionDB.Order(this.GetSortString(sortArray)).Offset(page * pageSize).Limit(pageSize).Find(&matters)
+	this.PanicError(db.Error)
+
+	return model.NewPager(page, pageSize, int(count), matters)
+}
+
 func (this *MatterDao) Page(page int, pageSize int, puuid string, userUuid string, spaceUuid string, name string, dir string, deleted string, extensions []string, sortArray []builder.OrderPair) *model.Pager {
 
 	count, matters := this.PlainPage(page, pageSize, puuid, userUuid, spaceUuid, name, dir, deleted, nil, extensions, sortArray)
@@ -481,6 +674,8 @@ func (this *MatterDao) Create(matter *model.Matter) *model.Matter {
 	db := core.CONTEXT.GetDB().Create(matter)
 	this.PanicError(db.Error)
 
+	this.matterSearchDao.Index(matter)
+
 	return matter
 }
 
@@ -490,9 +685,20 @@ func (this *MatterDao) Save(matter *model.Matter) *model.Matter {
 	db := core.CONTEXT.GetDB().Save(matter)
 	this.PanicError(db.Error)
 
+	//name, path or props may change.
+	this.matterSearchDao.Index(matter)
+
 	return matter
 }
 
+// find matters whose uuid is greater than the uuid. used to walk through all matters.
+func (this *MatterDao) FindAfterUuid(uuid string, limit int) []*model.Matter {
+	var matters []*model.Matter
+	db := core.CONTEXT.GetDB().Where("uuid > ?", uuid).Order("uuid ASC").Limit(limit).Find(&matters)
+	this.PanicError(db.Error)
+	return matters
+}
+
 // download time add 1
 func (this *MatterDao) TimesIncrement(matterUuid string) {
 	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where("uuid = ?", matterUuid).Updates(map[string]interface{}{"times": gorm.Expr("times + 1"), "visit_time": time.Now()})
@@ -553,10 +759,20 @@ func (this *MatterDao) Delete(matter *model.Matter) {
 		//delete from db.
 		db := core.CONTEXT.GetDB().Delete(&matter)
 		this.PanicError(db.Error)
-		if util.PathExists(matter.AbsolutePath()) {
-			//delete dir from disk.
-			util.DeleteEmptyDir(matter.AbsolutePath())
-		}
+
+		this.matterSearchDao.DeleteByUuid(matter.Uuid)
+
+		//take off its tags.
+		this.matterTagDao.DeleteByMatterUuid(matter.Uuid)
+
+		//no one can star it anymore.
+		this.favoriteDao.DeleteByMatterUuid(matter.Uuid)
+
+		//remove it from the recent files.
+		this.recentDao.DeleteByMatterUuid(matter.Uuid)
+
+		//delete dir from storage.
+		storage.RemoveEmptyDir(core.CONTEXT.GetStorage(), matter.StoragePath())
 
 	} else {
 
@@ -564,16 +780,41 @@ func (this *MatterDao) Delete(matter *model.Matter) {
 		db := core.CONTEXT.GetDB().Delete(&matter)
 		this.PanicError(db.Error)
 
+		this.matterSearchDao.DeleteByUuid(matter.Uuid)
+
 		//delete its image cache.
 		this.imageCacheDao.DeleteByMatterUuid(matter.Uuid)
 
 		//delete all the share.
 		this.bridgeDao.DeleteByMatterUuid(matter.Uuid)
 
-		//delete from disk.
-		err := os.Remove(matter.AbsolutePath())
-		if err != nil {
-			this.Logger.Error("occur error when deleting file. %v", err)
+		//delete the history versions.
+		this.matterVersionDao.DeleteByMatterUuid(matter.Uuid)
+
+		//take off its tags.
+		this.matterTagDao.DeleteByMatterUuid(matter.Uuid)
+
+		//no one can star it anymore.
+		this.favoriteDao.DeleteByMatterUuid(matter.Uuid)
+
+		//remove it from the recent files.
+		this.recentDao.DeleteByMatterUuid(matter.Uuid)
+
+		//delete its media metadata.
+		this.matterMediaDao.DeleteByMatterUuid(matter.Uuid)
+
+		//take it out of the albums.
+		this.albumMatterDao.DeleteByMatterUuid(matter.Uuid)
+
+		if matter.IsBlob() {
+			//the blob is shared. only release the reference.
+			this.blobDao.Release(matter.BlobUuid)
+		} else {
+			//delete from storage.
+			err := core.CONTEXT.GetStorage().Remove(matter.StoragePath())
+			if err != nil {
+				this.Logger.Error("occur error when deleting file. %v", err)
+			}
 		}
 
 	}
@@ -599,7 +840,23 @@ func (this *MatterDao) Recovery(matter *model.Matter) {
 
 func (this *MatterDao) DeleteByUserUuid(userUuid string) {
 
-	db := core.CONTEXT.GetDB().Where("user_uuid = ?", userUuid).Delete(model.Matter{})
+	this.matterVersionDao.DeleteByMatterUserUuid(userUuid)
+	this.matterSearchDao.DeleteByUserUuid(userUuid)
+	this.matterTagDao.DeleteByMatterUserUuid(userUuid)
+	this.favoriteDao.DeleteByMatterUserUuid(userUuid)
+	this.recentDao.DeleteByMatterUserUuid(userUuid)
+	this.matterMediaDao.DeleteByMatterUserUuid(userUuid)
+	this.albumMatterDao.DeleteByMatterUserUuid(userUuid)
+
+	//release the shared blobs first.
+	var blobUuids []string
+	db := core.CONTEXT.GetDB().Model(&model.Matter{}).Where("user_uuid = ? AND dir = 0 AND blob_uuid != ''", userUuid).Pluck("blob_uuid", &blobUuids)
+	this.PanicError(db.Error)
+	for _, blobUuid := range blobUuids {
+		this.blobDao.Release(blobUuid)
+	}
+
+	db = core.CONTEXT.GetDB().Where("user_uuid = ?", userUuid).Delete(model.Matter{})
 	this.PanicError(db.Error)
 
 }
@@ -730,7 +987,11 @@ func (this *MatterDao) Cleanup() {
 	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Matter{})
 	this.PanicError(db.Error)
 
-	err := os.RemoveAll(core.CONFIG.MatterPath())
+	err := storage.RemoveAll(core.CONTEXT.GetStorage(), "/")
+	this.PanicError(err)
+
+	//caches and temp files are always on local disk.
+	err = os.RemoveAll(core.CONFIG.MatterPath())
 	this.PanicError(err)
 
 }
@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type MatterMediaDao struct {
+	BaseDao
+}
+
+// find by matterUuid. if not found return nil.
+func (this *MatterMediaDao) FindByMatterUuid(matterUuid string) *model.MatterMedia {
+	var entity = &model.MatterMedia{}
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// find the metadata of matters. matterUuid -> metadata
+func (this *MatterMediaDao) FindByMatterUuids(matterUuids []string) map[string]*model.MatterMedia {
+	var matterMedias []*model.MatterMedia
+	if len(matterUuids) > 0 {
+		db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", matterUuids).Find(&matterMedias)
+		this.PanicError(db.Error)
+	}
+
+	mediaMap := make(map[string]*model.MatterMedia)
+	for _, matterMedia := range matterMedias {
+		mediaMap[matterMedia.MatterUuid] = matterMedia
+	}
+	return mediaMap
+}
+
+// create or replace the metadata of the matter.
+func (this *MatterMediaDao) Save(matterMedia *model.MatterMedia) *model.MatterMedia {
+
+	exist := this.FindByMatterUuid(matterMedia.MatterUuid)
+	if exist == nil {
+		timeUUID, _ := uuid.NewV4()
+		matterMedia.Uuid = string(timeUUID.String())
+		matterMedia.CreateTime = time.Now()
+		matterMedia.Sort = time.Now().UnixNano() / 1e6
+	} else {
+		matterMedia.Uuid = exist.Uuid
+		matterMedia.CreateTime = exist.CreateTime
+		matterMedia.Sort = exist.Sort
+	}
+	matterMedia.UpdateTime = time.Now()
+	db := core.CONTEXT.GetDB().Save(matterMedia)
+	this.PanicError(db.Error)
+
+	return matterMedia
+}
+
+func (this *MatterMediaDao) DeleteByMatterUuid(matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Delete(model.MatterMedia{})
+	this.PanicError(db.Error)
+}
+
+// delete the metadata of all the user's matters.
+func (this *MatterMediaDao) DeleteByMatterUserUuid(userUuid string) {
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("user_uuid = ?", userUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", subQuery).Delete(model.MatterMedia{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *MatterMediaDao) Cleanup() {
+	this.Logger.Info("[MatterMediaDao] clean up. Delete all MatterMedia")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.MatterMedia{})
+	this.PanicError(db.Error)
+}
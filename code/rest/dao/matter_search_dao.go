@@ -0,0 +1,306 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/util"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+/**
+ * full text index of matters' name, path, extension and props.
+ * sqlite uses a fts5 table with trigram tokenizer, mysql uses a table with ngram FULLTEXT index.
+ * other states like deleted are always read from the matter table.
+ */
+type MatterSearchDao struct {
+	BaseDao
+}
+
+func (this *MatterSearchDao) tableName() string {
+	return core.TABLE_PREFIX + "matter_search"
+}
+
+func (this *MatterSearchDao) matterTableName() string {
+	return core.TABLE_PREFIX + "matter"
+}
+
+func (this *MatterSearchDao) matterMediaTableName() string {
+	return core.TABLE_PREFIX + "matter_media"
+}
+
+func (this *MatterSearchDao) isSqlite() bool {
+	return core.CONFIG.DbType() == "sqlite"
+}
+
+// create the index table if not exists. true means it's newly created and should be rebuilt.
+func (this *MatterSearchDao) CreateTableIfNotExist() bool {
+
+	db := core.CONTEXT.GetDB()
+	if db.Migrator().HasTable(this.tableName()) {
+		return false
+	}
+
+	var sql string
+	if this.isSqlite() {
+		//trigram tokenizer supports substring match. https://www.sqlite.org/fts5.html#the_trigram_tokenizer
+		sql = fmt.Sprintf("CREATE VIRTUAL TABLE `%s` USING fts5(uuid UNINDEXED, name, path, extension, props, tokenize = 'trigram')", this.tableName())
+	} else {
+		//ngram parser supports CJK and substring match. https://dev.mysql.com/doc/refman/8.0/en/fulltext-search-ngram.html
+		sql = fmt.Sprintf("CREATE TABLE `%s` ("+
+			"`uuid` char(36) NOT NULL, "+
+			"`name` varchar(255) NOT NULL DEFAULT '', "+
+			"`path` text NOT NULL, "+
+			"`extension` varchar(45) NOT NULL DEFAULT '', "+
+			"`props` text NOT NULL, "+
+			"PRIMARY KEY (`uuid`), "+
+			"FULLTEXT KEY `idx_matter_search_n` (`name`) WITH PARSER ngram, "+
+			"FULLTEXT KEY `idx_matter_search_all` (`name`, `path`, `extension`, `props`) WITH PARSER ngram)", this.tableName())
+	}
+
+	db = db.Exec(sql)
+	this.PanicError(db.Error)
+
+	this.Logger.Info("create search index table %s", this.tableName())
+	return true
+}
+
+// the text of props to be indexed. eg. "key1 value1 key2 value2"
+func (this *MatterSearchDao) propsText(matter *model.Matter) string {
+	propMap := matter.FetchPropMap()
+	keys := make([]string, 0, len(propMap))
+	for key := range propMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, key, propMap[key])
+	}
+	return strings.Join(parts, " ")
+}
+
+func (this *MatterSearchDao) index(db *gorm.DB, matter *model.Matter) {
+
+	tx := db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE uuid = ?", this.tableName()), matter.Uuid)
+	this.PanicError(tx.Error)
+
+	tx = db.Exec(fmt.Sprintf("INSERT INTO `%s` (uuid, name, path, extension, props) VALUES (?, ?, ?, ?, ?)", this.tableName()),
+		matter.Uuid, matter.Name, matter.Path, strings.TrimPrefix(util.GetExtension(matter.Name), "."), this.propsText(matter))
+	this.PanicError(tx.Error)
+}
+
+// create or update the index of a matter.
+func (this *MatterSearchDao) Index(matter *model.Matter) {
+	this.index(core.CONTEXT.GetDB(), matter)
+}
+
+// index matters in one transaction.
+func (this *MatterSearchDao) IndexBatch(matters []*model.Matter) {
+	err := core.CONTEXT.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, matter := range matters {
+			this.index(tx, matter)
+		}
+		return nil
+	})
+	this.PanicError(err)
+}
+
+func (this *MatterSearchDao) DeleteByUuid(uuid string) {
+	db := core.CONTEXT.GetDB().Exec(fmt.Sprintf("DELETE FROM `%s` WHERE uuid = ?", this.tableName()), uuid)
+	this.PanicError(db.Error)
+}
+
+func (this *MatterSearchDao) DeleteByUserUuid(userUuid string) {
+	db := core.CONTEXT.GetDB().Exec(fmt.Sprintf("DELETE FROM `%s` WHERE uuid IN (SELECT uuid FROM `%s` WHERE user_uuid = ?)", this.tableName(), this.matterTableName()), userUuid)
+	this.PanicError(db.Error)
+}
+
+func (this *MatterSearchDao) DeleteAll() {
+	db := core.CONTEXT.GetDB().Exec(fmt.Sprintf("DELETE FROM `%s`", this.tableName()))
+	this.PanicError(db.Error)
+}
+
+// search matters in a space. results are ranked by relevance if keyword given and no sort specified.
+func (this *MatterSearchDao) Page(page int, pageSize int, spaceUuid string, filter *model.MatterFilter, sortArray []builder.OrderPair) *model.Pager {
+
+	//fts5 table's MATCH and bm25 must use the table name rather than an alias.
+	index := "s"
+	from := fmt.Sprintf("`%s` m JOIN `%s` s ON s.uuid = m.uuid", this.matterTableName(), this.tableName())
+	if this.isSqlite() {
+		index = "`" + this.tableName() + "`"
+		from = fmt.Sprintf("`%s` m JOIN %s ON %s.uuid = m.uuid", this.matterTableName(), index, index)
+	}
+
+	wp := &builder.WherePair{Query: "m.space_uuid = ?", Args: []interface{}{spaceUuid}}
+	if filterWp := this.filterWhere(filter); filterWp.Query != "" {
+		wp = wp.And(filterWp)
+	}
+
+	//terms shorter than a token cannot use the index.
+	minTokenLength := 2
+	if this.isSqlite() {
+		minTokenLength = 3
+	}
+	var phrases []string
+	for _, term := range strings.Fields(filter.Keyword) {
+		if utf8.RuneCountInString(term) >= minTokenLength {
+			phrases = append(phrases, term)
+		} else {
+			wp = wp.And(&builder.WherePair{Query: fmt.Sprintf("(%s.path LIKE ? OR %s.props LIKE ?)", index, index), Args: []interface{}{"%" + term + "%", "%" + term + "%"}})
+		}
+	}
+
+	orderBy := "m.dir DESC, m.name ASC"
+	var orderArgs []interface{}
+	if len(phrases) > 0 {
+		if this.isSqlite() {
+			var parts []string
+			for _, phrase := range phrases {
+				parts = append(parts, `"`+strings.ReplaceAll(phrase, `"`, `""`)+`"`)
+			}
+			wp = wp.And(&builder.WherePair{Query: index + " MATCH ?", Args: []interface{}{strings.Join(parts, " ")}})
+			//weights of uuid, name, path, extension, props. smaller bm25 is better.
+			orderBy = fmt.Sprintf("bm25(%s, 0, 10.0, 1.0, 5.0, 2.0) ASC, m.name ASC", index)
+		} else {
+			var parts []string
+			for _, phrase := range phrases {
+				parts = append(parts, `+"`+strings.ReplaceAll(phrase, `"`, ``)+`"`)
+			}
+			against := strings.Join(parts, " ")
+			wp = wp.And(&builder.WherePair{Query: "MATCH(s.name, s.path, s.extension, s.props) AGAINST(? IN BOOLEAN MODE)", Args: []interface{}{against}})
+			orderBy = "(MATCH(s.name) AGAINST(? IN BOOLEAN MODE) * 2 + MATCH(s.name, s.path, s.extension, s.props) AGAINST(? IN BOOLEAN MODE)) DESC, m.name ASC"
+			orderArgs = append(orderArgs, against, against)
+		}
+	} else if filter.Keyword == "" {
+		//no need to join the index.
+		from = fmt.Sprintf("`%s` m", this.matterTableName())
+	}
+
+	//specified sort takes the place of relevance.
+	var prefixedSortArray []builder.OrderPair
+	for _, pair := range sortArray {
+		prefixedSortArray = append(prefixedSortArray, builder.OrderPair{Key: "m." + pair.Key, Value: pair.Value})
+	}
+	if sortString := this.GetSortString(prefixedSortArray); sortString != "" {
+		orderBy = sortString
+		orderArgs = nil
+	}
+
+	var count int64
+	db := core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, wp.Query), wp.Args...).Scan(&count)
+	this.PanicError(db.Error)
+
+	matters := []*model.Matter{}
+	queryArgs := append(append(append([]interface{}{}, wp.Args...), orderArgs...), pageSize, page*pageSize)
+	db = core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT m.* FROM %s WHERE %s ORDER BY %s LIMIT ? OFFSET ?", from, wp.Query, orderBy), queryArgs...).Scan(&matters)
+	this.PanicError(db.Error)
+
+	return model.NewPager(page, pageSize, int(count), matters)
+}
+
+// conditions on the matter table except keyword.
+func (this *MatterSearchDao) filterWhere(filter *model.MatterFilter) *builder.WherePair {
+
+	var wp = &builder.WherePair{}
+
+	if filter.PathPrefix != "" {
+		wp = wp.And(&builder.WherePair{Query: "m.path LIKE ?", Args: []interface{}{filter.PathPrefix + "/%"}})
+	}
+
+	if filter.SizeMin >= 0 {
+		wp = wp.And(&builder.WherePair{Query: "m.size >= ?", Args: []interface{}{filter.SizeMin}})
+	}
+	if filter.SizeMax >= 0 {
+		wp = wp.And(&builder.WherePair{Query: "m.size <= ?", Args: []interface{}{filter.SizeMax}})
+	}
+
+	if filter.CreateTimeStart != nil {
+		wp = wp.And(&builder.WherePair{Query: "m.create_time >= ?", Args: []interface{}{*filter.CreateTimeStart}})
+	}
+	if filter.CreateTimeEnd != nil {
+		wp = wp.And(&builder.WherePair{Query: "m.create_time <= ?", Args: []interface{}{*filter.CreateTimeEnd}})
+	}
+	if filter.UpdateTimeStart != nil {
+		wp = wp.And(&builder.WherePair{Query: "m.update_time >= ?", Args: []interface{}{*filter.UpdateTimeStart}})
+	}
+	if filter.UpdateTimeEnd != nil {
+		wp = wp.And(&builder.WherePair{Query: "m.update_time <= ?", Args: []interface{}{*filter.UpdateTimeEnd}})
+	}
+
+	//name ends with one of the extensions.
+	extensionWhere := func(extensions []string) *builder.WherePair {
+		var orWp = &builder.WherePair{}
+		for _, extension := range extensions {
+			orWp = orWp.Or(&builder.WherePair{Query: "m.name LIKE ?", Args: []interface{}{"%." + extension}})
+		}
+		return &builder.WherePair{Query: "(" + orWp.Query + ")", Args: orWp.Args}
+	}
+	if len(filter.Extensions) > 0 {
+		wp = wp.And(extensionWhere(filter.Extensions))
+	}
+	if len(filter.Categories) > 0 {
+		var extensions []string
+		for _, category := range filter.Categories {
+			extensions = append(extensions, model.MATTER_CATEGORY_EXTENSIONS[category]...)
+		}
+		wp = wp.And(extensionWhere(extensions))
+	}
+
+	if filter.UserUuid != "" {
+		wp = wp.And(&builder.WherePair{Query: "m.user_uuid = ?", Args: []interface{}{filter.UserUuid}})
+	}
+
+	for column, value := range map[string]string{"m.dir": filter.Dir, "m.privacy": filter.Privacy, "m.deleted": filter.Deleted} {
+		if value == model.TRUE {
+			wp = wp.And(&builder.WherePair{Query: column + " = ?", Args: []interface{}{1}})
+		} else if value == model.FALSE {
+			wp = wp.And(&builder.WherePair{Query: column + " = ?", Args: []interface{}{0}})
+		}
+	}
+
+	if mediaWp := this.mediaWhere(filter); mediaWp.Query != "" {
+		wp = wp.And(&builder.WherePair{Query: fmt.Sprintf("EXISTS (SELECT 1 FROM `%s` mm WHERE mm.matter_uuid = m.uuid AND %s)", this.matterMediaTableName(), mediaWp.Query), Args: mediaWp.Args})
+	}
+
+	return wp
+}
+
+// conditions on the media metadata table.
+func (this *MatterSearchDao) mediaWhere(filter *model.MatterFilter) *builder.WherePair {
+
+	var wp = &builder.WherePair{}
+
+	for column, value := range map[string]string{"mm.make": filter.CameraMake, "mm.model": filter.CameraModel, "mm.artist": filter.Artist, "mm.album": filter.Album} {
+		if value != "" {
+			wp = wp.And(&builder.WherePair{Query: column + " LIKE ?", Args: []interface{}{"%" + value + "%"}})
+		}
+	}
+
+	if filter.TakenTimeStart != nil {
+		wp = wp.And(&builder.WherePair{Query: "mm.taken_time >= ?", Args: []interface{}{*filter.TakenTimeStart}})
+	}
+	if filter.TakenTimeEnd != nil {
+		wp = wp.And(&builder.WherePair{Query: "mm.taken_time <= ?", Args: []interface{}{*filter.TakenTimeEnd}})
+	}
+
+	if filter.Gps == model.TRUE {
+		wp = wp.And(&builder.WherePair{Query: "mm.latitude IS NOT NULL AND mm.longitude IS NOT NULL"})
+	} else if filter.Gps == model.FALSE {
+		wp = wp.And(&builder.WherePair{Query: "(mm.latitude IS NULL OR mm.longitude IS NULL)"})
+	}
+
+	return wp
+}
+
+// System cleanup.
+func (this *MatterSearchDao) Cleanup() {
+	this.Logger.Info("[MatterSearchDao] clean up. Delete all search index.")
+	this.DeleteAll()
+}
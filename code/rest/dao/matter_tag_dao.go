@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type MatterTagDao struct {
+	BaseDao
+}
+
+// find by tagUuid and matterUuid. if not found return nil.
+func (this *MatterTagDao) FindByTagUuidAndMatterUuid(tagUuid string, matterUuid string) *model.MatterTag {
+	var entity = &model.MatterTag{}
+	db := core.CONTEXT.GetDB().Where("tag_uuid = ? AND matter_uuid = ?", tagUuid, matterUuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// count the undeleted matters of each tag in a space. tagUuid -> count
+func (this *MatterTagDao) CountBySpaceUuid(spaceUuid string) map[string]int64 {
+
+	type tagCount struct {
+		TagUuid string
+		Count   int64
+	}
+	var tagCounts []*tagCount
+	db := core.CONTEXT.GetDB().Model(&model.MatterTag{}).
+		Select("tag_uuid, COUNT(*) AS count").
+		Where("space_uuid = ? AND matter_uuid IN (?)", spaceUuid, core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("deleted = ?", false)).
+		Group("tag_uuid").
+		Scan(&tagCounts)
+	this.PanicError(db.Error)
+
+	countMap := make(map[string]int64)
+	for _, item := range tagCounts {
+		countMap[item.TagUuid] = item.Count
+	}
+	return countMap
+}
+
+func (this *MatterTagDao) Create(matterTag *model.MatterTag) *model.MatterTag {
+
+	timeUUID, _ := uuid.NewV4()
+	matterTag.Uuid = string(timeUUID.String())
+	matterTag.CreateTime = time.Now()
+	matterTag.UpdateTime = time.Now()
+	matterTag.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(matterTag)
+	this.PanicError(db.Error)
+
+	return matterTag
+}
+
+func (this *MatterTagDao) DeleteByTagUuidAndMatterUuid(tagUuid string, matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("tag_uuid = ? AND matter_uuid = ?", tagUuid, matterUuid).Delete(model.MatterTag{})
+	this.PanicError(db.Error)
+}
+
+func (this *MatterTagDao) DeleteByTagUuid(tagUuid string) {
+	db := core.CONTEXT.GetDB().Where("tag_uuid = ?", tagUuid).Delete(model.MatterTag{})
+	this.PanicError(db.Error)
+}
+
+func (this *MatterTagDao) DeleteByMatterUuid(matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Delete(model.MatterTag{})
+	this.PanicError(db.Error)
+}
+
+func (this *MatterTagDao) DeleteBySpaceUuid(spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.MatterTag{})
+	this.PanicError(db.Error)
+}
+
+// delete the tags of all the user's matters.
+func (this *MatterTagDao) DeleteByMatterUserUuid(userUuid string) {
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("user_uuid = ?", userUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", subQuery).Delete(model.MatterTag{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *MatterTagDao) Cleanup() {
+	this.Logger.Info("[MatterTagDao] clean up. Delete all MatterTag")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.MatterTag{})
+	this.PanicError(db.Error)
+}
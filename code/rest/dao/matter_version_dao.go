@@ -0,0 +1,128 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type MatterVersionDao struct {
+	BaseDao
+	blobDao *BlobDao
+}
+
+func (this *MatterVersionDao) Init() {
+	this.BaseDao.Init()
+
+	b := core.CONTEXT.GetBean(this.blobDao)
+	if b, ok := b.(*BlobDao); ok {
+		this.blobDao = b
+	}
+}
+
+// find by uuid. if not found return nil.
+func (this *MatterVersionDao) FindByUuid(uuid string) *model.MatterVersion {
+	var entity = &model.MatterVersion{}
+	db := core.CONTEXT.GetDB().Where("uuid = ?", uuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// find by uuid. if not found panic NotFound error
+func (this *MatterVersionDao) CheckByUuid(uuid string) *model.MatterVersion {
+	entity := this.FindByUuid(uuid)
+	if entity == nil {
+		panic(result.NotFound("not found record with uuid = %s", uuid))
+	}
+	return entity
+}
+
+// list versions of a matter. the latest first.
+func (this *MatterVersionDao) ListByMatterUuid(matterUuid string) []*model.MatterVersion {
+	var versions []*model.MatterVersion
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Order("sort desc").Find(&versions)
+	this.PanicError(db.Error)
+	return versions
+}
+
+// find the matters which have versions sorted before the time.
+func (this *MatterVersionDao) ListMatterUuidsBySpaceUuidAndSortBefore(spaceUuid string, sort int64) []string {
+	var matterUuids []string
+	db := core.CONTEXT.GetDB().Model(&model.MatterVersion{}).Where("space_uuid = ? AND sort < ?", spaceUuid, sort).Distinct().Pluck("matter_uuid", &matterUuids)
+	this.PanicError(db.Error)
+	return matterUuids
+}
+
+func (this *MatterVersionDao) Create(matterVersion *model.MatterVersion) *model.MatterVersion {
+
+	timeUUID, _ := uuid.NewV4()
+	matterVersion.Uuid = string(timeUUID.String())
+	//the first version of a matter may be taken from an old content.
+	if matterVersion.CreateTime.IsZero() {
+		matterVersion.CreateTime = time.Now()
+	}
+	matterVersion.UpdateTime = time.Now()
+	if matterVersion.Sort == 0 {
+		matterVersion.Sort = time.Now().UnixNano() / 1e6
+	}
+	db := core.CONTEXT.GetDB().Create(matterVersion)
+	this.PanicError(db.Error)
+
+	return matterVersion
+}
+
+func (this *MatterVersionDao) Save(matterVersion *model.MatterVersion) *model.MatterVersion {
+
+	matterVersion.UpdateTime = time.Now()
+	db := core.CONTEXT.GetDB().Save(matterVersion)
+	this.PanicError(db.Error)
+
+	return matterVersion
+}
+
+// hand over versions from one matter to another.
+func (this *MatterVersionDao) UpdateMatterUuid(oldMatterUuid string, newMatterUuid string) {
+	db := core.CONTEXT.GetDB().Model(&model.MatterVersion{}).Where("matter_uuid = ?", oldMatterUuid).Update("matter_uuid", newMatterUuid)
+	this.PanicError(db.Error)
+}
+
+// delete a version and release its blob.
+func (this *MatterVersionDao) Delete(matterVersion *model.MatterVersion) {
+
+	db := core.CONTEXT.GetDB().Delete(&matterVersion)
+	this.PanicError(db.Error)
+
+	this.blobDao.Release(matterVersion.BlobUuid)
+}
+
+func (this *MatterVersionDao) DeleteByMatterUuid(matterUuid string) {
+	for _, matterVersion := range this.ListByMatterUuid(matterUuid) {
+		this.Delete(matterVersion)
+	}
+}
+
+// delete versions of all the user's matters.
+func (this *MatterVersionDao) DeleteByMatterUserUuid(userUuid string) {
+	var versions []*model.MatterVersion
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("user_uuid = ?", userUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", subQuery).Find(&versions)
+	this.PanicError(db.Error)
+	for _, matterVersion := range versions {
+		this.Delete(matterVersion)
+	}
+}
+
+// System cleanup.
+func (this *MatterVersionDao) Cleanup() {
+	this.Logger.Info("[MatterVersionDao] clean up. Delete all MatterVersion")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.MatterVersion{})
+	this.PanicError(db.Error)
+}
@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"fmt"
+)
+
+// queries of the image matters joined with their media metadata.
+type PhotoDao struct {
+	BaseDao
+}
+
+// capture time of the photo. the upload time if unknown.
+const photoTimeColumn = "COALESCE(mm.taken_time, m.create_time)"
+
+func (this *PhotoDao) from() string {
+	return fmt.Sprintf("`%smatter` m LEFT JOIN `%smatter_media` mm ON mm.matter_uuid = m.uuid", core.TABLE_PREFIX, core.TABLE_PREFIX)
+}
+
+// undeleted image files of the space.
+func (this *PhotoDao) where(spaceUuid string) *builder.WherePair {
+
+	var extensionWp = &builder.WherePair{}
+	for _, extension := range model.MATTER_CATEGORY_EXTENSIONS[model.MATTER_CATEGORY_IMAGE] {
+		extensionWp = extensionWp.Or(&builder.WherePair{Query: "m.name LIKE ?", Args: []interface{}{"%." + extension}})
+	}
+
+	wp := &builder.WherePair{Query: "m.space_uuid = ? AND m.dir = ? AND m.deleted = ?", Args: []interface{}{spaceUuid, 0, 0}}
+	return wp.And(&builder.WherePair{Query: "(" + extensionWp.Query + ")", Args: extensionWp.Args})
+}
+
+// count the photos of a space by the prefix of capture time. newest first.
+func (this *PhotoDao) Timeline(spaceUuid string, keyLength int) []*model.PhotoBucket {
+
+	wp := this.where(spaceUuid)
+	//the time's text starts with yyyy-MM-dd in both mysql and sqlite.
+	keyColumn := fmt.Sprintf("SUBSTR(%s, 1, %d)", photoTimeColumn, keyLength)
+
+	buckets := []*model.PhotoBucket{}
+	db := core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT %s AS `key`, COUNT(*) AS count FROM %s WHERE %s GROUP BY %s ORDER BY `key` DESC", keyColumn, this.from(), wp.Query, keyColumn), wp.Args...).Scan(&buckets)
+	this.PanicError(db.Error)
+
+	return buckets
+}
+
+// page the photos of a space. key limits the capture time. eg. 2024-05. newest first.
+func (this *PhotoDao) Page(page int, pageSize int, spaceUuid string, key string) (int, []*model.Matter) {
+
+	wp := this.where(spaceUuid)
+	if key != "" {
+		wp = wp.And(&builder.WherePair{Query: fmt.Sprintf("SUBSTR(%s, 1, %d) = ?", photoTimeColumn, len(key)), Args: []interface{}{key}})
+	}
+
+	return this.page(page, pageSize, this.from(), wp, photoTimeColumn+" DESC, m.name ASC")
+}
+
+// page the matters of an album. latest added first. the matters moved out of the album's space are left out.
+func (this *PhotoDao) PageByAlbumUuid(page int, pageSize int, albumUuid string) (int, []*model.Matter) {
+
+	from := fmt.Sprintf("`%smatter` m JOIN `%salbum_matter` am ON am.matter_uuid = m.uuid JOIN `%salbum` a ON a.uuid = am.album_uuid", core.TABLE_PREFIX, core.TABLE_PREFIX, core.TABLE_PREFIX)
+	wp := &builder.WherePair{Query: "am.album_uuid = ? AND m.space_uuid = a.space_uuid AND m.deleted = ?", Args: []interface{}{albumUuid, 0}}
+
+	return this.page(page, pageSize, from, wp, "am.sort DESC")
+}
+
+func (this *PhotoDao) page(page int, pageSize int, from string, wp *builder.WherePair, orderBy string) (int, []*model.Matter) {
+
+	var count int64
+	db := core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, wp.Query), wp.Args...).Scan(&count)
+	this.PanicError(db.Error)
+
+	matters := []*model.Matter{}
+	queryArgs := append(append([]interface{}{}, wp.Args...), pageSize, page*pageSize)
+	db = core.CONTEXT.GetDB().Raw(fmt.Sprintf("SELECT m.* FROM %s WHERE %s ORDER BY %s LIMIT ? OFFSET ?", from, wp.Query, orderBy), queryArgs...).Scan(&matters)
+	this.PanicError(db.Error)
+
+	return int(count), matters
+}
@@ -25,6 +25,10 @@ func (this *PreferenceDao) Fetch() *model.Preference {
 			preference.Version = core.VERSION
 			preference.PreviewConfig = "{}"
 			preference.ScanConfig = "{}"
+			//mysql cannot return the column defaults, set them explicitly.
+			preference.RecentKeepDays = 30
+			preference.ImageCacheMaxSize = -1
+			preference.ImageCacheSpaceMaxSize = -1
 			this.Create(preference)
 			return preference
 		} else {
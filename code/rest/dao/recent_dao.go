@@ -0,0 +1,139 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type RecentDao struct {
+	BaseDao
+}
+
+// find by userUuid and matterUuid. if not found return nil.
+func (this *RecentDao) FindByUserUuidAndMatterUuid(userUuid string, matterUuid string) *model.Recent {
+	var entity = &model.Recent{}
+	db := core.CONTEXT.GetDB().Where("user_uuid = ? AND matter_uuid = ?", userUuid, matterUuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// get pager of a user's recent files. the matters in recycle bin are excluded.
+func (this *RecentDao) PlainPage(page int, pageSize int, user *model.User, spaceUuid string, action string, visitTimeAfter *time.Time, sortArray []builder.OrderPair) (int, []*model.Recent) {
+
+	var wp = &builder.WherePair{Query: "user_uuid = ?", Args: []interface{}{user.Uuid}}
+
+	//the matters may be moved into a space the user cannot read.
+	if readableWp := whereReadableSpace(user); readableWp != nil {
+		wp = wp.And(readableWp)
+	}
+
+	if spaceUuid != "" {
+		wp = wp.And(&builder.WherePair{Query: "space_uuid = ?", Args: []interface{}{spaceUuid}})
+	}
+
+	if action != "" {
+		wp = wp.And(&builder.WherePair{Query: "action = ?", Args: []interface{}{action}})
+	}
+
+	if visitTimeAfter != nil {
+		wp = wp.And(&builder.WherePair{Query: "visit_time >= ?", Args: []interface{}{*visitTimeAfter}})
+	}
+
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("deleted = ?", false)
+	conditionDB := core.CONTEXT.GetDB().Model(&model.Recent{}).Where(wp.Query, wp.Args...).Where("matter_uuid IN (?)", subQuery)
+
+	var count int64 = 0
+	db := conditionDB.Count(&count)
+	this.PanicError(db.Error)
+
+	var recents []*model.Recent
+	db = conditionDB.Order(this.GetSortString(sortArray)).Offset(page * pageSize).Limit(pageSize).Find(&recents)
+	this.PanicError(db.Error)
+
+	return int(count), recents
+}
+
+func (this *RecentDao) Create(recent *model.Recent) *model.Recent {
+
+	timeUUID, _ := uuid.NewV4()
+	recent.Uuid = string(timeUUID.String())
+	recent.CreateTime = time.Now()
+	recent.UpdateTime = time.Now()
+	recent.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(recent)
+	this.PanicError(db.Error)
+
+	return recent
+}
+
+func (this *RecentDao) Save(recent *model.Recent) *model.Recent {
+
+	recent.UpdateTime = time.Now()
+	db := core.CONTEXT.GetDB().Save(recent)
+	this.PanicError(db.Error)
+
+	return recent
+}
+
+func (this *RecentDao) DeleteByMatterUuid(matterUuid string) {
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+// the matter is moved into another space.
+func (this *RecentDao) UpdateSpaceUuidByMatterUuid(matterUuid string, spaceUuid string) {
+	db := core.CONTEXT.GetDB().Model(&model.Recent{}).Where("matter_uuid = ?", matterUuid).Update("space_uuid", spaceUuid)
+	this.PanicError(db.Error)
+}
+
+// the matter is moved into another space. the users who cannot read that space lose it.
+func (this *RecentDao) DeleteByMatterUuidAndNotSpaceReader(matterUuid string, spaceUuid string) {
+	wp := whereSpaceReader(spaceUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid = ?", matterUuid).Where("NOT "+wp.Query, wp.Args...).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+func (this *RecentDao) DeleteByUserUuid(userUuid string) {
+	db := core.CONTEXT.GetDB().Where("user_uuid = ?", userUuid).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+// the user cannot read the space anymore.
+func (this *RecentDao) DeleteByUserUuidAndSpaceUuid(userUuid string, spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("user_uuid = ? AND space_uuid = ?", userUuid, spaceUuid).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+func (this *RecentDao) DeleteBySpaceUuid(spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+// delete the records of all the user's matters.
+func (this *RecentDao) DeleteByMatterUserUuid(userUuid string) {
+	subQuery := core.CONTEXT.GetDB().Model(&model.Matter{}).Select("uuid").Where("user_uuid = ?", userUuid)
+	db := core.CONTEXT.GetDB().Where("matter_uuid IN (?)", subQuery).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+func (this *RecentDao) DeleteByVisitTimeBefore(visitTime time.Time) {
+	db := core.CONTEXT.GetDB().Where("visit_time < ?", visitTime).Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *RecentDao) Cleanup() {
+	this.Logger.Info("[RecentDao] clean up. Delete all Recent")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Recent{})
+	this.PanicError(db.Error)
+}
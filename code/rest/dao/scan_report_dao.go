@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type ScanReportDao struct {
+	BaseDao
+}
+
+// find by uuid. if not found return nil.
+func (this *ScanReportDao) FindByUuid(uuid string) *model.ScanReport {
+	var entity = &model.ScanReport{}
+	db := core.CONTEXT.GetDB().Where("uuid = ?", uuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	entity.Unpack()
+	return entity
+}
+
+// the latest first.
+func (this *ScanReportDao) FindAll() []*model.ScanReport {
+	var reports []*model.ScanReport
+	db := core.CONTEXT.GetDB().Order("sort DESC").Find(&reports)
+	this.PanicError(db.Error)
+	for _, report := range reports {
+		report.Unpack()
+	}
+	return reports
+}
+
+// replace the previous report of the same kind in the space.
+func (this *ScanReportDao) Create(report *model.ScanReport) *model.ScanReport {
+
+	this.DeleteBySpaceUuidAndDryRun(report.SpaceUuid, report.DryRun)
+
+	timeUUID, _ := uuid.NewV4()
+	report.Uuid = string(timeUUID.String())
+	report.CreateTime = time.Now()
+	report.UpdateTime = time.Now()
+	report.Sort = time.Now().UnixNano() / 1e6
+	report.Pack()
+	db := core.CONTEXT.GetDB().Create(report)
+	this.PanicError(db.Error)
+
+	return report
+}
+
+// false if it has been deleted by someone else.
+func (this *ScanReportDao) Delete(report *model.ScanReport) bool {
+	db := core.CONTEXT.GetDB().Where("uuid = ?", report.Uuid).Delete(model.ScanReport{})
+	this.PanicError(db.Error)
+	return db.RowsAffected > 0
+}
+
+func (this *ScanReportDao) DeleteBySpaceUuidAndDryRun(spaceUuid string, dryRun bool) {
+	db := core.CONTEXT.GetDB().Where("space_uuid = ? AND dry_run = ?", spaceUuid, dryRun).Delete(model.ScanReport{})
+	this.PanicError(db.Error)
+}
+
+func (this *ScanReportDao) DeleteBySpaceUuid(spaceUuid string) {
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.ScanReport{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *ScanReportDao) Cleanup() {
+	this.Logger.Info("[ScanReportDao] clean up. Delete all ScanReport")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.ScanReport{})
+	this.PanicError(db.Error)
+}
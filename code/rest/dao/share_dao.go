@@ -68,6 +68,13 @@ func (this *ShareDao) PlainPage(page int, pageSize int, userUuid string, sortArr
 	return int(count), shares
 }
 
+func (this *ShareDao) FindBySpaceUuid(spaceUuid string) []*model.Share {
+	var shares []*model.Share
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Find(&shares)
+	this.PanicError(db.Error)
+	return shares
+}
+
 func (this *ShareDao) Create(share *model.Share) *model.Share {
 
 	timeUUID, _ := uuid.NewV4()
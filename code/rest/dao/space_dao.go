@@ -50,6 +50,15 @@ func (this *SpaceDao) CountByName(name string) int {
 	return int(count)
 }
 
+func (this *SpaceDao) CountAll() int64 {
+	var count int64
+	db := core.CONTEXT.GetDB().
+		Model(&model.Space{}).
+		Count(&count)
+	this.PanicError(db.Error)
+	return count
+}
+
 func (this *SpaceDao) FindByName(name string) *model.Space {
 
 	var space = &model.Space{}
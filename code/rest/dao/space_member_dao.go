@@ -5,6 +5,7 @@ import (
 	"box/code/rest/model"
 	"box/code/tool/builder"
 	"box/code/tool/result"
+	"fmt"
 	"gorm.io/gorm"
 
 	"box/code/tool/uuid"
@@ -13,6 +14,41 @@ import (
 
 type SpaceMemberDao struct {
 	BaseDao
+	favoriteDao *FavoriteDao
+	recentDao   *RecentDao
+}
+
+// the rows whose user_uuid can read the space. same rule as SpaceMemberService.CanRead.
+func whereSpaceReader(spaceUuid string) *builder.WherePair {
+	return &builder.WherePair{
+		Query: fmt.Sprintf("(user_uuid IN (SELECT uuid FROM `%suser` WHERE role = ? OR space_uuid = ?) OR user_uuid IN (SELECT user_uuid FROM `%sspace_member` WHERE space_uuid = ?))", core.TABLE_PREFIX, core.TABLE_PREFIX),
+		Args:  []interface{}{model.USER_ROLE_ADMINISTRATOR, spaceUuid, spaceUuid},
+	}
+}
+
+// the rows whose space_uuid is readable by the user. same rule as SpaceMemberService.CanRead. nil if all are readable.
+func whereReadableSpace(user *model.User) *builder.WherePair {
+	if user.Role == model.USER_ROLE_ADMINISTRATOR {
+		return nil
+	}
+	return &builder.WherePair{
+		Query: fmt.Sprintf("(space_uuid = ? OR space_uuid IN (SELECT space_uuid FROM `%sspace_member` WHERE user_uuid = ?))", core.TABLE_PREFIX),
+		Args:  []interface{}{user.SpaceUuid, user.Uuid},
+	}
+}
+
+func (this *SpaceMemberDao) Init() {
+	this.BaseDao.Init()
+
+	b := core.CONTEXT.GetBean(this.favoriteDao)
+	if b, ok := b.(*FavoriteDao); ok {
+		this.favoriteDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentDao)
+	if b, ok := b.(*RecentDao); ok {
+		this.recentDao = b
+	}
 }
 
 // find by uuid. if not found return nil.
@@ -110,6 +146,10 @@ func (this *SpaceMemberDao) Delete(spaceMember *model.SpaceMember) {
 	db := core.CONTEXT.GetDB().Delete(&spaceMember)
 	this.PanicError(db.Error)
 
+	//the member cannot read the space's matters anymore.
+	this.favoriteDao.DeleteByUserUuidAndSpaceUuid(spaceMember.UserUuid, spaceMember.SpaceUuid)
+	this.recentDao.DeleteByUserUuidAndSpaceUuid(spaceMember.UserUuid, spaceMember.SpaceUuid)
+
 }
 
 func (this *SpaceMemberDao) DeleteBySpaceUuid(spaceUuid string) {
@@ -120,6 +160,9 @@ func (this *SpaceMemberDao) DeleteBySpaceUuid(spaceUuid string) {
 
 	db := core.CONTEXT.GetDB().Where(wp.Query, wp.Args).Delete(model.SpaceMember{})
 	this.PanicError(db.Error)
+
+	this.favoriteDao.DeleteBySpaceUuid(spaceUuid)
+	this.recentDao.DeleteBySpaceUuid(spaceUuid)
 }
 
 func (this *SpaceMemberDao) CountBySpaceUuid(spaceUuid string) int {
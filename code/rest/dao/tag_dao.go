@@ -0,0 +1,123 @@
+package dao
+
+import (
+	"box/code/core"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/uuid"
+	"time"
+)
+
+type TagDao struct {
+	BaseDao
+	matterTagDao *MatterTagDao
+}
+
+func (this *TagDao) Init() {
+	this.BaseDao.Init()
+
+	b := core.CONTEXT.GetBean(this.matterTagDao)
+	if b, ok := b.(*MatterTagDao); ok {
+		this.matterTagDao = b
+	}
+}
+
+// find by uuid. if not found return nil.
+func (this *TagDao) FindByUuid(uuid string) *model.Tag {
+	var entity = &model.Tag{}
+	db := core.CONTEXT.GetDB().Where("uuid = ?", uuid).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// find by uuid. if not found panic NotFound error
+func (this *TagDao) CheckByUuid(uuid string) *model.Tag {
+	entity := this.FindByUuid(uuid)
+	if entity == nil {
+		panic(result.NotFound("not found record with uuid = %s", uuid))
+	}
+	return entity
+}
+
+// find by spaceUuid and name. if not found return nil.
+func (this *TagDao) FindBySpaceUuidAndName(spaceUuid string, name string) *model.Tag {
+	var entity = &model.Tag{}
+	db := core.CONTEXT.GetDB().Where("space_uuid = ? AND name = ?", spaceUuid, name).First(entity)
+	if db.Error != nil {
+		if db.Error.Error() == result.DB_ERROR_NOT_FOUND {
+			return nil
+		} else {
+			panic(db.Error)
+		}
+	}
+	return entity
+}
+
+// list all the tags of a space, ordered by name.
+func (this *TagDao) ListBySpaceUuid(spaceUuid string) []*model.Tag {
+	var tags = []*model.Tag{}
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Order("name asc").Find(&tags)
+	this.PanicError(db.Error)
+	return tags
+}
+
+// list the tags of a matter, ordered by name.
+func (this *TagDao) ListByMatterUuid(matterUuid string) []*model.Tag {
+	var tags = []*model.Tag{}
+	subQuery := core.CONTEXT.GetDB().Model(&model.MatterTag{}).Select("tag_uuid").Where("matter_uuid = ?", matterUuid)
+	db := core.CONTEXT.GetDB().Where("uuid IN (?)", subQuery).Order("name asc").Find(&tags)
+	this.PanicError(db.Error)
+	return tags
+}
+
+func (this *TagDao) Create(tag *model.Tag) *model.Tag {
+
+	timeUUID, _ := uuid.NewV4()
+	tag.Uuid = string(timeUUID.String())
+	tag.CreateTime = time.Now()
+	tag.UpdateTime = time.Now()
+	tag.Sort = time.Now().UnixNano() / 1e6
+	db := core.CONTEXT.GetDB().Create(tag)
+	this.PanicError(db.Error)
+
+	return tag
+}
+
+func (this *TagDao) Save(tag *model.Tag) *model.Tag {
+
+	tag.UpdateTime = time.Now()
+	db := core.CONTEXT.GetDB().Save(tag)
+	this.PanicError(db.Error)
+
+	return tag
+}
+
+// delete a tag and take it off from all the matters.
+func (this *TagDao) Delete(tag *model.Tag) {
+
+	this.matterTagDao.DeleteByTagUuid(tag.Uuid)
+
+	db := core.CONTEXT.GetDB().Delete(&tag)
+	this.PanicError(db.Error)
+}
+
+func (this *TagDao) DeleteBySpaceUuid(spaceUuid string) {
+
+	this.matterTagDao.DeleteBySpaceUuid(spaceUuid)
+
+	db := core.CONTEXT.GetDB().Where("space_uuid = ?", spaceUuid).Delete(model.Tag{})
+	this.PanicError(db.Error)
+}
+
+// System cleanup.
+func (this *TagDao) Cleanup() {
+	this.Logger.Info("[TagDao] clean up. Delete all Tag")
+	db := core.CONTEXT.GetDB().Where("uuid is not null").Delete(model.Tag{})
+	this.PanicError(db.Error)
+}
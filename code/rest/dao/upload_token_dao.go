@@ -58,6 +58,13 @@ func (this *UploadTokenDao) Save(uploadToken *model.UploadToken) *model.UploadTo
 	return uploadToken
 }
 
+func (this *UploadTokenDao) Delete(uploadToken *model.UploadToken) {
+
+	db := core.CONTEXT.GetDB().Delete(&uploadToken)
+	this.PanicError(db.Error)
+
+}
+
 func (this *UploadTokenDao) DeleteByUserUuid(userUuid string) {
 
 	db := core.CONTEXT.GetDB().Where("user_uuid = ?", userUuid).Delete(model.UploadToken{})
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+/**
+ * an album of a space. it references matters in any directories without moving them.
+ */
+type Album struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_album_su"`
+	UserUuid   string    `json:"userUuid" gorm:"type:char(36) not null"`
+	Name       string    `json:"name" gorm:"type:varchar(255) not null"`
+	//number of the undeleted matters in this album.
+	MatterCount int64 `json:"matterCount" gorm:"-"`
+}
+
+/**
+ * the link table for Album and Matter.
+ */
+type AlbumMatter struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_album_matter_su"`
+	AlbumUuid  string    `json:"albumUuid" gorm:"type:char(36) not null;uniqueIndex:idx_album_matter_au_mu"`
+	MatterUuid string    `json:"matterUuid" gorm:"type:char(36) not null;uniqueIndex:idx_album_matter_au_mu;index:idx_album_matter_mu"`
+}
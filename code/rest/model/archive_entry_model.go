@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+/**
+ * an entry inside an archive matter.
+ */
+type ArchiveEntry struct {
+	//relative path in the archive separated by /
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
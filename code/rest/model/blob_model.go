@@ -0,0 +1,47 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	//shared blob directory name. space name cannot contain dot, so no conflict.
+	BLOB_DIR = ".blob"
+	//temp directory of uploading blobs.
+	BLOB_TEMP_DIR = "tmp"
+)
+
+/**
+ * content addressed file. matters with the same md5 share one blob on disk.
+ */
+type Blob struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	Md5        string    `json:"md5" gorm:"type:varchar(45) not null;uniqueIndex:idx_blob_md5"`
+	Size       int64     `json:"size" gorm:"type:bigint(20) not null;default:0"`
+	RefCount   int64     `json:"refCount" gorm:"type:bigint(20) not null;default:0"`
+	Path       string    `json:"path" gorm:"type:varchar(512)"`
+}
+
+// get the path in the storage driver. path in db means relative path.
+func (this *Blob) StoragePath() string {
+	return "/" + BLOB_DIR + this.Path
+}
+
+// get the path of a blob in the storage driver
+func GetBlobStoragePath(md5 string) string {
+	return "/" + BLOB_DIR + GetBlobRelativePath(md5)
+}
+
+// get the temp path of uploading blobs in the storage driver
+func GetBlobTempStoragePath(name string) string {
+	return fmt.Sprintf("/%s/%s/%s", BLOB_DIR, BLOB_TEMP_DIR, name)
+}
+
+// blobs are spread into two levels of directories by md5. eg. /d4/1d/d41d8cd98f00b204e9800998ecf8427e
+func GetBlobRelativePath(md5 string) string {
+	return fmt.Sprintf("/%s/%s/%s", md5[0:2], md5[2:4], md5)
+}
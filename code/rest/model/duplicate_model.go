@@ -0,0 +1,15 @@
+package model
+
+/**
+ * files with the same content. they share one blob on disk, but every copy counts in the space's size.
+ */
+type DuplicateGroup struct {
+	Md5  string `json:"md5"`
+	Size int64  `json:"size"`
+	//number of the copies.
+	Count int64 `json:"count"`
+	//bytes of all the copies except one.
+	WastedSize int64 `json:"wastedSize"`
+	//oldest first.
+	Matters []*Matter `json:"matters" gorm:"-"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+/**
+ * a matter starred by a user.
+ */
+type Favorite struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	UserUuid   string    `json:"userUuid" gorm:"type:char(36) not null;uniqueIndex:idx_favorite_uu_mu"`
+	MatterUuid string    `json:"matterUuid" gorm:"type:char(36) not null;uniqueIndex:idx_favorite_uu_mu;index:idx_favorite_mu"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_favorite_su"`
+	Matter     *Matter   `json:"matter" gorm:"-"`
+}
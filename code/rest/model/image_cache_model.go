@@ -1,6 +1,11 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 /**
  * image cache.
@@ -19,6 +24,10 @@ type ImageCache struct {
 	Md5        string    `json:"md5" gorm:"type:varchar(45)"`
 	Size       int64     `json:"size" gorm:"type:bigint(20) not null;default:0"`
 	Path       string    `json:"path" gorm:"type:varchar(512)"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36);index:idx_image_cache_su"`
+	//Matter.ContentKey() when cached. a different one means the content is changed.
+	ContentKey string    `json:"contentKey" gorm:"type:varchar(64)"`
+	VisitTime  time.Time `json:"visitTime" gorm:"type:timestamp not null;index:idx_image_cache_vt;default:'2018-01-01 00:00:00'"`
 	Matter     *Matter   `json:"matter" gorm:"-"`
 }
 
@@ -26,3 +35,92 @@ type ImageCache struct {
 func (this *ImageCache) AbsolutePath() string {
 	return GetSpaceCacheRootDir(this.Username) + this.Path
 }
+
+/**
+ * statistics of the image cache. hits, misses and evictions are counted since the application started.
+ */
+type ImageCacheStats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hitRate"`
+	Evictions int64   `json:"evictions"`
+	//current bytes and number of caches.
+	TotalSize  int64 `json:"totalSize"`
+	TotalCount int64 `json:"totalCount"`
+	//limits in bytes. -1 means no limit.
+	MaxSize      int64 `json:"maxSize"`
+	SpaceMaxSize int64 `json:"spaceMaxSize"`
+	//spaceUuid -> bytes
+	SpaceSizes map[string]int64 `json:"spaceSizes"`
+}
+
+/**
+ * progress of generating the thumbnail presets for existing images.
+ */
+type ImageCacheBackfill struct {
+	Running bool `json:"running"`
+	//number of the images to process.
+	Total     int64 `json:"total"`
+	Processed int64 `json:"processed"`
+	//number of the thumbnails generated and failed.
+	Generated int64      `json:"generated"`
+	Failed    int64      `json:"failed"`
+	StartTime *time.Time `json:"startTime"`
+	EndTime   *time.Time `json:"endTime"`
+}
+
+/**
+ * how to process an image. zero value means not required.
+ */
+type ImageProcess struct {
+	//fit, fill or fixed. empty means no resizing.
+	ResizeMode   string
+	ResizeWidth  int
+	ResizeHeight int
+	//extension of the output without dot. empty means the same as the source.
+	Format string
+	//quality of jpeg. 1-100
+	Quality int
+	//rotate by the EXIF orientation.
+	AutoOrient bool
+	//clockwise degrees. 90, 180 or 270
+	Rotate int
+	//crop rectangle on the source. no crop if width is 0.
+	CropX      int
+	CropY      int
+	CropWidth  int
+	CropHeight int
+	//sigma of the gaussian blur.
+	Blur      float64
+	Grayscale bool
+}
+
+// the mode of the image cache. the same as mode_w_h when only resizing.
+func (this *ImageProcess) Mode() string {
+	var parts []string
+	if this.ResizeMode != "" {
+		parts = append(parts, fmt.Sprintf("%s_%d_%d", this.ResizeMode, this.ResizeWidth, this.ResizeHeight))
+	}
+	if this.Format != "" {
+		parts = append(parts, "f-"+this.Format)
+	}
+	if this.Quality > 0 {
+		parts = append(parts, fmt.Sprintf("q%d", this.Quality))
+	}
+	if this.AutoOrient {
+		parts = append(parts, "auto")
+	}
+	if this.Rotate > 0 {
+		parts = append(parts, fmt.Sprintf("r%d", this.Rotate))
+	}
+	if this.CropWidth > 0 {
+		parts = append(parts, fmt.Sprintf("c%d-%d-%d-%d", this.CropX, this.CropY, this.CropWidth, this.CropHeight))
+	}
+	if this.Blur > 0 {
+		parts = append(parts, "b"+strconv.FormatFloat(this.Blur, 'f', -1, 64))
+	}
+	if this.Grayscale {
+		parts = append(parts, "gray")
+	}
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	//mirror local files into a space.
+	JOB_TYPE_MIRROR = "MIRROR"
+	//copy a matter.
+	JOB_TYPE_COPY = "COPY"
+	//crawl a url into a directory.
+	JOB_TYPE_CRAWL = "CRAWL"
+	//compute the sizes of all the directories in a space.
+	JOB_TYPE_COMPUTE_DIR_SIZE = "COMPUTE_DIR_SIZE"
+	//scan the physics files of the spaces in the scan config.
+	JOB_TYPE_SCAN = "SCAN"
+)
+
+const (
+	JOB_STATUS_QUEUED    = "QUEUED"
+	JOB_STATUS_RUNNING   = "RUNNING"
+	JOB_STATUS_SUCCEEDED = "SUCCEEDED"
+	JOB_STATUS_FAILED    = "FAILED"
+	JOB_STATUS_CANCELLED = "CANCELLED"
+)
+
+/**
+ * a long operation run by the job workers in background.
+ */
+type Job struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	//who submitted the job.
+	UserUuid  string `json:"userUuid" gorm:"type:char(36) not null;index:idx_job_uu"`
+	SpaceUuid string `json:"spaceUuid" gorm:"type:char(36)"`
+	Type      string `json:"type" gorm:"type:varchar(45) not null"`
+	Status    string `json:"status" gorm:"type:varchar(45) not null;index:idx_job_status"`
+	//0 ~ 100
+	Percent int64 `json:"percent" gorm:"type:bigint(20) not null;default:0"`
+	//what the job is doing, or why it failed.
+	Message string `json:"message" gorm:"type:varchar(1024)"`
+	//json of JobParams
+	Params string `json:"params" gorm:"type:text"`
+	//json of the result. eg. the crawled matter.
+	Result    string    `json:"result" gorm:"type:text"`
+	StartTime time.Time `json:"startTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	EndTime   time.Time `json:"endTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+}
+
+/**
+ * arguments of a job. only the fields of its type are used.
+ */
+type JobParams struct {
+	//copy: the matter to copy.
+	SrcUuid string `json:"srcUuid,omitempty"`
+	//copy: the directory to copy into. crawl: the directory to crawl into.
+	DestUuid string `json:"destUuid,omitempty"`
+	//copy: the new name. crawl: the filename.
+	Name string `json:"name,omitempty"`
+	//mirror: the local path.
+	SrcPath string `json:"srcPath,omitempty"`
+	//mirror: the directory in the space.
+	DestPath  string `json:"destPath,omitempty"`
+	Url       string `json:"url,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+	Privacy   bool   `json:"privacy,omitempty"`
+	Full      bool   `json:"full,omitempty"`
+	DryRun    bool   `json:"dryRun,omitempty"`
+}
+
+// finished jobs never run again.
+func (this *Job) Finished() bool {
+	return this.Status == JOB_STATUS_SUCCEEDED || this.Status == JOB_STATUS_FAILED || this.Status == JOB_STATUS_CANCELLED
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+)
+
+// categories of files, judged by extension.
+const (
+	MATTER_CATEGORY_IMAGE    = "image"
+	MATTER_CATEGORY_VIDEO    = "video"
+	MATTER_CATEGORY_AUDIO    = "audio"
+	MATTER_CATEGORY_DOCUMENT = "document"
+	MATTER_CATEGORY_ARCHIVE  = "archive"
+)
+
+var MATTER_CATEGORY_EXTENSIONS = map[string][]string{
+	MATTER_CATEGORY_IMAGE:    {"jpg", "jpeg", "png", "gif", "bmp", "webp", "svg", "tif", "tiff", "ico", "heic", "heif", "raw", "cr2", "nef", "arw", "dng"},
+	MATTER_CATEGORY_VIDEO:    {"mp4", "mkv", "avi", "mov", "wmv", "flv", "webm", "m4v", "mpg", "mpeg", "3gp", "ts", "rmvb"},
+	MATTER_CATEGORY_AUDIO:    {"mp3", "wav", "flac", "aac", "ogg", "m4a", "wma", "ape", "opus"},
+	MATTER_CATEGORY_DOCUMENT: {"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "odt", "ods", "odp", "rtf", "txt", "md", "csv", "epub", "pages", "numbers", "key"},
+	MATTER_CATEGORY_ARCHIVE:  {"zip", "rar", "7z", "tar", "gz", "tgz", "bz2", "xz", "zst", "tzst"},
+}
+
+/**
+ * conditions of searching matters. zero value means no limit.
+ */
+type MatterFilter struct {
+	Keyword string
+	//only the matters under this path. eg. /a/b
+	PathPrefix string
+	//-1 means no limit.
+	SizeMin         int64
+	SizeMax         int64
+	CreateTimeStart *time.Time
+	CreateTimeEnd   *time.Time
+	UpdateTimeStart *time.Time
+	UpdateTimeEnd   *time.Time
+	//without dot. eg. jpg
+	Extensions []string
+	//MATTER_CATEGORY_XXX
+	Categories []string
+	//owner of the matters.
+	UserUuid string
+	//TRUE, FALSE or empty.
+	Dir     string
+	Privacy string
+	Deleted string
+	//conditions on the media metadata. only the matters extracted match.
+	CameraMake     string
+	CameraModel    string
+	TakenTimeStart *time.Time
+	TakenTimeEnd   *time.Time
+	//TRUE means with GPS, FALSE means without.
+	Gps    string
+	Artist string
+	Album  string
+}
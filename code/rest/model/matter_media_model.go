@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+/**
+ * metadata of an image, audio or video file. one record for one matter.
+ */
+type MatterMedia struct {
+	Uuid        string     `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort        int64      `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime  time.Time  `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime  time.Time  `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	MatterUuid  string     `json:"matterUuid" gorm:"type:char(36) not null;uniqueIndex:idx_matter_media_mu"`
+	Width       int        `json:"width" gorm:"type:int(11) not null;default:0"`
+	Height      int        `json:"height" gorm:"type:int(11) not null;default:0"`
+	Orientation int        `json:"orientation" gorm:"type:int(11) not null;default:0"`
+	Make        string     `json:"make" gorm:"type:varchar(255) not null;default:''"`
+	Model       string     `json:"model" gorm:"type:varchar(255) not null;default:''"`
+	TakenTime   *time.Time `json:"takenTime" gorm:"type:timestamp null;index:idx_matter_media_tt"`
+	Latitude    *float64   `json:"latitude" gorm:"type:double null"`
+	Longitude   *float64   `json:"longitude" gorm:"type:double null"`
+	//in seconds.
+	Duration float64 `json:"duration" gorm:"type:double not null;default:0"`
+	Title    string  `json:"title" gorm:"type:varchar(255) not null;default:''"`
+	Artist   string  `json:"artist" gorm:"type:varchar(255) not null;default:''"`
+	Album    string  `json:"album" gorm:"type:varchar(255) not null;default:''"`
+	Year     string  `json:"year" gorm:"type:varchar(45) not null;default:''"`
+	Genre    string  `json:"genre" gorm:"type:varchar(255) not null;default:''"`
+}
@@ -17,9 +17,7 @@ const (
 	//root matter's uuid
 	MATTER_ROOT = "root"
 	//cache directory name.
-	MATTER_CACHE = "cache"
-	//zip file temp directory.
-	MATTER_ZIP             = "zip"
+	MATTER_CACHE           = "cache"
 	MATTER_NAME_MAX_LENGTH = 200
 	MATTER_NAME_MAX_DEPTH  = 32
 	//matter name pattern
@@ -50,14 +48,41 @@ type Matter struct {
 	Deleted    bool      `json:"deleted" gorm:"type:tinyint(1) not null;index:idx_matter_del;default:0"`
 	DeleteTime time.Time `json:"deleteTime" gorm:"type:timestamp not null;index:idx_matter_delt;default:'2018-01-01 00:00:00'"`
 	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_matter_space_uuid"`
-	User       *User     `json:"user" gorm:"-"`
-	Parent     *Matter   `json:"parent" gorm:"-"`
-	Children   []*Matter `json:"-" gorm:"-"`
+	BlobUuid   string    `json:"blobUuid" gorm:"type:char(36);index:idx_matter_blob_uuid"`
+	//modify time in unix nano and inode of the physics file. used by the incremental scan.
+	FileModTime int64     `json:"fileModTime" gorm:"type:bigint(20) not null;default:0"`
+	FileInode   int64     `json:"fileInode" gorm:"type:bigint(20) not null;index:idx_matter_file_inode;default:0"`
+	User        *User     `json:"user" gorm:"-"`
+	Parent      *Matter   `json:"parent" gorm:"-"`
+	Children    []*Matter `json:"-" gorm:"-"`
+	//image, audio or video metadata. only filled in detail.
+	Media *MatterMedia `json:"media" gorm:"-"`
 }
 
-// get matter's absolute path. the Path property is relative path in db.
+// get matter's absolute path on local disk. the Path property is relative path in db.
 func (this *Matter) AbsolutePath() string {
-	return GetSpaceMatterRootDir(this.SpaceName) + this.Path
+	return core.CONFIG.MatterPath() + this.StoragePath()
+}
+
+// get matter's path in the storage driver. file stored as blob resolves to the shared blob.
+func (this *Matter) StoragePath() string {
+	if this.IsBlob() {
+		return GetBlobStoragePath(this.Md5)
+	}
+	return GetSpaceMatterStoragePath(this.SpaceName) + this.Path
+}
+
+// whether the content of this file is stored in the shared blob directory.
+func (this *Matter) IsBlob() bool {
+	return !this.Dir && this.BlobUuid != "" && this.Md5 != ""
+}
+
+// identify the content of this file. it changes when the content changes.
+func (this *Matter) ContentKey() string {
+	if this.IsBlob() {
+		return this.Md5
+	}
+	return fmt.Sprintf("%d_%d", this.Size, this.FileModTime)
 }
 
 func (this *Matter) MimeType() string {
@@ -96,18 +121,18 @@ func GetSpaceMatterRootDir(spaceName string) (rootDirPath string) {
 	return rootDirPath
 }
 
-// get user's cache absolute path
-func GetSpaceCacheRootDir(spaceName string) (rootDirPath string) {
+// get space's root path in the storage driver
+func GetSpaceMatterStoragePath(spaceName string) (rootPath string) {
 
-	rootDirPath = fmt.Sprintf("%s/%s/%s", core.CONFIG.MatterPath(), spaceName, MATTER_CACHE)
+	rootPath = fmt.Sprintf("/%s/%s", spaceName, MATTER_ROOT)
 
-	return rootDirPath
+	return rootPath
 }
 
-// get user's zip absolute path
-func GetSpaceZipRootDir(spaceName string) (rootDirPath string) {
+// get user's cache absolute path
+func GetSpaceCacheRootDir(spaceName string) (rootDirPath string) {
 
-	rootDirPath = fmt.Sprintf("%s/%s/%s", core.CONFIG.MatterPath(), spaceName, MATTER_ZIP)
+	rootDirPath = fmt.Sprintf("%s/%s/%s", core.CONFIG.MatterPath(), spaceName, MATTER_CACHE)
 
 	return rootDirPath
 }
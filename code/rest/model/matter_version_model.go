@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+/**
+ * a history content of a file. the content is kept as a blob.
+ * the latest version of a matter is its current content.
+ */
+type MatterVersion struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	MatterUuid string    `json:"matterUuid" gorm:"type:char(36) not null;index:idx_matter_version_mu"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_matter_version_su"`
+	UserUuid   string    `json:"userUuid" gorm:"type:char(36) not null"`
+	Username   string    `json:"username" gorm:"type:varchar(45) not null"`
+	BlobUuid   string    `json:"blobUuid" gorm:"type:char(36) not null"`
+	Md5        string    `json:"md5" gorm:"type:varchar(45)"`
+	Size       int64     `json:"size" gorm:"type:bigint(20) not null;default:0"`
+	Current    bool      `json:"current" gorm:"-"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+// buckets of the photo timeline.
+const (
+	PHOTO_GRANULARITY_YEAR  = "year"
+	PHOTO_GRANULARITY_MONTH = "month"
+	PHOTO_GRANULARITY_DAY   = "day"
+)
+
+// length of the bucket key. eg. 2024, 2024-05, 2024-05-01
+var PHOTO_GRANULARITY_KEY_LENGTHS = map[string]int{
+	PHOTO_GRANULARITY_YEAR:  4,
+	PHOTO_GRANULARITY_MONTH: 7,
+	PHOTO_GRANULARITY_DAY:   10,
+}
+
+// resize parameter of thumbnails when not specified.
+const PHOTO_DEFAULT_IR = "fill_200_200"
+
+/**
+ * a bucket of the photo timeline.
+ */
+type PhotoBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+/**
+ * an image matter with its capture time and thumbnail.
+ */
+type Photo struct {
+	Matter *Matter `json:"matter"`
+	//capture time in the metadata, or the upload time if unknown.
+	TakenTime    time.Time `json:"takenTime"`
+	ThumbnailUrl string    `json:"thumbnailUrl"`
+}
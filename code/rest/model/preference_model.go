@@ -2,27 +2,32 @@ package model
 
 import (
 	jsoniter "github.com/json-iterator/go"
+	"strings"
 	"time"
 )
 
 type Preference struct {
-	Uuid                  string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
-	Sort                  int64     `json:"sort" gorm:"type:bigint(20) not null"`
-	UpdateTime            time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
-	CreateTime            time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
-	Name                  string    `json:"name" gorm:"type:varchar(45)"`
-	LogoUrl               string    `json:"logoUrl" gorm:"type:varchar(255)"`
-	FaviconUrl            string    `json:"faviconUrl" gorm:"type:varchar(255)"`
-	Copyright             string    `json:"copyright" gorm:"type:varchar(1024)"`
-	Record                string    `json:"record" gorm:"type:varchar(1024)"`
-	DownloadDirMaxSize    int64     `json:"downloadDirMaxSize" gorm:"type:bigint(20) not null;default:-1"`
-	DownloadDirMaxNum     int64     `json:"downloadDirMaxNum" gorm:"type:bigint(20) not null;default:-1"`
-	DefaultTotalSizeLimit int64     `json:"defaultTotalSizeLimit" gorm:"type:bigint(20) not null;default:-1"`
-	AllowRegister         bool      `json:"allowRegister" gorm:"type:tinyint(1) not null;default:0"`
-	PreviewConfig         string    `json:"previewConfig" gorm:"type:text"`
-	ScanConfig            string    `json:"scanConfig" gorm:"type:text"`
-	DeletedKeepDays       int64     `json:"deletedKeepDays" gorm:"type:bigint(20) not null;default:7"`
-	Version               string    `json:"version" gorm:"-"`
+	Uuid                   string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort                   int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime             time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime             time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	Name                   string    `json:"name" gorm:"type:varchar(45)"`
+	LogoUrl                string    `json:"logoUrl" gorm:"type:varchar(255)"`
+	FaviconUrl             string    `json:"faviconUrl" gorm:"type:varchar(255)"`
+	Copyright              string    `json:"copyright" gorm:"type:varchar(1024)"`
+	Record                 string    `json:"record" gorm:"type:varchar(1024)"`
+	DownloadDirMaxSize     int64     `json:"downloadDirMaxSize" gorm:"type:bigint(20) not null;default:-1"`
+	DownloadDirMaxNum      int64     `json:"downloadDirMaxNum" gorm:"type:bigint(20) not null;default:-1"`
+	DefaultTotalSizeLimit  int64     `json:"defaultTotalSizeLimit" gorm:"type:bigint(20) not null;default:-1"`
+	AllowRegister          bool      `json:"allowRegister" gorm:"type:tinyint(1) not null;default:0"`
+	PreviewConfig          string    `json:"previewConfig" gorm:"type:text"`
+	ScanConfig             string    `json:"scanConfig" gorm:"type:text"`
+	DeletedKeepDays        int64     `json:"deletedKeepDays" gorm:"type:bigint(20) not null;default:7"`
+	RecentKeepDays         int64     `json:"recentKeepDays" gorm:"type:bigint(20) not null;default:30"`
+	ThumbnailPresets       string    `json:"thumbnailPresets" gorm:"type:varchar(1024) not null;default:''"`
+	ImageCacheMaxSize      int64     `json:"imageCacheMaxSize" gorm:"type:bigint(20) not null;default:-1"`
+	ImageCacheSpaceMaxSize int64     `json:"imageCacheSpaceMaxSize" gorm:"type:bigint(20) not null;default:-1"`
+	Version                string    `json:"version" gorm:"-"`
 }
 
 const (
@@ -42,6 +47,10 @@ type ScanConfig struct {
 	SpaceNames []string `json:"spaceNames"`
 	//scan scope. see SCAN_SCOPE
 	Scope string `json:"scope"`
+	//a scan removes nothing if it would remove more than this percentage of a space's matters on disk. eg. the mount is gone. 0 means no limit.
+	MaxRemovePercent int64 `json:"maxRemovePercent"`
+	//spaces whose root directories are watched. changes are synced in a few seconds. linux and local storage only.
+	WatchSpaceNames []string `json:"watchSpaceNames"`
 }
 
 // fetch the scan config
@@ -63,3 +72,14 @@ func (this *Preference) FetchScanConfig() *ScanConfig {
 		return m
 	}
 }
+
+// the ir of thumbnails generated right after upload. eg. fill_200_200,fit_800_
+func (this *Preference) FetchThumbnailPresets() []string {
+	var presets []string
+	for _, preset := range strings.Split(this.ThumbnailPresets, ",") {
+		if preset = strings.TrimSpace(preset); preset != "" {
+			presets = append(presets, preset)
+		}
+	}
+	return presets
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	//previewed a file.
+	RECENT_ACTION_OPEN = "OPEN"
+	//downloaded a file.
+	RECENT_ACTION_DOWNLOAD = "DOWNLOAD"
+	//uploaded a new file.
+	RECENT_ACTION_UPLOAD = "UPLOAD"
+	//overwrote the content of a file.
+	RECENT_ACTION_EDIT = "EDIT"
+)
+
+/**
+ * a file recently used by a user. one record for one user and one matter, the latest action wins.
+ */
+type Recent struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	UserUuid   string    `json:"userUuid" gorm:"type:char(36) not null;uniqueIndex:idx_recent_uu_mu"`
+	MatterUuid string    `json:"matterUuid" gorm:"type:char(36) not null;uniqueIndex:idx_recent_uu_mu;index:idx_recent_mu"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_recent_su"`
+	Action     string    `json:"action" gorm:"type:varchar(45) not null"`
+	VisitTime  time.Time `json:"visitTime" gorm:"type:timestamp not null;index:idx_recent_vt;default:'2018-01-01 00:00:00'"`
+	Matter     *Matter   `json:"matter" gorm:"-"`
+}
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"time"
+)
+
+// what the scan did to an entry.
+const (
+	SCAN_ACTION_CREATE = "CREATE"
+	SCAN_ACTION_UPDATE = "UPDATE"
+	SCAN_ACTION_RENAME = "RENAME"
+	SCAN_ACTION_REMOVE = "REMOVE"
+)
+
+// at most these entries are kept in a report. the counts are always complete.
+const SCAN_REPORT_MAX_ENTRIES = 1000
+
+/**
+ * a changed entry found by the scan.
+ */
+type ScanEntry struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	//path before renamed.
+	OldPath string `json:"oldPath"`
+	Dir     bool   `json:"dir"`
+	Size    int64  `json:"size"`
+	//size before updated.
+	OldSize int64 `json:"oldSize"`
+}
+
+/**
+ * result of scanning the physics files of a space. the last scan and the last dry run of every space are kept.
+ */
+type ScanReport struct {
+	//used to apply a dry run.
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_scan_report_su"`
+	SpaceName  string    `json:"spaceName" gorm:"type:varchar(100)"`
+	//full scan reads every directory, otherwise the unchanged directories are skipped.
+	Full bool `json:"full" gorm:"type:tinyint(1) not null;default:0"`
+	//dry run only computes the changes. nothing is written.
+	DryRun       bool      `json:"dryRun" gorm:"type:tinyint(1) not null;default:0"`
+	StartTime    time.Time `json:"startTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	EndTime      time.Time `json:"endTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	CreatedCount int64     `json:"createdCount" gorm:"type:bigint(20) not null;default:0"`
+	UpdatedCount int64     `json:"updatedCount" gorm:"type:bigint(20) not null;default:0"`
+	RenamedCount int64     `json:"renamedCount" gorm:"type:bigint(20) not null;default:0"`
+	RemovedCount int64     `json:"removedCount" gorm:"type:bigint(20) not null;default:0"`
+	//sizes of the created files, the updated files and the removed matters.
+	CreatedSize int64 `json:"createdSize" gorm:"type:bigint(20) not null;default:0"`
+	UpdatedSize int64 `json:"updatedSize" gorm:"type:bigint(20) not null;default:0"`
+	RemovedSize int64 `json:"removedSize" gorm:"type:bigint(20) not null;default:0"`
+	//percentage of the matters on disk to remove, including the ones in the removed directories.
+	RemovePercent int64 `json:"removePercent" gorm:"type:bigint(20) not null;default:0"`
+	//nothing is removed because RemovePercent exceeds the max remove percent.
+	Aborted bool `json:"aborted" gorm:"type:tinyint(1) not null;default:0"`
+	//directories not read because their modify time is unchanged.
+	SkippedDirCount int64 `json:"skippedDirCount" gorm:"type:bigint(20) not null;default:0"`
+	//entries failed to sync. see the log.
+	ErrorCount int64        `json:"errorCount" gorm:"type:bigint(20) not null;default:0"`
+	Entries    []*ScanEntry `json:"entries" gorm:"-"`
+	//json of Entries
+	EntriesJson string `json:"-" gorm:"type:mediumtext"`
+	//json of the plan keys
+	PlanJson string `json:"-" gorm:"type:mediumtext"`
+
+	//all the changes of a dry run. Entries may be truncated.
+	plan map[string]bool
+}
+
+func scanPlanKey(action string, path string, oldPath string) string {
+	return fmt.Sprintf("%s:%s:%s", action, oldPath, path)
+}
+
+// record a changed entry. oldSize is only for updates.
+func (this *ScanReport) Add(action string, matter *Matter, oldPath string, oldSize int64) {
+	switch action {
+	case SCAN_ACTION_CREATE:
+		this.CreatedCount++
+		this.CreatedSize += matter.Size
+	case SCAN_ACTION_UPDATE:
+		this.UpdatedCount++
+		this.UpdatedSize += matter.Size
+	case SCAN_ACTION_RENAME:
+		this.RenamedCount++
+	case SCAN_ACTION_REMOVE:
+		this.RemovedCount++
+		this.RemovedSize += matter.Size
+	}
+	if len(this.Entries) < SCAN_REPORT_MAX_ENTRIES {
+		this.Entries = append(this.Entries, &ScanEntry{Action: action, Path: matter.Path, OldPath: oldPath, Dir: matter.Dir, Size: matter.Size, OldSize: oldSize})
+	}
+	if this.DryRun {
+		if this.plan == nil {
+			this.plan = make(map[string]bool)
+		}
+		this.plan[scanPlanKey(action, matter.Path, oldPath)] = true
+	}
+}
+
+// nothing is changed and nothing went wrong.
+func (this *ScanReport) Empty() bool {
+	return this.CreatedCount == 0 && this.UpdatedCount == 0 && this.RenamedCount == 0 && this.RemovedCount == 0 && this.ErrorCount == 0 && !this.Aborted
+}
+
+// whether the change is found by the dry run.
+func (this *ScanReport) Planned(action string, path string, oldPath string) bool {
+	return this.plan[scanPlanKey(action, path, oldPath)]
+}
+
+// fill EntriesJson and PlanJson before saved.
+func (this *ScanReport) Pack() {
+	bytes, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(this.Entries)
+	if err != nil {
+		panic(err)
+	}
+	this.EntriesJson = string(bytes)
+
+	keys := make([]string, 0, len(this.plan))
+	for key := range this.plan {
+		keys = append(keys, key)
+	}
+	bytes, err = jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(keys)
+	if err != nil {
+		panic(err)
+	}
+	this.PlanJson = string(bytes)
+}
+
+// restore Entries and the plan after loaded.
+func (this *ScanReport) Unpack() {
+	this.Entries = []*ScanEntry{}
+	if this.EntriesJson != "" {
+		err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal([]byte(this.EntriesJson), &this.Entries)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var keys []string
+	if this.PlanJson != "" {
+		err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal([]byte(this.PlanJson), &keys)
+		if err != nil {
+			panic(err)
+		}
+	}
+	this.plan = make(map[string]bool)
+	for _, key := range keys {
+		this.plan[key] = true
+	}
+}
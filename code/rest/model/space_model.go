@@ -9,6 +9,11 @@ const (
 	SPACE_TYPE_PRIVATE = "PRIVATE"
 	//group shared space.
 	SPACE_TYPE_SHARED = "SHARED"
+
+	//history versions kept for a file by default.
+	SPACE_DEFAULT_VERSION_LIMIT = 10
+	//days to keep a history version by default.
+	SPACE_DEFAULT_VERSION_KEEP_DAYS = 30
 )
 
 /**
@@ -24,6 +29,10 @@ type Space struct {
 	SizeLimit      int64     `json:"sizeLimit" gorm:"type:bigint(20) not null;default:-1"`
 	TotalSizeLimit int64     `json:"totalSizeLimit" gorm:"type:bigint(20) not null;default:-1"`
 	TotalSize      int64     `json:"totalSize" gorm:"type:bigint(20) not null;default:0"`
-	Type           string    `json:"type" gorm:"type:varchar(45)"`
-	User           *User     `json:"user" gorm:"-"`
+	//max history versions of a file. -1 means no limit. 0 means no history.
+	VersionLimit int64 `json:"versionLimit" gorm:"type:bigint(20) not null;default:10"`
+	//days to keep a version after it's replaced. -1 means forever.
+	VersionKeepDays int64  `json:"versionKeepDays" gorm:"type:bigint(20) not null;default:30"`
+	Type            string `json:"type" gorm:"type:varchar(45)"`
+	User            *User  `json:"user" gorm:"-"`
 }
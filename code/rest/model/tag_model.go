@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	//a matter should have all the tags.
+	TAG_MODE_AND = "and"
+	//a matter should have any of the tags.
+	TAG_MODE_OR = "or"
+)
+
+/**
+ * a tag in the vocabulary of a space.
+ */
+type Tag struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;uniqueIndex:idx_tag_su_name"`
+	UserUuid   string    `json:"userUuid" gorm:"type:char(36) not null"`
+	Name       string    `json:"name" gorm:"type:varchar(45) not null;uniqueIndex:idx_tag_su_name"`
+	//number of the undeleted matters with this tag.
+	MatterCount int64 `json:"matterCount" gorm:"-"`
+}
+
+/**
+ * the link table for Tag and Matter.
+ */
+type MatterTag struct {
+	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
+	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
+	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
+	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36) not null;index:idx_matter_tag_su"`
+	TagUuid    string    `json:"tagUuid" gorm:"type:char(36) not null;uniqueIndex:idx_matter_tag_tu_mu"`
+	MatterUuid string    `json:"matterUuid" gorm:"type:char(36) not null;uniqueIndex:idx_matter_tag_tu_mu;index:idx_matter_tag_mu"`
+}
@@ -0,0 +1,36 @@
+package model
+
+// lines returned when neither lines nor a byte range is given.
+const TEXT_PREVIEW_DEFAULT_LINES = 100
+
+// at most these lines in one preview.
+const TEXT_PREVIEW_MAX_LINES = 10000
+
+// at most these bytes are read in one preview.
+const TEXT_PREVIEW_MAX_BYTES = 1024 * 1024
+
+// bytes used to detect the encoding.
+const TEXT_PREVIEW_DETECT_BYTES = 64 * 1024
+
+// the total lines are only counted for the files not larger than this.
+const TEXT_PREVIEW_COUNT_MAX_SIZE = 16 * 1024 * 1024
+
+/**
+ * a piece of a text matter transcoded to UTF-8.
+ */
+type TextPreview struct {
+	//the detected encoding. eg. UTF-8 GBK Big5
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+	//the bytes of the content in the file. next piece starts from offset + length.
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+	//size of the file.
+	Size int64 `json:"size"`
+	//lines of the content.
+	LineCount int64 `json:"lineCount"`
+	//lines of the file. -1 if the file is too large to count.
+	TotalLineCount int64 `json:"totalLineCount"`
+	//whether the content reaches the end of the file.
+	Eof bool `json:"eof"`
+}
@@ -4,17 +4,31 @@ import (
 	"time"
 )
 
+const (
+	//dir under space's cache dir, holds the partial data of resumable uploads.
+	UPLOAD_PARTIAL_DIR = "upload"
+	//how long a resumable upload can be resumed.
+	UPLOAD_RESUMABLE_EXPIRE = 7 * 24 * time.Hour
+)
+
 type UploadToken struct {
 	Uuid       string    `json:"uuid" gorm:"type:char(36);primary_key;unique"`
 	Sort       int64     `json:"sort" gorm:"type:bigint(20) not null"`
 	UpdateTime time.Time `json:"updateTime" gorm:"type:timestamp not null;default:CURRENT_TIMESTAMP"`
 	CreateTime time.Time `json:"createTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
 	UserUuid   string    `json:"userUuid" gorm:"type:char(36) not null"`
+	SpaceUuid  string    `json:"spaceUuid" gorm:"type:char(36)"`
 	FolderUuid string    `json:"folderUuid" gorm:"type:char(36) not null"`
 	MatterUuid string    `json:"matterUuid" gorm:"type:char(36) not null"`
 	ExpireTime time.Time `json:"expireTime" gorm:"type:timestamp not null;default:'2018-01-01 00:00:00'"`
 	Filename   string    `json:"filename" gorm:"type:varchar(255) not null"`
 	Privacy    bool      `json:"privacy" gorm:"type:tinyint(1) not null;default:0"`
 	Size       int64     `json:"size" gorm:"type:bigint(20) not null;default:0"`
+	Offset     int64     `json:"offset" gorm:"type:bigint(20) not null;default:0"`
 	Ip         string    `json:"ip" gorm:"type:varchar(128) not null"`
 }
+
+// get the partial data path of a resumable upload.
+func GetUploadPartialPath(spaceName string, uploadTokenUuid string) string {
+	return GetSpaceCacheRootDir(spaceName) + "/" + UPLOAD_PARTIAL_DIR + "/" + uploadTokenUuid
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// max length of an album's name.
+const ALBUM_NAME_MAX_LENGTH = 255
+
+// @Service
+type AlbumService struct {
+	bean.BaseBean
+	albumDao       *dao.AlbumDao
+	albumMatterDao *dao.AlbumMatterDao
+	photoDao       *dao.PhotoDao
+	photoService   *PhotoService
+}
+
+func (this *AlbumService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.albumDao)
+	if b, ok := b.(*dao.AlbumDao); ok {
+		this.albumDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.albumMatterDao)
+	if b, ok := b.(*dao.AlbumMatterDao); ok {
+		this.albumMatterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.photoDao)
+	if b, ok := b.(*dao.PhotoDao); ok {
+		this.photoDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.photoService)
+	if b, ok := b.(*PhotoService); ok {
+		this.photoService = b
+	}
+
+}
+
+// trim and check the album's name.
+func (this *AlbumService) CheckAlbumName(request *http.Request, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		panic(result.BadRequest("album name cannot be empty."))
+	}
+	if utf8.RuneCountInString(name) > ALBUM_NAME_MAX_LENGTH {
+		panic(result.BadRequest("album name cannot be longer than %d.", ALBUM_NAME_MAX_LENGTH))
+	}
+	return name
+}
+
+// list the albums of a space with the number of matters.
+func (this *AlbumService) List(request *http.Request, space *model.Space) []*model.Album {
+
+	albums := this.albumDao.ListBySpaceUuid(space.Uuid)
+	countMap := this.albumMatterDao.CountBySpaceUuid(space.Uuid)
+	for _, album := range albums {
+		album.MatterCount = countMap[album.Uuid]
+	}
+
+	return albums
+}
+
+func (this *AlbumService) Create(request *http.Request, user *model.User, space *model.Space, name string) *model.Album {
+
+	album := &model.Album{
+		SpaceUuid: space.Uuid,
+		UserUuid:  user.Uuid,
+		Name:      this.CheckAlbumName(request, name),
+	}
+	return this.albumDao.Create(album)
+}
+
+func (this *AlbumService) Rename(request *http.Request, album *model.Album, name string) *model.Album {
+	album.Name = this.CheckAlbumName(request, name)
+	return this.albumDao.Save(album)
+}
+
+// delete an album. the matters are not touched.
+func (this *AlbumService) Delete(request *http.Request, album *model.Album) {
+	this.albumDao.Delete(album)
+}
+
+// put matters into an album. directories cannot be put.
+func (this *AlbumService) Add(request *http.Request, album *model.Album, matters []*model.Matter) {
+
+	for _, matter := range matters {
+		if matter.Dir {
+			panic(result.BadRequest("directory %s cannot be put into an album.", matter.Name))
+		}
+	}
+
+	for _, matter := range matters {
+		if this.albumMatterDao.FindByAlbumUuidAndMatterUuid(album.Uuid, matter.Uuid) == nil {
+			this.albumMatterDao.Create(&model.AlbumMatter{
+				SpaceUuid:  album.SpaceUuid,
+				AlbumUuid:  album.Uuid,
+				MatterUuid: matter.Uuid,
+			})
+		}
+	}
+}
+
+// take matters out of an album.
+func (this *AlbumService) Remove(request *http.Request, album *model.Album, matters []*model.Matter) {
+	for _, matter := range matters {
+		this.albumMatterDao.DeleteByAlbumUuidAndMatterUuid(album.Uuid, matter.Uuid)
+	}
+}
+
+// page the photos of an album. latest added first.
+func (this *AlbumService) PagePhotos(request *http.Request, page int, pageSize int, album *model.Album) *model.Pager {
+
+	ir := this.photoService.CheckIr(request)
+
+	count, matters := this.photoDao.PageByAlbumUuid(page, pageSize, album.Uuid)
+
+	return model.NewPager(page, pageSize, count, this.photoService.Wrap(request, matters, ir))
+}
@@ -5,8 +5,10 @@ import (
 	"box/code/rest/bean"
 	"box/code/rest/dao"
 	"box/code/rest/model"
+	"box/code/tool/archive"
 	"box/code/tool/result"
-	"fmt"
+	"box/code/tool/util"
+	"github.com/json-iterator/go"
 	"net/http"
 	"time"
 )
@@ -23,6 +25,7 @@ type AlienService struct {
 	imageCacheDao     *dao.ImageCacheDao
 	imageCacheService *ImageCacheService
 	spaceService      *SpaceService
+	recentService     *RecentService
 }
 
 func (this *AlienService) Init() {
@@ -71,6 +74,10 @@ func (this *AlienService) Init() {
 	if c, ok := b.(*SpaceService); ok {
 		this.spaceService = c
 	}
+	b = core.CONTEXT.GetBean(this.recentService)
+	if c, ok := b.(*RecentService); ok {
+		this.recentService = c
+	}
 }
 
 // check whether the request params ok.
@@ -136,29 +143,49 @@ func (this *AlienService) PreviewOrDownload(
 	matter *model.Matter,
 	withContentDisposition bool,
 ) {
-	//download directory
-	if matter.Dir {
+	//browse an archive without extracting. a listing is not an open.
+	if util.ExtractRequestOptionalBool(request, "archiveList", false) {
+		this.writeJson(writer, this.matterService.ListArchive(matter))
+		return
+	}
+
+	if entryPath := request.FormValue("archiveEntry"); entryPath != "" {
 
-		this.matterService.DownloadZip(writer, request, []*model.Matter{matter})
+		this.matterService.DownloadArchiveEntry(writer, request, matter, entryPath, withContentDisposition)
+
+	} else if matter.Dir {
+		//download directory
+
+		format := util.ExtractRequestOptionalString(request, "format", archive.FORMAT_ZIP)
+		this.matterService.DownloadArchive(writer, request, []*model.Matter{matter}, format)
 
 	} else {
 
 		//handle the image operation.
-		needProcess, imageResizeM, imageResizeW, imageResizeH := this.imageCacheService.ResizeParams(request)
+		needProcess, process := this.imageCacheService.ProcessParams(request)
 		if needProcess {
 
 			//if image, try to use cache.
-			mode := fmt.Sprintf("%s_%d_%d", imageResizeM, imageResizeW, imageResizeH)
-			imageCache := this.imageCacheDao.FindByMatterUuidAndMode(matter.Uuid, mode)
+			imageCache := this.imageCacheService.FindFreshByMatterAndMode(matter, process.Mode())
 			if imageCache == nil {
-				imageCache = this.imageCacheService.cacheImage(writer, request, matter)
+				this.imageCacheService.Miss()
+				imageCache = this.imageCacheService.cacheImage(writer, request, matter, process)
+			} else {
+				this.imageCacheService.Hit(imageCache)
 			}
 
 			//download the cache image file.
 			this.matterService.DownloadFile(writer, request, model.GetSpaceCacheRootDir(imageCache.Username)+imageCache.Path, imageCache.Name, withContentDisposition)
 
 		} else {
-			this.matterService.DownloadFile(writer, request, matter.AbsolutePath(), matter.Name, withContentDisposition)
+			this.matterService.DownloadMatter(writer, request, matter, withContentDisposition)
+
+			//only the original content counts. thumbnails and archive entries are not opens.
+			action := model.RECENT_ACTION_OPEN
+			if withContentDisposition {
+				action = model.RECENT_ACTION_DOWNLOAD
+			}
+			this.recentService.Record(request, this.FindUser(request), matter, action)
 		}
 
 	}
@@ -168,3 +195,14 @@ func (this *AlienService) PreviewOrDownload(
 		this.matterDao.TimesIncrement(matter.Uuid)
 	})
 }
+
+// response a success result in json.
+func (this *AlienService) writeJson(writer http.ResponseWriter, data interface{}) {
+
+	b, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(&result.WebResult{Code: result.OK.Code, Data: data})
+	this.PanicError(err)
+
+	writer.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_, err = writer.Write(b)
+	this.PanicError(err)
+}
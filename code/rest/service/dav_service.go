@@ -32,6 +32,7 @@ type DavService struct {
 	bean.BaseBean
 	matterDao     *dao.MatterDao
 	matterService *MatterService
+	recentService *RecentService
 	lockSystem    webdav.LockSystem
 }
 
@@ -48,6 +49,11 @@ func (this *DavService) Init() {
 		this.matterService = b
 	}
 
+	b = core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*RecentService); ok {
+		this.recentService = b
+	}
+
 	// init the webdav lock system.
 	this.lockSystem = webdav.NewMemLS()
 }
@@ -313,7 +319,11 @@ func (this *DavService) HandleGetHeadPost(writer http.ResponseWriter, request *h
 	}
 
 	//download a file.
-	this.matterService.DownloadFile(writer, request, matter.AbsolutePath(), matter.Name, false)
+	this.matterService.DownloadMatter(writer, request, matter, false)
+
+	if request.Method == http.MethodGet {
+		this.recentService.Record(request, user, matter, model.RECENT_ACTION_DOWNLOAD)
+	}
 
 }
 
@@ -344,13 +354,21 @@ func (this *DavService) HandlePut(writer http.ResponseWriter, request *http.Requ
 
 	dirMatter := this.matterDao.CheckWithRootByPath(dirPath, user, space)
 
-	//if exist delete it.
+	//if exist, overwrite it and keep the old content as a version.
 	srcMatter := this.matterDao.FindByUserUuidAndPath(user.Uuid, subPath)
+	if srcMatter != nil && !srcMatter.Dir && !srcMatter.Deleted {
+		srcMatter = this.matterService.AtomicOverwrite(request, srcMatter, request.Body, user, space)
+
+		//existing resource modified. (RFC7231:4.3.4)
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
 	if srcMatter != nil {
 		this.matterService.AtomicDelete(request, srcMatter, user, space)
 	}
 
-	this.matterService.Upload(request, request.Body, nil, user, space, dirMatter, filename, true)
+	matter := this.matterService.Upload(request, request.Body, nil, user, space, dirMatter, filename, true)
+	this.recentService.Record(request, user, matter, model.RECENT_ACTION_UPLOAD)
 
 	//set the status code 201
 	writer.WriteHeader(http.StatusCreated)
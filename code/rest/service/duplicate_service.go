@@ -0,0 +1,105 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"net/http"
+)
+
+// @Service
+type DuplicateService struct {
+	bean.BaseBean
+	duplicateDao  *dao.DuplicateDao
+	spaceDao      *dao.SpaceDao
+	matterDao     *dao.MatterDao
+	matterService *MatterService
+}
+
+func (this *DuplicateService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.duplicateDao)
+	if b, ok := b.(*dao.DuplicateDao); ok {
+		this.duplicateDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.spaceDao)
+	if b, ok := b.(*dao.SpaceDao); ok {
+		this.spaceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+}
+
+// page the groups of duplicate files with their copies. spaceUuid empty means all the spaces.
+func (this *DuplicateService) Page(request *http.Request, page int, pageSize int, spaceUuid string, minSize int64) *model.Pager {
+
+	count, groups := this.duplicateDao.PageGroups(page, pageSize, spaceUuid, minSize)
+
+	md5s := make([]string, 0, len(groups))
+	groupMap := make(map[string]*model.DuplicateGroup)
+	for _, group := range groups {
+		md5s = append(md5s, group.Md5)
+		group.Matters = []*model.Matter{}
+		groupMap[group.Md5] = group
+	}
+	for _, matter := range this.duplicateDao.ListByMd5s(md5s, spaceUuid) {
+		if group, ok := groupMap[matter.Md5]; ok {
+			group.Matters = append(group.Matters, matter)
+		}
+	}
+
+	return model.NewPager(page, pageSize, count, groups)
+}
+
+// keep the chosen copies and soft delete the other copies of the same contents. spaceUuid empty means all the spaces.
+func (this *DuplicateService) Clean(request *http.Request, user *model.User, spaceUuid string, keepUuids []string) []*model.Matter {
+
+	keepMap := make(map[string]bool)
+	var md5s []string
+	for _, uuid := range keepUuids {
+		matter := this.matterDao.CheckByUuid(uuid)
+		if spaceUuid != "" && matter.SpaceUuid != spaceUuid {
+			panic(result.UNAUTHORIZED)
+		}
+		if matter.Dir || matter.Deleted || matter.Md5 == "" {
+			panic(result.BadRequest("%s is not a file with content hash.", matter.Name))
+		}
+		if _, ok := keepMap[matter.Uuid]; ok {
+			continue
+		}
+		keepMap[matter.Uuid] = true
+		md5s = append(md5s, matter.Md5)
+	}
+
+	var deletedMatters []*model.Matter
+	spaceMap := make(map[string]*model.Space)
+	for _, matter := range this.duplicateDao.ListByMd5s(md5s, spaceUuid) {
+		if keepMap[matter.Uuid] {
+			continue
+		}
+		space, ok := spaceMap[matter.SpaceUuid]
+		if !ok {
+			space = this.spaceDao.CheckByUuid(matter.SpaceUuid)
+			spaceMap[matter.SpaceUuid] = space
+		}
+		this.matterService.AtomicSoftDelete(request, matter, user, space)
+		deletedMatters = append(deletedMatters, matter)
+	}
+
+	this.Logger.Info("%s kept %d files and deleted %d duplicates.", user.Username, len(keepMap), len(deletedMatters))
+
+	return deletedMatters
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"net/http"
+)
+
+// @Service
+type FavoriteService struct {
+	bean.BaseBean
+	favoriteDao   *dao.FavoriteDao
+	matterDao     *dao.MatterDao
+	matterService *MatterService
+}
+
+func (this *FavoriteService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.favoriteDao)
+	if b, ok := b.(*dao.FavoriteDao); ok {
+		this.favoriteDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+}
+
+// star a matter. starring twice makes no difference.
+func (this *FavoriteService) Star(request *http.Request, user *model.User, matter *model.Matter) *model.Favorite {
+
+	favorite := this.favoriteDao.FindByUserUuidAndMatterUuid(user.Uuid, matter.Uuid)
+	if favorite != nil {
+		return favorite
+	}
+
+	favorite = &model.Favorite{
+		UserUuid:   user.Uuid,
+		MatterUuid: matter.Uuid,
+		SpaceUuid:  matter.SpaceUuid,
+	}
+	return this.favoriteDao.Create(favorite)
+}
+
+func (this *FavoriteService) Unstar(request *http.Request, user *model.User, matterUuid string) {
+
+	favorite := this.favoriteDao.FindByUserUuidAndMatterUuid(user.Uuid, matterUuid)
+	if favorite != nil {
+		this.favoriteDao.Delete(favorite)
+	}
+}
+
+// page a user's favorites. each matter is wrapped with its parents to show the location.
+func (this *FavoriteService) Page(request *http.Request, page int, pageSize int, user *model.User, spaceUuid string, sortArray []builder.OrderPair) *model.Pager {
+
+	count, favorites := this.favoriteDao.PlainPage(page, pageSize, user, spaceUuid, sortArray)
+	for _, favorite := range favorites {
+		matter := this.matterDao.FindByUuid(favorite.MatterUuid)
+		if matter != nil {
+			favorite.Matter = this.matterService.WrapParentDetail(request, matter)
+		}
+	}
+
+	return model.NewPager(page, pageSize, count, favorites)
+}
@@ -7,22 +7,71 @@ import (
 	"box/code/rest/model"
 	"box/code/tool/result"
 	"box/code/tool/util"
-	"fmt"
 	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
 	"image"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// only these image can be processed. extension -> default output format
+var IMAGE_CACHE_FORMATS = map[string]imaging.Format{
+	".jpg":  imaging.JPEG,
+	".jpeg": imaging.JPEG,
+	".png":  imaging.PNG,
+	".tif":  imaging.TIFF,
+	".tiff": imaging.TIFF,
+	".bmp":  imaging.BMP,
+	".gif":  imaging.GIF,
+	//webp can be decoded but not encoded.
+	".webp": imaging.PNG,
+}
+
+// the formats can be encoded. extension without dot -> format
+var IMAGE_CACHE_OUTPUT_FORMATS = map[string]imaging.Format{
+	"jpg":  imaging.JPEG,
+	"jpeg": imaging.JPEG,
+	"png":  imaging.PNG,
+	"tif":  imaging.TIFF,
+	"tiff": imaging.TIFF,
+	"bmp":  imaging.BMP,
+	"gif":  imaging.GIF,
+}
+
+// number of the workers generating thumbnail presets.
+const IMAGE_CACHE_WORKER_NUM = 2
+
+// capacity of the presets queue. new tasks are dropped when full, the backfill can pick them up later.
+const IMAGE_CACHE_QUEUE_SIZE = 1024
+
+// number of caches evicted in one batch.
+const IMAGE_CACHE_EVICT_BATCH = 100
+
 // @Service
 type ImageCacheService struct {
 	bean.BaseBean
-	imageCacheDao *dao.ImageCacheDao
-	userDao       *dao.UserDao
-	matterDao     *dao.MatterDao
+	imageCacheDao     *dao.ImageCacheDao
+	userDao           *dao.UserDao
+	matterDao         *dao.MatterDao
+	preferenceService *PreferenceService
+	//uuids of the matters to generate presets.
+	queue chan string
+	//guard the backfill.
+	backfillMutex sync.Mutex
+	backfill      model.ImageCacheBackfill
+	//wake up the evictor. at most one pending signal.
+	evictSignal chan struct{}
+	//statistics since the application started.
+	hits      int64
+	misses    int64
+	evictions int64
 }
 
 func (this *ImageCacheService) Init() {
@@ -43,6 +92,38 @@ func (this *ImageCacheService) Init() {
 		this.matterDao = b
 	}
 
+	b = core.CONTEXT.GetBean(this.preferenceService)
+	if b, ok := b.(*PreferenceService); ok {
+		this.preferenceService = b
+	}
+
+	this.queue = make(chan string, IMAGE_CACHE_QUEUE_SIZE)
+	this.evictSignal = make(chan struct{}, 1)
+}
+
+func (this *ImageCacheService) Bootstrap() {
+
+	for i := 0; i < IMAGE_CACHE_WORKER_NUM; i++ {
+		go func() {
+			for matterUuid := range this.queue {
+				core.RunWithRecovery(func() {
+					matter := this.matterDao.FindByUuid(matterUuid)
+					if matter != nil && !matter.Deleted {
+						this.generatePresets(matter)
+					}
+				})
+			}
+		}()
+	}
+
+	go func() {
+		for range this.evictSignal {
+			core.RunWithRecovery(this.Evict)
+		}
+	}()
+
+	//the limits may have been lowered while the application was down.
+	this.TriggerEvict()
 }
 
 func (this *ImageCacheService) Detail(uuid string) *model.ImageCache {
@@ -52,75 +133,197 @@ func (this *ImageCacheService) Detail(uuid string) *model.ImageCache {
 	return imageCache
 }
 
-// prepare the resize parameters.
-func (this *ImageCacheService) ResizeParams(request *http.Request) (needProcess bool, resizeMode string, resizeWidth int, resizeHeight int) {
+// parse the resize parameter. mode_w_h  if w or h empty means not required.
+func (this *ImageCacheService) ParseIr(ir string) (resizeMode string, resizeWidth int, resizeHeight int) {
 	var err error
 
-	if request.FormValue("ir") != "" {
-		//mode_w_h  if w or h equal means not required.
-		imageResizeStr := request.FormValue("ir")
-		arr := strings.Split(imageResizeStr, "_")
-		if len(arr) != 3 {
-			panic(result.BadRequest("param error. the format is mode_w_h"))
-		}
-
-		imageResizeM := arr[0]
-		if imageResizeM == "" {
-			imageResizeM = "fit"
-		} else if imageResizeM != "fit" && imageResizeM != "fill" && imageResizeM != "fixed" {
-			panic(result.BadRequest("mode can only be fit/fill/fixed"))
-		}
-		imageResizeWStr := arr[1]
-		var imageResizeW int
-		if imageResizeWStr != "" {
-			imageResizeW, err = strconv.Atoi(imageResizeWStr)
-			this.PanicError(err)
-			if imageResizeW < 0 || imageResizeW > 4096 {
-				panic(result.BadRequest("zoom size cannot exceed 4096"))
-			}
+	arr := strings.Split(ir, "_")
+	if len(arr) != 3 {
+		panic(result.BadRequest("param error. the format is mode_w_h"))
+	}
+
+	imageResizeM := arr[0]
+	if imageResizeM == "" {
+		imageResizeM = "fit"
+	} else if imageResizeM != "fit" && imageResizeM != "fill" && imageResizeM != "fixed" {
+		panic(result.BadRequest("mode can only be fit/fill/fixed"))
+	}
+	imageResizeWStr := arr[1]
+	var imageResizeW int
+	if imageResizeWStr != "" {
+		imageResizeW, err = strconv.Atoi(imageResizeWStr)
+		this.PanicError(err)
+		if imageResizeW < 0 || imageResizeW > 4096 {
+			panic(result.BadRequest("zoom size cannot exceed 4096"))
+		}
+	}
+	imageResizeHStr := arr[2]
+	var imageResizeH int
+	if imageResizeHStr != "" {
+		imageResizeH, err = strconv.Atoi(imageResizeHStr)
+		this.PanicError(err)
+		if imageResizeH < 0 || imageResizeH > 4096 {
+			panic(result.BadRequest("zoom size cannot exceed 4096"))
+		}
+	}
+	return imageResizeM, imageResizeW, imageResizeH
+}
+
+// prepare the processing parameters. ir is mode_w_h, the others are optional.
+func (this *ImageCacheService) ProcessParams(request *http.Request) (needProcess bool, process *model.ImageProcess) {
+
+	process = &model.ImageProcess{}
+	if ir := request.FormValue("ir"); ir != "" {
+		process.ResizeMode, process.ResizeWidth, process.ResizeHeight = this.ParseIr(ir)
+	}
+
+	if format := strings.ToLower(request.FormValue("iformat")); format != "" {
+		if format == "webp" {
+			panic(result.BadRequest("webp cannot be encoded. use jpg, png or gif"))
+		}
+		if _, ok := IMAGE_CACHE_OUTPUT_FORMATS[format]; !ok {
+			panic(result.BadRequest("iformat can only be jpg/jpeg/png/gif/tif/tiff/bmp"))
+		}
+		process.Format = format
+	}
+
+	process.Quality = util.ExtractRequestOptionalInt(request, "iquality", 0)
+	if process.Quality < 0 || process.Quality > 100 {
+		panic(result.BadRequest("iquality should between 1 and 100"))
+	}
+
+	process.AutoOrient = util.ExtractRequestOptionalBool(request, "iorient", false)
+
+	process.Rotate = util.ExtractRequestOptionalInt(request, "irotate", 0) % 360
+	if process.Rotate != 0 && process.Rotate != 90 && process.Rotate != 180 && process.Rotate != 270 {
+		panic(result.BadRequest("irotate can only be 90/180/270"))
+	}
+
+	//x_y_w_h
+	if crop := request.FormValue("icrop"); crop != "" {
+		arr := strings.Split(crop, "_")
+		if len(arr) != 4 {
+			panic(result.BadRequest("param error. the format of icrop is x_y_w_h"))
 		}
-		imageResizeHStr := arr[2]
-		var imageResizeH int
-		if imageResizeHStr != "" {
-			imageResizeH, err = strconv.Atoi(imageResizeHStr)
-			this.PanicError(err)
-			if imageResizeH < 0 || imageResizeH > 4096 {
-				panic(result.BadRequest("zoom size cannot exceed 4096"))
+		var numbers [4]int
+		for i, str := range arr {
+			number, err := strconv.Atoi(str)
+			if err != nil || number < 0 {
+				panic(result.BadRequest("icrop should be non-negative integers"))
 			}
+			numbers[i] = number
 		}
-		return true, imageResizeM, imageResizeW, imageResizeH
-	} else {
-		return false, "", 0, 0
+		if numbers[2] == 0 || numbers[3] == 0 {
+			panic(result.BadRequest("width and height of icrop cannot be 0"))
+		}
+		process.CropX, process.CropY, process.CropWidth, process.CropHeight = numbers[0], numbers[1], numbers[2], numbers[3]
+	}
+
+	if blur := request.FormValue("iblur"); blur != "" {
+		sigma, err := strconv.ParseFloat(blur, 64)
+		if err != nil || sigma <= 0 || sigma > 50 {
+			panic(result.BadRequest("iblur should between 0 and 50"))
+		}
+		process.Blur = sigma
+	}
+
+	process.Grayscale = util.ExtractRequestOptionalBool(request, "igray", false)
+
+	return process.Mode() != "", process
+}
+
+// find the cache of the matter in the mode. the cache of a changed content is deleted and nil returned.
+func (this *ImageCacheService) FindFreshByMatterAndMode(matter *model.Matter, mode string) *model.ImageCache {
+	imageCache := this.imageCacheDao.FindByMatterUuidAndMode(matter.Uuid, mode)
+	if imageCache != nil && imageCache.ContentKey != matter.ContentKey() {
+		this.Logger.Info("content of %s is changed since image cache %s. delete it.", matter.Path, imageCache.Name)
+		this.imageCacheDao.Delete(imageCache)
+		return nil
 	}
+	return imageCache
+}
+
+// the matter is moved into another space. its caches follow it instead of being generated again.
+// invoke after the matter is saved.
+func (this *ImageCacheService) Relocate(matter *model.Matter) {
+	for _, imageCache := range this.imageCacheDao.FindByMatterUuid(matter.Uuid) {
+
+		//the cache file is named by the matter's path, which is taken by others now.
+		relativePath := util.GetSimpleFileName(matter.Path) + "_" + imageCache.Mode + filepath.Ext(imageCache.Path)
+		absolutePath := model.GetSpaceCacheRootDir(imageCache.Username) + relativePath
+		util.MakeDirAll(filepath.Dir(absolutePath))
+		err := os.Rename(imageCache.AbsolutePath(), absolutePath)
+		if err != nil {
+			this.Logger.Error("cannot relocate image cache %s. %v", imageCache.AbsolutePath(), err)
+			this.imageCacheDao.Delete(imageCache)
+			continue
+		}
+		util.DeleteEmptyDirRecursive(filepath.Dir(imageCache.AbsolutePath()))
+
+		imageCache.Path = relativePath
+		imageCache.SpaceUuid = matter.SpaceUuid
+		this.imageCacheDao.Save(imageCache)
+	}
+}
 
+// the process of only resizing.
+func (this *ImageCacheService) IrProcess(ir string) *model.ImageProcess {
+	process := &model.ImageProcess{}
+	process.ResizeMode, process.ResizeWidth, process.ResizeHeight = this.ParseIr(ir)
+	return process
 }
 
-// resize image.
-func (this *ImageCacheService) ResizeImage(request *http.Request, filePath string) *image.NRGBA {
+// decode and process the image. crop, rotate, resize, blur and grayscale in order.
+func (this *ImageCacheService) processImage(diskFile io.Reader, process *model.ImageProcess) image.Image {
 
-	diskFile, err := os.Open(filePath)
+	var dst image.Image
+	var err error
+	dst, err = imaging.Decode(diskFile, imaging.AutoOrientation(process.AutoOrient))
 	this.PanicError(err)
-	defer func() {
-		e := diskFile.Close()
-		this.PanicError(e)
-	}()
 
-	_, imageResizeM, imageResizeW, imageResizeH := this.ResizeParams(request)
+	if process.CropWidth > 0 {
+		bounds := dst.Bounds()
+		rect := image.Rect(process.CropX, process.CropY, process.CropX+process.CropWidth, process.CropY+process.CropHeight).Add(bounds.Min).Intersect(bounds)
+		if rect.Empty() {
+			panic(result.BadRequest("icrop is out of the image"))
+		}
+		dst = imaging.Crop(dst, rect)
+	}
+
+	//imaging rotates counterclockwise.
+	switch process.Rotate {
+	case 90:
+		dst = imaging.Rotate270(dst)
+	case 180:
+		dst = imaging.Rotate180(dst)
+	case 270:
+		dst = imaging.Rotate90(dst)
+	}
+
+	if process.ResizeMode != "" {
+		dst = this.resize(dst, process.ResizeMode, process.ResizeWidth, process.ResizeHeight)
+	}
+
+	if process.Blur > 0 {
+		dst = imaging.Blur(dst, process.Blur)
+	}
 
+	if process.Grayscale {
+		dst = imaging.Grayscale(dst)
+	}
+
+	return dst
+}
+
+func (this *ImageCacheService) resize(src image.Image, imageResizeM string, imageResizeW int, imageResizeH int) *image.NRGBA {
 	if imageResizeM == "fit" {
 		//fit mode.
 		if imageResizeW != 0 {
 			//eg. width = 100 height auto in proportion
-
-			src, err := imaging.Decode(diskFile)
-			this.PanicError(err)
 			return imaging.Resize(src, imageResizeW, 0, imaging.Lanczos)
 
 		} else if imageResizeH != 0 {
 			//eg. height = 100 width auto in proportion
-
-			src, err := imaging.Decode(diskFile)
-			this.PanicError(err)
 			return imaging.Resize(src, 0, imageResizeH, imaging.Lanczos)
 
 		} else {
@@ -129,8 +332,6 @@ func (this *ImageCacheService) ResizeImage(request *http.Request, filePath strin
 	} else if imageResizeM == "fill" {
 		//fill mode. specify the width and height
 		if imageResizeW > 0 && imageResizeH > 0 {
-			src, err := imaging.Decode(diskFile)
-			this.PanicError(err)
 			return imaging.Fill(src, imageResizeW, imageResizeH, imaging.Center, imaging.Lanczos)
 
 		} else {
@@ -139,8 +340,6 @@ func (this *ImageCacheService) ResizeImage(request *http.Request, filePath strin
 	} else if imageResizeM == "fixed" {
 		//fixed mode
 		if imageResizeW > 0 && imageResizeH > 0 {
-			src, err := imaging.Decode(diskFile)
-			this.PanicError(err)
 			return imaging.Resize(src, imageResizeW, imageResizeH, imaging.Lanczos)
 
 		} else {
@@ -152,31 +351,41 @@ func (this *ImageCacheService) ResizeImage(request *http.Request, filePath strin
 }
 
 // cache an image
-func (this *ImageCacheService) cacheImage(writer http.ResponseWriter, request *http.Request, matter *model.Matter) *model.ImageCache {
+func (this *ImageCacheService) cacheImage(writer http.ResponseWriter, request *http.Request, matter *model.Matter, process *model.ImageProcess) *model.ImageCache {
+	return this.CacheImage(matter, process)
+}
+
+// process an image and cache it.
+func (this *ImageCacheService) CacheImage(matter *model.Matter, process *model.ImageProcess) *model.ImageCache {
 
 	//only these image can do.
-	extension := util.GetExtension(matter.Name)
-	formats := map[string]imaging.Format{
-		".jpg":  imaging.JPEG,
-		".jpeg": imaging.JPEG,
-		".png":  imaging.PNG,
-		".tif":  imaging.TIFF,
-		".tiff": imaging.TIFF,
-		".bmp":  imaging.BMP,
-		".gif":  imaging.GIF,
+	sourceExtension := util.GetExtension(matter.Name)
+	format, ok := IMAGE_CACHE_FORMATS[strings.ToLower(sourceExtension)]
+	if !ok {
+		panic(result.BadRequest("not support this kind of image's (%s) resize", sourceExtension))
 	}
 
-	_, imageResizeM, imageResizeW, imageResizeH := this.ResizeParams(request)
-	mode := fmt.Sprintf("%s_%d_%d", imageResizeM, imageResizeW, imageResizeH)
-
-	format, ok := formats[extension]
-	if !ok {
-		panic(result.BadRequest("not support this kind of image's (%s) resize", extension))
+	//keep the source's extension if possible.
+	extension := sourceExtension
+	if process.Format != "" {
+		format = IMAGE_CACHE_OUTPUT_FORMATS[process.Format]
+		extension = "." + process.Format
+	} else if _, ok := IMAGE_CACHE_OUTPUT_FORMATS[strings.ToLower(strings.TrimPrefix(sourceExtension, "."))]; !ok {
+		extension = ".png"
 	}
 
+	mode := process.Mode()
+
 	user := this.userDao.FindByUuid(matter.UserUuid)
 
-	dstImage := this.ResizeImage(request, matter.AbsolutePath())
+	diskFile, err := core.CONTEXT.GetStorage().Open(matter.StoragePath())
+	this.PanicError(err)
+	defer func() {
+		e := diskFile.Close()
+		this.PanicError(e)
+	}()
+
+	dstImage := this.processImage(diskFile, process)
 
 	cacheImageName := util.GetSimpleFileName(matter.Name) + "_" + mode + extension
 	cacheImageRelativePath := util.GetSimpleFileName(matter.Path) + "_" + mode + extension
@@ -194,7 +403,11 @@ func (this *ImageCacheService) cacheImage(writer http.ResponseWriter, request *h
 	}()
 
 	//store on disk after handle
-	err = imaging.Encode(fileWriter, dstImage, format)
+	var options []imaging.EncodeOption
+	if process.Quality > 0 {
+		options = append(options, imaging.JPEGQuality(process.Quality))
+	}
+	err = imaging.Encode(fileWriter, dstImage, format, options...)
 	this.PanicError(err)
 
 	fileInfo, err := fileWriter.Stat()
@@ -209,8 +422,232 @@ func (this *ImageCacheService) cacheImage(writer http.ResponseWriter, request *h
 		Mode:       mode,
 		Size:       fileInfo.Size(),
 		Path:       cacheImageRelativePath,
+		SpaceUuid:  matter.SpaceUuid,
+		ContentKey: matter.ContentKey(),
 	}
 	this.imageCacheDao.Create(imageCache)
 
+	this.TriggerEvict()
+
 	return imageCache
 }
+
+// whether the matter can be resized.
+func (this *ImageCacheService) IsSupported(matter *model.Matter) bool {
+	_, ok := IMAGE_CACHE_FORMATS[strings.ToLower(util.GetExtension(matter.Name))]
+	return !matter.Dir && ok
+}
+
+// generate the thumbnail presets of the matter in background. never block the invoker.
+func (this *ImageCacheService) Enqueue(matter *model.Matter) {
+
+	if matter == nil || !this.IsSupported(matter) || len(this.preferenceService.Fetch().FetchThumbnailPresets()) == 0 {
+		return
+	}
+
+	select {
+	case this.queue <- matter.Uuid:
+	default:
+		this.Logger.Warn("thumbnail queue is full. skip %s", matter.Path)
+	}
+}
+
+// generate the presets not cached yet. return the number of generated and failed.
+func (this *ImageCacheService) generatePresets(matter *model.Matter) (generated int64, failed int64) {
+
+	for _, preset := range this.preferenceService.Fetch().FetchThumbnailPresets() {
+		process := this.IrProcess(preset)
+		if this.FindFreshByMatterAndMode(matter, process.Mode()) != nil {
+			continue
+		}
+		if this.generatePreset(matter, process) {
+			generated++
+		} else {
+			failed++
+		}
+	}
+	return generated, failed
+}
+
+// one broken image should not stop the others.
+func (this *ImageCacheService) generatePreset(matter *model.Matter, process *model.ImageProcess) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			this.Logger.Error("cannot generate thumbnail %s of %s. %v", process.Mode(), matter.Path, err)
+			ok = false
+		}
+	}()
+	this.CacheImage(matter, process)
+	return true
+}
+
+// generate the thumbnail presets for existing images in background.
+func (this *ImageCacheService) Backfill(request *http.Request) {
+
+	if len(this.preferenceService.Fetch().FetchThumbnailPresets()) == 0 {
+		panic(result.BadRequest("no thumbnail presets configured."))
+	}
+
+	this.backfillMutex.Lock()
+	defer this.backfillMutex.Unlock()
+	if this.backfill.Running {
+		panic(result.BadRequest("the thumbnails are backfilling."))
+	}
+	startTime := time.Now()
+	this.backfill = model.ImageCacheBackfill{Running: true, StartTime: &startTime}
+
+	go core.RunWithRecovery(func() {
+
+		defer func() {
+			this.backfillMutex.Lock()
+			endTime := time.Now()
+			this.backfill.Running = false
+			this.backfill.EndTime = &endTime
+			this.backfillMutex.Unlock()
+		}()
+
+		this.Logger.Info("start backfilling the thumbnails.")
+
+		//find the images first to know the total.
+		var matterUuids []string
+		lastUuid := ""
+		for {
+			matters := this.matterDao.FindAfterUuid(lastUuid, 1000)
+			if len(matters) == 0 {
+				break
+			}
+			for _, matter := range matters {
+				if !matter.Deleted && this.IsSupported(matter) {
+					matterUuids = append(matterUuids, matter.Uuid)
+				}
+			}
+			lastUuid = matters[len(matters)-1].Uuid
+		}
+
+		this.backfillMutex.Lock()
+		this.backfill.Total = int64(len(matterUuids))
+		this.backfillMutex.Unlock()
+
+		tasks := make(chan string)
+		var waitGroup sync.WaitGroup
+		for i := 0; i < IMAGE_CACHE_WORKER_NUM; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				for matterUuid := range tasks {
+					var generated, failed int64
+					core.RunWithRecovery(func() {
+						if matter := this.matterDao.FindByUuid(matterUuid); matter != nil {
+							generated, failed = this.generatePresets(matter)
+						}
+					})
+
+					this.backfillMutex.Lock()
+					this.backfill.Processed++
+					this.backfill.Generated += generated
+					this.backfill.Failed += failed
+					this.backfillMutex.Unlock()
+				}
+			}()
+		}
+		for _, matterUuid := range matterUuids {
+			tasks <- matterUuid
+		}
+		close(tasks)
+		waitGroup.Wait()
+
+		this.Logger.Info("finish backfilling the thumbnails. %d images processed.", len(matterUuids))
+	})
+}
+
+// the progress of the last backfill.
+func (this *ImageCacheService) BackfillProgress() model.ImageCacheBackfill {
+	this.backfillMutex.Lock()
+	defer this.backfillMutex.Unlock()
+	return this.backfill
+}
+
+// a cache is served.
+func (this *ImageCacheService) Hit(imageCache *model.ImageCache) {
+	atomic.AddInt64(&this.hits, 1)
+	go core.RunWithRecovery(func() {
+		this.imageCacheDao.Touch(imageCache.Uuid)
+	})
+}
+
+// no cache for the request, the image is processed.
+func (this *ImageCacheService) Miss() {
+	atomic.AddInt64(&this.misses, 1)
+}
+
+// ask the evictor to check the limits. never blocks.
+func (this *ImageCacheService) TriggerEvict() {
+	select {
+	case this.evictSignal <- struct{}{}:
+	default:
+	}
+}
+
+// evict the least recently served caches until the space limit and the global limit are satisfied.
+func (this *ImageCacheService) Evict() {
+
+	preference := this.preferenceService.Fetch()
+
+	if preference.ImageCacheSpaceMaxSize >= 0 {
+		for spaceUuid, size := range this.imageCacheDao.SumSizeGroupBySpaceUuid() {
+			this.evict(spaceUuid, size, preference.ImageCacheSpaceMaxSize)
+		}
+	}
+
+	if preference.ImageCacheMaxSize >= 0 {
+		this.evict("", this.imageCacheDao.SumSize(""), preference.ImageCacheMaxSize)
+	}
+}
+
+// spaceUuid empty means all the caches.
+func (this *ImageCacheService) evict(spaceUuid string, size int64, maxSize int64) {
+
+	if size <= maxSize {
+		return
+	}
+	this.Logger.Info("image caches of space '%s' use %d bytes, exceed %d. start evicting.", spaceUuid, size, maxSize)
+
+	var count int64 = 0
+	for size > maxSize {
+		imageCaches := this.imageCacheDao.FindLeastVisited(spaceUuid, IMAGE_CACHE_EVICT_BATCH)
+		if len(imageCaches) == 0 {
+			break
+		}
+		for _, imageCache := range imageCaches {
+			this.imageCacheDao.Delete(imageCache)
+			size -= imageCache.Size
+			count++
+			if size <= maxSize {
+				break
+			}
+		}
+	}
+
+	atomic.AddInt64(&this.evictions, count)
+	this.Logger.Info("%d image caches of space '%s' evicted.", count, spaceUuid)
+}
+
+func (this *ImageCacheService) Stats() *model.ImageCacheStats {
+
+	preference := this.preferenceService.Fetch()
+
+	stats := &model.ImageCacheStats{
+		Hits:         atomic.LoadInt64(&this.hits),
+		Misses:       atomic.LoadInt64(&this.misses),
+		Evictions:    atomic.LoadInt64(&this.evictions),
+		TotalSize:    this.imageCacheDao.SumSize(""),
+		TotalCount:   this.imageCacheDao.Count(),
+		MaxSize:      preference.ImageCacheMaxSize,
+		SpaceMaxSize: preference.ImageCacheSpaceMaxSize,
+		SpaceSizes:   this.imageCacheDao.SumSizeGroupBySpaceUuid(),
+	}
+	if stats.Hits+stats.Misses > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+	}
+	return stats
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	//jobs running at the same time.
+	JOB_WORKER_COUNT = 4
+	//look for the queued jobs in case a wake up is missed.
+	JOB_POLL_INTERVAL = 10 * time.Second
+	//write the progress at most once in this interval.
+	JOB_PROGRESS_INTERVAL = time.Second
+	//max length of the message column.
+	JOB_MESSAGE_MAX_LENGTH = 1024
+)
+
+// these jobs can run again from the beginning after interrupted by a restart. the others are failed.
+var resumableJobTypes = map[string]bool{
+	model.JOB_TYPE_CRAWL:            true,
+	model.JOB_TYPE_COMPUTE_DIR_SIZE: true,
+	model.JOB_TYPE_SCAN:             true,
+}
+
+type jobProgressKey struct{}
+
+/**
+ * progress of a running job. the long operations report to it and check whether the job is cancelled.
+ * all the methods work on nil, so that the operations can run outside a job as well.
+ */
+type JobProgress struct {
+	jobUuid string
+	jobDao  *dao.JobDao
+	ctx     context.Context
+
+	mutex    sync.Mutex
+	total    int64
+	done     int64
+	message  string
+	saveTime time.Time
+}
+
+// the progress of the job which the request is mocked for. nil if the request is not from a job.
+func jobProgressOf(request *http.Request) *JobProgress {
+	if request == nil {
+		return nil
+	}
+	progress, _ := request.Context().Value(jobProgressKey{}).(*JobProgress)
+	return progress
+}
+
+// the amount of work. 0 if unknown.
+func (this *JobProgress) SetTotal(total int64) {
+	if this == nil {
+		return
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.total = total
+	this.save()
+}
+
+// some work is done. empty message keeps the last one.
+func (this *JobProgress) Add(n int64, message string) {
+	if this == nil {
+		return
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.done += n
+	if message != "" {
+		this.message = message
+	}
+	if time.Since(this.saveTime) >= JOB_PROGRESS_INTERVAL {
+		this.save()
+	}
+}
+
+// mutex is held.
+func (this *JobProgress) save() {
+	var percent int64 = 0
+	if this.total > 0 {
+		percent = this.done * 100 / this.total
+		//100 only when the job is finished.
+		if percent > 99 {
+			percent = 99
+		}
+	}
+	this.saveTime = time.Now()
+	this.jobDao.UpdateProgress(this.jobUuid, percent, truncateJobMessage(this.message))
+}
+
+// cancelled when the job is cancelled. never cancelled outside a job.
+func (this *JobProgress) Context() context.Context {
+	if this == nil {
+		return context.Background()
+	}
+	return this.ctx
+}
+
+func (this *JobProgress) Cancelled() bool {
+	return this != nil && this.ctx.Err() != nil
+}
+
+// stop the operation if the job is cancelled.
+func (this *JobProgress) Check() {
+	if this.Cancelled() {
+		panic(result.BadRequest("job %s is cancelled.", this.jobUuid))
+	}
+}
+
+// count the bytes read into the progress.
+type jobProgressReader struct {
+	reader   io.Reader
+	progress *JobProgress
+}
+
+func (this *jobProgressReader) Read(p []byte) (int, error) {
+	n, err := this.reader.Read(p)
+	this.progress.Add(int64(n), "")
+	return n, err
+}
+
+func truncateJobMessage(message string) string {
+	runes := []rune(message)
+	if len(runes) > JOB_MESSAGE_MAX_LENGTH {
+		return string(runes[:JOB_MESSAGE_MAX_LENGTH])
+	}
+	return message
+}
+
+// run the long operations in background.
+// @Service
+type JobService struct {
+	bean.BaseBean
+	jobDao        *dao.JobDao
+	userDao       *dao.UserDao
+	spaceDao      *dao.SpaceDao
+	matterDao     *dao.MatterDao
+	matterService *MatterService
+	taskService   *TaskService
+
+	//wake up the idle workers.
+	wakeSignal chan struct{}
+	//jobUuid -> cancel func of the running jobs.
+	cancelMutex sync.Mutex
+	cancelMap   map[string]context.CancelFunc
+}
+
+func (this *JobService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.jobDao)
+	if b, ok := b.(*dao.JobDao); ok {
+		this.jobDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.userDao)
+	if b, ok := b.(*dao.UserDao); ok {
+		this.userDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.spaceDao)
+	if b, ok := b.(*dao.SpaceDao); ok {
+		this.spaceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.taskService)
+	if b, ok := b.(*TaskService); ok {
+		this.taskService = b
+	}
+
+	this.wakeSignal = make(chan struct{}, JOB_WORKER_COUNT)
+	this.cancelMap = make(map[string]context.CancelFunc)
+}
+
+func (this *JobService) Bootstrap() {
+
+	//the running jobs are interrupted by the restart.
+	for _, job := range this.jobDao.FindByStatus(model.JOB_STATUS_RUNNING) {
+		if resumableJobTypes[job.Type] {
+			this.Logger.Info("resume job %s %s", job.Type, job.Uuid)
+			this.jobDao.UpdateStatus(job.Uuid, model.JOB_STATUS_RUNNING, model.JOB_STATUS_QUEUED, map[string]interface{}{"percent": 0, "message": "interrupted by restart. run again."})
+		} else {
+			this.Logger.Info("job %s %s is interrupted", job.Type, job.Uuid)
+			this.jobDao.UpdateStatus(job.Uuid, model.JOB_STATUS_RUNNING, model.JOB_STATUS_FAILED, map[string]interface{}{"message": "interrupted by restart.", "end_time": time.Now()})
+		}
+	}
+
+	for i := 0; i < JOB_WORKER_COUNT; i++ {
+		go this.work()
+	}
+}
+
+// queue a job. it runs when a worker is free.
+func (this *JobService) Submit(user *model.User, spaceUuid string, jobType string, params *model.JobParams) *model.Job {
+
+	paramsBytes, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(params)
+	this.PanicError(err)
+
+	job := &model.Job{
+		UserUuid:  user.Uuid,
+		SpaceUuid: spaceUuid,
+		Type:      jobType,
+		Status:    model.JOB_STATUS_QUEUED,
+		Params:    string(paramsBytes),
+	}
+	job = this.jobDao.Create(job)
+	this.Logger.Info("submit job %s %s", job.Type, job.Uuid)
+
+	select {
+	case this.wakeSignal <- struct{}{}:
+	default:
+	}
+
+	return job
+}
+
+// whether a job of the type is queued or running.
+func (this *JobService) Unfinished(jobType string) bool {
+	return this.jobDao.CountByTypeAndStatus(jobType, model.JOB_STATUS_QUEUED)+this.jobDao.CountByTypeAndStatus(jobType, model.JOB_STATUS_RUNNING) > 0
+}
+
+// a queued job never runs. a running job stops at the next check.
+func (this *JobService) Cancel(request *http.Request, job *model.Job) {
+
+	if job.Status == model.JOB_STATUS_QUEUED {
+		if this.jobDao.UpdateStatus(job.Uuid, model.JOB_STATUS_QUEUED, model.JOB_STATUS_CANCELLED, map[string]interface{}{"message": "cancelled.", "end_time": time.Now()}) {
+			return
+		}
+	}
+
+	this.cancelMutex.Lock()
+	cancel, ok := this.cancelMap[job.Uuid]
+	this.cancelMutex.Unlock()
+	if !ok {
+		job = this.jobDao.CheckByUuid(job.Uuid)
+		panic(result.BadRequest("job %s is %s. cannot cancel it.", job.Uuid, job.Status))
+	}
+
+	this.Logger.Info("cancel job %s %s", job.Type, job.Uuid)
+	cancel()
+}
+
+func (this *JobService) work() {
+	for {
+		job, ctx := this.claim()
+		if job == nil {
+			select {
+			case <-this.wakeSignal:
+			case <-time.After(JOB_POLL_INTERVAL):
+			}
+			continue
+		}
+		this.run(job, ctx)
+	}
+}
+
+// take the earliest queued job. another worker may take it first.
+// the cancel handle is registered before the job turns RUNNING, so that a cancel in between is not lost.
+func (this *JobService) claim() (job *model.Job, ctx context.Context) {
+	core.RunWithRecovery(func() {
+		for {
+			queued := this.jobDao.FindFirstQueued()
+			if queued == nil {
+				return
+			}
+
+			queuedCtx, cancel := context.WithCancel(context.Background())
+			this.cancelMutex.Lock()
+			this.cancelMap[queued.Uuid] = cancel
+			this.cancelMutex.Unlock()
+
+			startTime := time.Now()
+			claimed := false
+			func() {
+				defer func() {
+					if !claimed {
+						this.release(queued.Uuid)
+					}
+				}()
+				claimed = this.jobDao.UpdateStatus(queued.Uuid, model.JOB_STATUS_QUEUED, model.JOB_STATUS_RUNNING, map[string]interface{}{"percent": 0, "start_time": startTime})
+			}()
+			if claimed {
+				queued.Status = model.JOB_STATUS_RUNNING
+				queued.StartTime = startTime
+				job = queued
+				ctx = queuedCtx
+				return
+			}
+		}
+	})
+	return job, ctx
+}
+
+// drop the cancel handle of a job.
+func (this *JobService) release(jobUuid string) {
+	this.cancelMutex.Lock()
+	cancel, ok := this.cancelMap[jobUuid]
+	delete(this.cancelMap, jobUuid)
+	this.cancelMutex.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (this *JobService) run(job *model.Job, ctx context.Context) {
+
+	defer this.release(job.Uuid)
+
+	progress := &JobProgress{jobUuid: job.Uuid, jobDao: this.jobDao, ctx: ctx}
+
+	//mock a request. the operations find the progress by it.
+	request := (&http.Request{Header: http.Header{}}).WithContext(context.WithValue(ctx, jobProgressKey{}, progress))
+
+	this.Logger.Info("start job %s %s", job.Type, job.Uuid)
+
+	var value interface{}
+	var failure interface{}
+	func() {
+		defer func() {
+			failure = recover()
+		}()
+		value = this.execute(request, job)
+	}()
+
+	columns := map[string]interface{}{"end_time": time.Now()}
+	status := model.JOB_STATUS_SUCCEEDED
+	if ctx.Err() != nil {
+		status = model.JOB_STATUS_CANCELLED
+		columns["message"] = "cancelled."
+	} else if failure != nil {
+		status = model.JOB_STATUS_FAILED
+		columns["message"] = truncateJobMessage(this.failureMessage(failure))
+		this.Logger.Error("job %s %s failed. %v", job.Type, job.Uuid, failure)
+	} else {
+		columns["percent"] = 100
+		if value != nil {
+			valueBytes, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(value)
+			if err == nil {
+				columns["result"] = string(valueBytes)
+			}
+		}
+	}
+
+	core.RunWithRecovery(func() {
+		this.jobDao.UpdateStatus(job.Uuid, model.JOB_STATUS_RUNNING, status, columns)
+	})
+	this.Logger.Info("finish job %s %s. %s", job.Type, job.Uuid, status)
+}
+
+func (this *JobService) failureMessage(failure interface{}) string {
+	switch value := failure.(type) {
+	case *result.WebResult:
+		return value.Msg
+	case error:
+		return value.Error()
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// do the job. returns its result.
+func (this *JobService) execute(request *http.Request, job *model.Job) interface{} {
+
+	params := &model.JobParams{}
+	err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal([]byte(job.Params), params)
+	this.PanicError(err)
+
+	user := this.userDao.CheckByUuid(job.UserUuid)
+
+	switch job.Type {
+	case model.JOB_TYPE_MIRROR:
+		space := this.spaceDao.CheckByUuid(job.SpaceUuid)
+		this.matterService.AtomicMirror(request, params.SrcPath, params.DestPath, params.Overwrite, user, space)
+		return nil
+	case model.JOB_TYPE_COPY:
+		space := this.spaceDao.CheckByUuid(job.SpaceUuid)
+		srcMatter := this.matterDao.CheckByUuid(params.SrcUuid)
+		destDirMatter := this.matterDao.CheckWithRootByUuid(params.DestUuid, space)
+		this.matterService.AtomicCopy(request, srcMatter, destDirMatter, params.Name, params.Overwrite, user, space)
+		return nil
+	case model.JOB_TYPE_CRAWL:
+		space := this.spaceDao.CheckByUuid(job.SpaceUuid)
+		dirMatter := this.matterDao.CheckWithRootByUuid(params.DestUuid, space)
+		return this.matterService.AtomicCrawl(request, params.Url, params.Name, user, space, dirMatter, params.Privacy)
+	case model.JOB_TYPE_COMPUTE_DIR_SIZE:
+		space := this.spaceDao.CheckByUuid(job.SpaceUuid)
+		this.matterService.ComputeAllDirSize(request, user, space)
+		return nil
+	case model.JOB_TYPE_SCAN:
+		return this.taskService.DoScanTask(request, params.Full, params.DryRun)
+	default:
+		panic(result.BadRequest("cannot recognize job type %s", job.Type))
+	}
+}
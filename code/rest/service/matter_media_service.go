@@ -0,0 +1,165 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/media"
+	"box/code/tool/result"
+	"net/http"
+	"sync/atomic"
+)
+
+// capacity of the extracting queue. new tasks are dropped when full, the backfill can pick them up later.
+const MATTER_MEDIA_QUEUE_SIZE = 1024
+
+// @Service
+type MatterMediaService struct {
+	bean.BaseBean
+	matterMediaDao *dao.MatterMediaDao
+	matterDao      *dao.MatterDao
+	//uuids of the matters to extract.
+	queue chan string
+	//1 when backfilling.
+	backfilling int32
+}
+
+func (this *MatterMediaService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.matterMediaDao)
+	if b, ok := b.(*dao.MatterMediaDao); ok {
+		this.matterMediaDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	this.queue = make(chan string, MATTER_MEDIA_QUEUE_SIZE)
+}
+
+func (this *MatterMediaService) Bootstrap() {
+
+	//one worker is enough. extracting only reads the head of files.
+	go func() {
+		for matterUuid := range this.queue {
+			core.RunWithRecovery(func() {
+				matter := this.matterDao.FindByUuid(matterUuid)
+				if matter != nil {
+					this.Extract(matter)
+				}
+			})
+		}
+	}()
+}
+
+// extract the metadata of the matter in background. never block the invoker.
+func (this *MatterMediaService) Enqueue(matter *model.Matter) {
+
+	if matter == nil || matter.Dir || !media.IsSupported(matter.Name) {
+		return
+	}
+
+	select {
+	case this.queue <- matter.Uuid:
+	default:
+		this.Logger.Warn("media queue is full. skip %s", matter.Path)
+	}
+}
+
+// extract the metadata of the matter and save it. the stale metadata is removed if the content is not a valid media anymore.
+func (this *MatterMediaService) Extract(matter *model.Matter) *model.MatterMedia {
+
+	if matter.Dir || !media.IsSupported(matter.Name) {
+		return nil
+	}
+
+	file, err := core.CONTEXT.GetStorage().Open(matter.StoragePath())
+	if err != nil {
+		this.Logger.Error("cannot open %s. %v", matter.Path, err)
+		return nil
+	}
+	defer func() {
+		err := file.Close()
+		if err != nil {
+			this.Logger.Error("occur error when closing file. %v", err)
+		}
+	}()
+
+	metadata, err := media.Extract(matter.Name, file, matter.Size)
+	if err != nil {
+		this.Logger.Info("no media metadata in %s. %v", matter.Path, err)
+		this.matterMediaDao.DeleteByMatterUuid(matter.Uuid)
+		return nil
+	}
+
+	return this.matterMediaDao.Save(&model.MatterMedia{
+		MatterUuid:  matter.Uuid,
+		Width:       metadata.Width,
+		Height:      metadata.Height,
+		Orientation: metadata.Orientation,
+		Make:        truncate(metadata.Make, 255),
+		Model:       truncate(metadata.Model, 255),
+		TakenTime:   metadata.TakenTime,
+		Latitude:    metadata.Latitude,
+		Longitude:   metadata.Longitude,
+		Duration:    metadata.Duration,
+		Title:       truncate(metadata.Title, 255),
+		Artist:      truncate(metadata.Artist, 255),
+		Album:       truncate(metadata.Album, 255),
+		Year:        truncate(metadata.Year, 45),
+		Genre:       truncate(metadata.Genre, 255),
+	})
+}
+
+// extract the metadata of existing files in background. force means extract again even if extracted.
+func (this *MatterMediaService) Backfill(request *http.Request, force bool) {
+
+	if !atomic.CompareAndSwapInt32(&this.backfilling, 0, 1) {
+		panic(result.BadRequest("the media metadata is backfilling."))
+	}
+
+	go core.RunWithRecovery(func() {
+		defer atomic.StoreInt32(&this.backfilling, 0)
+
+		this.Logger.Info("start backfilling the media metadata.")
+
+		count := 0
+		lastUuid := ""
+		for {
+			matters := this.matterDao.FindAfterUuid(lastUuid, 1000)
+			if len(matters) == 0 {
+				break
+			}
+			for _, matter := range matters {
+				if matter.Dir || !media.IsSupported(matter.Name) {
+					continue
+				}
+				if !force && this.matterMediaDao.FindByMatterUuid(matter.Uuid) != nil {
+					continue
+				}
+				//one broken file should not stop the others.
+				core.RunWithRecovery(func() {
+					if this.Extract(matter) != nil {
+						count++
+					}
+				})
+			}
+			lastUuid = matters[len(matters)-1].Uuid
+		}
+
+		this.Logger.Info("finish backfilling the media metadata. %d matters extracted.", count)
+	})
+}
+
+// cut the string to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
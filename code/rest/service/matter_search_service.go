@@ -0,0 +1,96 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"net/http"
+	"sync/atomic"
+)
+
+// @Service
+type MatterSearchService struct {
+	bean.BaseBean
+	matterSearchDao *dao.MatterSearchDao
+	matterDao       *dao.MatterDao
+	//1 when rebuilding.
+	rebuilding int32
+}
+
+func (this *MatterSearchService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.matterSearchDao)
+	if b, ok := b.(*dao.MatterSearchDao); ok {
+		this.matterSearchDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+}
+
+func (this *MatterSearchService) Bootstrap() {
+
+	//the index is new. eg. upgraded from an old version.
+	if this.matterSearchDao.CreateTableIfNotExist() {
+		this.Logger.Info("Build the search index in background.")
+		go core.RunWithRecovery(func() {
+			this.Rebuild(nil)
+		})
+	}
+}
+
+// search matters in a space. dirMatter limits the result under it.
+func (this *MatterSearchService) Search(request *http.Request, page int, pageSize int, space *model.Space, dirMatter *model.Matter, filter *model.MatterFilter, sortArray []builder.OrderPair) *model.Pager {
+
+	for _, category := range filter.Categories {
+		if _, ok := model.MATTER_CATEGORY_EXTENSIONS[category]; !ok {
+			panic(result.BadRequest("category %s is not supported.", category))
+		}
+	}
+	if filter.SizeMin >= 0 && filter.SizeMax >= 0 && filter.SizeMin > filter.SizeMax {
+		panic(result.BadRequest("sizeMin cannot be greater than sizeMax."))
+	}
+
+	if dirMatter != nil && dirMatter.Uuid != model.MATTER_ROOT {
+		filter.PathPrefix = dirMatter.Path
+	}
+
+	return this.matterSearchDao.Page(page, pageSize, space.Uuid, filter, sortArray)
+}
+
+// rebuild the whole index. return the number of matters indexed.
+func (this *MatterSearchService) Rebuild(request *http.Request) int {
+
+	if !atomic.CompareAndSwapInt32(&this.rebuilding, 0, 1) {
+		panic(result.BadRequest("the search index is rebuilding."))
+	}
+	defer atomic.StoreInt32(&this.rebuilding, 0)
+
+	this.Logger.Info("start rebuilding the search index.")
+
+	this.matterSearchDao.DeleteAll()
+
+	count := 0
+	lastUuid := ""
+	for {
+		matters := this.matterDao.FindAfterUuid(lastUuid, 1000)
+		if len(matters) == 0 {
+			break
+		}
+		this.matterSearchDao.IndexBatch(matters)
+
+		count += len(matters)
+		lastUuid = matters[len(matters)-1].Uuid
+	}
+
+	this.Logger.Info("finish rebuilding the search index. %d matters indexed.", count)
+
+	return count
+}
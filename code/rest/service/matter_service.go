@@ -1,15 +1,19 @@
 package service
 
 import (
-	"archive/zip"
 	"box/code/rest/bean"
 	"box/code/rest/dao"
 	"box/code/rest/model"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -18,11 +22,15 @@ import (
 	"time"
 
 	"box/code/core"
+	"box/code/tool/archive"
 	"box/code/tool/builder"
+	"box/code/tool/charset"
 	"box/code/tool/download"
 	"box/code/tool/i18n"
 	"box/code/tool/result"
+	"box/code/tool/storage"
 	"box/code/tool/util"
+	"box/code/tool/uuid"
 )
 
 /**
@@ -31,13 +39,23 @@ import (
 //@Service
 type MatterService struct {
 	bean.BaseBean
-	matterDao         *dao.MatterDao
-	spaceDao          *dao.SpaceDao
-	userDao           *dao.UserDao
-	userService       *UserService
-	imageCacheDao     *dao.ImageCacheDao
-	imageCacheService *ImageCacheService
-	preferenceService *PreferenceService
+	matterDao            *dao.MatterDao
+	spaceDao             *dao.SpaceDao
+	userDao              *dao.UserDao
+	userService          *UserService
+	imageCacheDao        *dao.ImageCacheDao
+	imageCacheService    *ImageCacheService
+	preferenceService    *PreferenceService
+	blobDao              *dao.BlobDao
+	matterVersionService *MatterVersionService
+	matterMediaDao       *dao.MatterMediaDao
+	matterMediaService   *MatterMediaService
+	shareService         *ShareService
+	favoriteDao          *dao.FavoriteDao
+	recentDao            *dao.RecentDao
+	matterTagDao         *dao.MatterTagDao
+	albumMatterDao       *dao.AlbumMatterDao
+	recentService        *RecentService
 }
 
 func (this *MatterService) Init() {
@@ -78,6 +96,56 @@ func (this *MatterService) Init() {
 		this.preferenceService = b
 	}
 
+	b = core.CONTEXT.GetBean(this.blobDao)
+	if b, ok := b.(*dao.BlobDao); ok {
+		this.blobDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterVersionService)
+	if b, ok := b.(*MatterVersionService); ok {
+		this.matterVersionService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterMediaDao)
+	if b, ok := b.(*dao.MatterMediaDao); ok {
+		this.matterMediaDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterMediaService)
+	if b, ok := b.(*MatterMediaService); ok {
+		this.matterMediaService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.shareService)
+	if b, ok := b.(*ShareService); ok {
+		this.shareService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.favoriteDao)
+	if b, ok := b.(*dao.FavoriteDao); ok {
+		this.favoriteDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentDao)
+	if b, ok := b.(*dao.RecentDao); ok {
+		this.recentDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterTagDao)
+	if b, ok := b.(*dao.MatterTagDao); ok {
+		this.matterTagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.albumMatterDao)
+	if b, ok := b.(*dao.AlbumMatterDao); ok {
+		this.albumMatterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*RecentService); ok {
+		this.recentService = b
+	}
+
 }
 
 // get the page of matters.
@@ -141,62 +209,6 @@ func (this *MatterService) Page(
 	return pager
 }
 
-// search files by dfs.
-func (this *MatterService) DfsSearch(
-	request *http.Request,
-	limit int,
-	puuid string,
-	keyword string,
-	spaceUuid string,
-	deleted bool,
-) []*model.Matter {
-
-	deletedStr := model.FALSE
-	if deleted {
-		deletedStr = model.FALSE
-	}
-
-	//find all matters including dir and files.
-	_, matters := this.matterDao.PlainPage(0, limit, puuid, "", spaceUuid, keyword, "", deletedStr, nil, nil, nil)
-	if len(matters) >= limit {
-		return matters
-	}
-
-	var resultList = make([]*model.Matter, 0)
-	for _, matter := range matters {
-		if !matter.Dir {
-			resultList = append(resultList, matter)
-		}
-	}
-
-	//dfs from puuid.
-	_, dirMatters := this.matterDao.PlainPage(0, 1000, puuid, "", spaceUuid, "", model.TRUE, "", nil, nil, nil)
-	for _, dirMatter := range dirMatters {
-
-		//add dir if match.
-		if strings.Contains(dirMatter.Name, keyword) {
-			if deleted == dirMatter.Deleted {
-				resultList = append(resultList, dirMatter)
-			}
-		}
-
-		remainLimit := limit - len(resultList)
-		subMatters := this.DfsSearch(request, remainLimit, dirMatter.Uuid, keyword, spaceUuid, deleted)
-		for _, subMatter := range subMatters {
-			resultList = append(resultList, subMatter)
-		}
-
-		//enough then return
-		if len(resultList) >= limit {
-			return resultList
-		}
-
-	}
-
-	//not enough ,return.
-	return resultList
-}
-
 // Download. Support chunk download.
 func (this *MatterService) DownloadFile(
 	writer http.ResponseWriter,
@@ -208,15 +220,47 @@ func (this *MatterService) DownloadFile(
 	download.DownloadFile(writer, request, filePath, filename, withContentDisposition)
 }
 
-// Download specified matters. matters must have the same puuid.
-func (this *MatterService) DownloadZip(
+// Download a matter from the storage. Support chunk download.
+func (this *MatterService) DownloadMatter(
 	writer http.ResponseWriter,
 	request *http.Request,
-	matters []*model.Matter) {
+	matter *model.Matter,
+	withContentDisposition bool) {
+
+	driver := core.CONTEXT.GetStorage()
+	file, err := driver.Open(matter.StoragePath())
+	this.PanicError(err)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
+
+	//blob is shared by many matters, so its own modify time makes no sense.
+	modifyTime := matter.UpdateTime
+	size := matter.Size
+	if !matter.IsBlob() {
+		fileInfo, err := driver.Stat(matter.StoragePath())
+		this.PanicError(err)
+		modifyTime = fileInfo.ModTime()
+		size = fileInfo.Size()
+	}
+
+	download.DownloadContent(writer, request, file, modifyTime, size, matter.Name, withContentDisposition)
+}
+
+// Download specified matters as an archive. matters must have the same puuid.
+func (this *MatterService) DownloadArchive(
+	writer http.ResponseWriter,
+	request *http.Request,
+	matters []*model.Matter,
+	format string) {
 
 	if matters == nil || len(matters) == 0 {
 		panic(result.BadRequest("matters cannot be nil."))
 	}
+	if !archive.IsFormat(format) {
+		panic(result.BadRequest("format must be one of %s", strings.Join(archive.FORMATS, ",")))
+	}
 	spaceUuid := matters[0].SpaceUuid
 	puuid := matters[0].Puuid
 
@@ -254,110 +298,76 @@ func (this *MatterService) DownloadZip(
 		}
 	}
 
-	//prepare the temp zip dir
-	destZipDirPath := fmt.Sprintf("%s/%d", model.GetSpaceZipRootDir(matters[0].SpaceName), time.Now().UnixNano()/1e6)
-	util.MakeDirAll(destZipDirPath)
+	//wrap children for every matter.
+	for _, m := range matters {
+		this.WrapChildrenDetail(request, m)
+	}
 
-	destZipName := fmt.Sprintf("%s.zip", matters[0].Name)
+	archiveName := fmt.Sprintf("%s.%s", matters[0].Name, format)
 	if len(matters) > 1 || !matters[0].Dir {
-		destZipName = "archive.zip"
+		archiveName = "archive." + format
 	}
 
-	destZipPath := fmt.Sprintf("%s/%s", destZipDirPath, destZipName)
+	//the archive is streamed to the client, so its size is unknown and range is not supported.
+	writer.Header().Set("Content-Type", archive.ContentType(format))
+	writer.Header().Set("content-disposition", "attachment; filename=\""+url.QueryEscape(archiveName)+"\"")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusOK)
 
-	this.zipMatters(request, matters, destZipPath)
+	//the response has started, cannot send an error result any more.
+	defer func() {
+		if err := recover(); err != nil {
+			this.Logger.Error("archive %s aborted. %v", archiveName, err)
+			panic(http.ErrAbortHandler)
+		}
+	}()
 
-	download.DownloadFile(writer, request, destZipPath, destZipName, true)
+	archiveWriter, err := archive.NewWriter(format, writer)
+	this.PanicError(err)
 
-	//delete the temp zip file.
-	err := os.Remove(destZipPath)
-	if err != nil {
-		this.Logger.Error("error while deleting zip file %s", err.Error())
-	}
-	util.DeleteEmptyDir(destZipDirPath)
+	this.archiveMatters(request, matters, archiveWriter)
 
+	err = archiveWriter.Close()
+	this.PanicError(err)
 }
 
-// zip matters.
-func (this *MatterService) zipMatters(request *http.Request, matters []*model.Matter, destPath string) {
+// write matters to the archive. matters must have the same puuid and their children wrapped.
+func (this *MatterService) archiveMatters(request *http.Request, matters []*model.Matter, archiveWriter archive.Writer) {
 
-	if util.PathExists(destPath) {
-		panic(result.BadRequest("%s exists", destPath))
-	}
+	baseDirPath := util.GetDirOfPath(matters[0].Path) + "/"
 
-	//matters must have the same puuid.
-	if matters == nil || len(matters) == 0 {
-		panic(result.BadRequest("matters cannot be nil."))
-	}
-	spaceUuid := matters[0].SpaceUuid
-	puuid := matters[0].Puuid
-	baseDirPath := util.GetDirOfPath(matters[0].AbsolutePath()) + "/"
-
-	for _, m := range matters {
-		if m.SpaceUuid != spaceUuid {
-			panic(result.BadRequest("spaceUuid not same"))
-		} else if m.Puuid != puuid {
-			panic(result.BadRequest("puuid not same"))
-		}
-	}
-
-	//wrap children for every matter.
-	for _, m := range matters {
-		this.WrapChildrenDetail(request, m)
-	}
-
-	// create temp zip file.
-	fileWriter, err := os.Create(destPath)
-	this.PanicError(err)
-
-	defer func() {
-		err := fileWriter.Close()
-		this.PanicError(err)
-	}()
-
-	zipWriter := zip.NewWriter(fileWriter)
-	defer func() {
-		err := zipWriter.Close()
-		this.PanicError(err)
-	}()
+	driver := core.CONTEXT.GetStorage()
+	ctx := request.Context()
 
 	//DFS algorithm
 	var walkFunc func(matter *model.Matter)
 	walkFunc = func(matter *model.Matter) {
 
-		path := matter.AbsolutePath()
-
-		fileInfo, err := os.Stat(path)
-		this.PanicError(err)
+		//client has gone.
+		this.PanicError(ctx.Err())
 
-		// Create file info.
-		fileHeader, err := zip.FileInfoHeader(fileInfo)
+		fileInfo, err := driver.Stat(matter.StoragePath())
 		this.PanicError(err)
 
-		// Trim the baseDirPath
-		fileHeader.Name = strings.TrimPrefix(path, baseDirPath)
-
-		// directory has prefix /
-		if matter.Dir {
-			fileHeader.Name += "/"
+		// Trim the baseDirPath. blob's name on disk is md5, so use the relative path.
+		entry := &archive.Entry{
+			Name:    strings.TrimPrefix(matter.Path, baseDirPath),
+			Dir:     matter.Dir,
+			Size:    fileInfo.Size(),
+			Mode:    fileInfo.Mode(),
+			ModTime: fileInfo.ModTime(),
+		}
+		//blob is shared by many matters, its mode and modify time make no sense. use the common file mode.
+		if matter.IsBlob() {
+			entry.Mode = 0644
+			entry.ModTime = matter.UpdateTime
 		}
 
-		writer, err := zipWriter.CreateHeader(fileHeader)
+		entryWriter, err := archiveWriter.Create(entry)
 		this.PanicError(err)
 
-		// only regular file has things to write.
-		if fileHeader.Mode().IsRegular() {
-
-			fileToBeZip, err := os.Open(path)
-			defer func() {
-				err = fileToBeZip.Close()
-				this.PanicError(err)
-			}()
-			this.PanicError(err)
-
-			_, err = io.Copy(writer, fileToBeZip)
-			this.PanicError(err)
-
+		if !matter.Dir {
+			this.copyToArchive(entryWriter, matter, ctx)
 		}
 
 		//dfs.
@@ -371,6 +381,20 @@ func (this *MatterService) zipMatters(request *http.Request, matters []*model.Ma
 	}
 }
 
+// copy the content of a file into an archive entry.
+func (this *MatterService) copyToArchive(writer io.Writer, matter *model.Matter, ctx context.Context) {
+
+	file, err := core.CONTEXT.GetStorage().Open(matter.StoragePath())
+	this.PanicError(err)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
+
+	_, err = io.Copy(writer, &download.ContextReader{Context: ctx, Reader: file})
+	this.PanicError(err)
+}
+
 // delete files.
 func (this *MatterService) Delete(request *http.Request, matter *model.Matter, user *model.User, space *model.Space) {
 
@@ -492,23 +516,9 @@ func (this *MatterService) Upload(request *http.Request, file io.Reader, fileHea
 
 	//if fileHeader.Size not nill . check size in advance.
 	if fileHeader != nil {
-		//check the size limit.
-		if space.SizeLimit >= 0 {
-			if fileHeader.Size > space.SizeLimit {
-				panic(result.BadRequestI18n(request, i18n.MatterSizeExceedLimit, util.HumanFileSize(fileHeader.Size), util.HumanFileSize(space.SizeLimit)))
-			}
-		}
-
-		//check total size.
-		if space.TotalSizeLimit >= 0 {
-			if space.TotalSize+fileHeader.Size > space.TotalSizeLimit {
-				panic(result.BadRequestI18n(request, i18n.MatterSizeExceedTotalLimit, util.HumanFileSize(space.TotalSize), util.HumanFileSize(space.TotalSizeLimit)))
-			}
-		}
+		this.CheckSizeLimit(request, space, fileHeader.Size)
 	}
 
-	dirAbsolutePath := dirMatter.AbsolutePath()
-
 	dbMatter := this.matterDao.FindBySpaceUuidAndPuuidAndDirAndName(space.Uuid, dirMatter.Uuid, false, filename)
 	if dbMatter != nil {
 		if dbMatter.Deleted {
@@ -519,67 +529,224 @@ func (this *MatterService) Upload(request *http.Request, file io.Reader, fileHea
 
 	}
 
-	fileAbsolutePath := dirAbsolutePath + "/" + filename
+	//when size unknown in advance, check it after receiving.
+	var checkSpace *model.Space
+	if fileHeader == nil {
+		checkSpace = space
+	}
+	blob := this.storeBlob(request, file, checkSpace, 0)
+
+	this.Logger.Info("upload %s %v ", filename, util.HumanFileSize(blob.Size))
+
+	matter := this.createNonDirMatter(dirMatter, filename, blob.Size, privacy, user, space, blob)
+
+	return matter
+}
 
-	util.MakeDirAll(dirAbsolutePath)
+// the matters of uuids. they must be in the space.
+func (this *MatterService) CheckByUuidsInSpace(uuids []string, space *model.Space) []*model.Matter {
 
-	//if exist, overwrite it.
-	exist := util.PathExists(fileAbsolutePath)
-	if exist {
-		this.Logger.Error("%s exits, overwrite it.", fileAbsolutePath)
-		removeError := os.Remove(fileAbsolutePath)
-		this.PanicError(removeError)
+	matters := make([]*model.Matter, 0)
+	for _, uuid := range uuids {
+
+		matter := this.matterDao.CheckByUuid(uuid)
+		if matter.SpaceUuid != space.Uuid {
+			panic(result.UNAUTHORIZED)
+		}
+
+		matters = append(matters, matter)
 	}
 
-	destFile, err := os.OpenFile(fileAbsolutePath, os.O_WRONLY|os.O_CREATE, 0777)
-	this.PanicError(err)
-	closeDestFile := func() {
-		err := destFile.Close()
-		this.PanicError(err)
+	return matters
+}
+
+// check whether a file of fileSize can be put into the space.
+func (this *MatterService) CheckSizeLimit(request *http.Request, space *model.Space, fileSize int64) {
+
+	//check the size limit.
+	if space.SizeLimit >= 0 {
+		if fileSize > space.SizeLimit {
+			panic(result.BadRequestI18n(request, i18n.MatterSizeExceedLimit, util.HumanFileSize(fileSize), util.HumanFileSize(space.SizeLimit)))
+		}
 	}
 
-	fileSize, err := io.Copy(destFile, file)
-	this.PanicError(err)
+	//check total size.
+	if space.TotalSizeLimit >= 0 {
+		if space.TotalSize+fileSize > space.TotalSizeLimit {
+			panic(result.BadRequestI18n(request, i18n.MatterSizeExceedTotalLimit, util.HumanFileSize(space.TotalSize), util.HumanFileSize(space.TotalSizeLimit)))
+		}
+	}
+}
 
-	this.Logger.Info("upload %s %v ", filename, util.HumanFileSize(fileSize))
+// check whether the matters with their children can be put into the space.
+func (this *MatterService) checkTreeSizeLimit(request *http.Request, space *model.Space, matters []*model.Matter) {
 
-	if fileHeader == nil {
+	var totalSize, largestSize int64 = 0, 0
+	for _, matter := range matters {
+		size, largest := this.matterDao.SumSizeBySpaceUuidAndPath(matter.SpaceUuid, matter.Path)
+		totalSize += size
+		if largest > largestSize {
+			largestSize = largest
+		}
+	}
+
+	if space.SizeLimit >= 0 {
+		if largestSize > space.SizeLimit {
+			panic(result.BadRequestI18n(request, i18n.MatterSizeExceedLimit, util.HumanFileSize(largestSize), util.HumanFileSize(space.SizeLimit)))
+		}
+	}
+
+	if space.TotalSizeLimit >= 0 {
+		if space.TotalSize+totalSize > space.TotalSizeLimit {
+			panic(result.BadRequestI18n(request, i18n.MatterSizeExceedTotalLimit, util.HumanFileSize(space.TotalSize), util.HumanFileSize(space.TotalSizeLimit)))
+		}
+	}
+}
+
+// hash the content while streaming it into a temp file, then store it as a shared blob.
+// if space not nil, check the size limit after receiving. replacedSize is the size of the content it replaces, which is freed.
+func (this *MatterService) storeBlob(request *http.Request, file io.Reader, space *model.Space, replacedSize int64) *model.Blob {
+
+	driver := core.CONTEXT.GetStorage()
+
+	timeUUID, _ := uuid.NewV4()
+	tempPath := model.GetBlobTempStoragePath(timeUUID.String())
+
+	hash := md5.New()
+	fileSize, err := storage.Write(driver, tempPath, io.TeeReader(file, hash))
+
+	removeTempFile := func() {
+		removeErr := driver.Remove(tempPath)
+		if removeErr != nil {
+			this.Logger.Error("occur error when deleting temp blob. %v", removeErr)
+		}
+	}
+
+	if err != nil {
+		if storage.Exists(driver, tempPath) {
+			removeTempFile()
+		}
+		panic(err)
+	}
+
+	if space != nil {
 		//check the size limit.
 		if space.SizeLimit >= 0 {
 			if fileSize > space.SizeLimit {
-				closeDestFile()
-
-				//delete the file on disk.
-				err = os.Remove(fileAbsolutePath)
-				this.PanicError(err)
-
+				removeTempFile()
 				panic(result.BadRequestI18n(request, i18n.MatterSizeExceedLimit, util.HumanFileSize(fileSize), util.HumanFileSize(space.SizeLimit)))
 			}
 		}
 
 		//check total size.
 		if space.TotalSizeLimit >= 0 {
-			if space.TotalSize+fileSize > space.TotalSizeLimit {
-				closeDestFile()
-
-				//delete the file on disk.
-				err = os.Remove(fileAbsolutePath)
-				this.PanicError(err)
-
+			if space.TotalSize-replacedSize+fileSize > space.TotalSizeLimit {
+				removeTempFile()
 				panic(result.BadRequestI18n(request, i18n.MatterSizeExceedTotalLimit, util.HumanFileSize(space.TotalSize), util.HumanFileSize(space.TotalSizeLimit)))
 			}
 		}
 	}
 
-	closeDestFile()
+	return this.blobDao.Commit(hex.EncodeToString(hash.Sum(nil)), fileSize, tempPath)
+}
+
+// get a referenced blob of a file's content. physics file will be hashed into a blob.
+func (this *MatterService) retainBlob(request *http.Request, matter *model.Matter) *model.Blob {
+
+	if matter.IsBlob() {
+		//share the same blob.
+		this.blobDao.Retain(matter.BlobUuid)
+		return this.blobDao.CheckByUuid(matter.BlobUuid)
+	}
+
+	file, err := core.CONTEXT.GetStorage().Open(matter.StoragePath())
+	this.PanicError(err)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
+
+	return this.storeBlob(request, file, nil, 0)
+}
+
+// replace the content of a file with a referenced blob. the replaced content is kept as a version. invoker must handled the lock.
+func (this *MatterService) replaceContent(request *http.Request, matter *model.Matter, blob *model.Blob, user *model.User, space *model.Space) *model.Matter {
+
+	//make sure the current content has been kept.
+	this.matterVersionService.keep(request, matter)
+
+	oldIsBlob := matter.IsBlob()
+	oldBlobUuid := matter.BlobUuid
+	oldStoragePath := matter.StoragePath()
+
+	matter.Md5 = blob.Md5
+	matter.BlobUuid = blob.Uuid
+	matter.Size = blob.Size
+	matter = this.matterDao.Save(matter)
+
+	//the version holds its own reference.
+	if oldIsBlob {
+		this.blobDao.Release(oldBlobUuid)
+	} else {
+		err := core.CONTEXT.GetStorage().Remove(oldStoragePath)
+		if err != nil {
+			this.Logger.Error("occur error when deleting file. %v", err)
+		}
+	}
+
+	//caches of the old content.
+	this.imageCacheDao.DeleteByMatterUuid(matter.Uuid)
+
+	this.contentChanged(matter)
+
+	this.matterVersionService.record(matter, user, space)
 
-	matter := this.createNonDirMatter(dirMatter, filename, fileSize, privacy, user, space)
+	//overwriting and restoring are both edits.
+	this.recentService.Record(request, user, matter, model.RECENT_ACTION_EDIT)
+
+	//compute the size of directory
+	go core.RunWithRecovery(func() {
+		this.ComputeRouteSize(matter.Puuid, user, space)
+	})
 
 	return matter
 }
 
-// create a non dir matter.
-func (this *MatterService) createNonDirMatter(dirMatter *model.Matter, filename string, fileSize int64, privacy bool, user *model.User, space *model.Space) *model.Matter {
+// overwrite the content of a file. invoker must handled the lock.
+func (this *MatterService) Overwrite(request *http.Request, matter *model.Matter, file io.Reader, user *model.User, space *model.Space) *model.Matter {
+
+	if matter.Dir {
+		panic(result.BadRequest("cannot overwrite a directory."))
+	}
+
+	if matter.Deleted {
+		panic(result.BadRequestI18n(request, i18n.MatterRecycleBinExist, matter.Name))
+	}
+
+	blob := this.storeBlob(request, file, space, matter.Size)
+
+	this.Logger.Info("overwrite %s %v ", matter.Path, util.HumanFileSize(blob.Size))
+
+	return this.replaceContent(request, matter, blob, user, space)
+}
+
+// overwrite the content of a file.
+func (this *MatterService) AtomicOverwrite(request *http.Request, matter *model.Matter, file io.Reader, user *model.User, space *model.Space) *model.Matter {
+
+	this.userService.MatterLock(matter.UserUuid)
+	defer this.userService.MatterUnlock(matter.UserUuid)
+
+	return this.Overwrite(request, matter, file, user, space)
+}
+
+// the content of a file is new. prepare its metadata and thumbnails in background.
+func (this *MatterService) contentChanged(matter *model.Matter) {
+	this.matterMediaService.Enqueue(matter)
+	this.imageCacheService.Enqueue(matter)
+}
+
+// create a non dir matter. blob is nil when the file is a physics file in space's root dir.
+func (this *MatterService) createNonDirMatter(dirMatter *model.Matter, filename string, fileSize int64, privacy bool, user *model.User, space *model.Space, blob *model.Blob) *model.Matter {
 	dirRelativePath := dirMatter.Path
 	fileRelativePath := dirRelativePath + "/" + filename
 
@@ -598,8 +765,14 @@ func (this *MatterService) createNonDirMatter(dirMatter *model.Matter, filename
 		Prop:      model.EMPTY_JSON_MAP,
 		VisitTime: time.Now(),
 	}
+	if blob != nil {
+		matter.Md5 = blob.Md5
+		matter.BlobUuid = blob.Uuid
+	}
 	matter = this.matterDao.Create(matter)
 
+	this.contentChanged(matter)
+
 	//compute the size of directory
 	go core.RunWithRecovery(func() {
 		this.ComputeRouteSize(dirMatter.Uuid, user, space)
@@ -615,6 +788,11 @@ func (this *MatterService) updateNonDirMatter(matter *model.Matter, fileSize int
 
 	matter = this.matterDao.Save(matter)
 
+	//caches of the old content.
+	this.imageCacheDao.DeleteByMatterUuid(matter.Uuid)
+
+	this.contentChanged(matter)
+
 	//compute the size of directory
 	go core.RunWithRecovery(func() {
 		this.ComputeRouteSize(matter.Puuid, user, space)
@@ -660,25 +838,32 @@ func (this *MatterService) ComputeRouteSize(matterUuid string, user *model.User,
 }
 
 // compute all dir's size.
-func (this *MatterService) ComputeAllDirSize(user *model.User, space *model.Space) {
+func (this *MatterService) ComputeAllDirSize(request *http.Request, user *model.User, space *model.Space) {
 
 	this.Logger.Info("Compute all dir's size for user %s %s", user.Uuid, user.Username)
 
+	jobProgressOf(request).SetTotal(this.matterDao.CountDirBySpaceUuid(space.Uuid) + 1)
+
 	rootMatter := model.NewRootMatter(space)
-	this.ComputeDirSize(rootMatter, user, space)
+	this.ComputeDirSize(request, rootMatter, user, space)
 }
 
 // compute a dir's size.
-func (this *MatterService) ComputeDirSize(dirMatter *model.Matter, user *model.User, space *model.Space) {
+func (this *MatterService) ComputeDirSize(request *http.Request, dirMatter *model.Matter, user *model.User, space *model.Space) {
 
 	this.Logger.Info("Compute dir's size %s %s", dirMatter.Uuid, dirMatter.Name)
 
+	progress := jobProgressOf(request)
+	progress.Check()
+
 	//update sub dir first
 	childrenDirMatters := this.matterDao.FindByUserUuidAndPuuidAndDirTrue(user.Uuid, dirMatter.Uuid)
 	for _, childrenDirMatter := range childrenDirMatters {
-		this.ComputeDirSize(childrenDirMatter, user, space)
+		this.ComputeDirSize(request, childrenDirMatter, user, space)
 	}
 
+	progress.Add(1, dirMatter.Path)
+
 	//if to root directory, then update to user's info.
 	if dirMatter.Uuid == model.MATTER_ROOT {
 
@@ -748,13 +933,14 @@ func (this *MatterService) createDirectory(request *http.Request, dirMatter *mod
 		panic(result.BadRequestI18n(request, i18n.MatterDepthExceedLimit, len(parts), model.MATTER_NAME_MAX_DEPTH))
 	}
 
-	absolutePath := model.GetSpaceMatterRootDir(space.Name) + dirMatter.Path + "/" + name
+	storagePath := model.GetSpaceMatterStoragePath(space.Name) + dirMatter.Path + "/" + name
 
 	relativePath := dirMatter.Path + "/" + name
 
-	//crate directory on disk.
-	dirPath := util.MakeDirAll(absolutePath)
-	this.Logger.Info("Create Directory: %s", dirPath)
+	//crate directory in storage.
+	err := core.CONTEXT.GetStorage().MkdirAll(storagePath)
+	this.PanicError(err)
+	this.Logger.Info("Create Directory: %s", storagePath)
 
 	//create in db
 	matter = &model.Matter{
@@ -788,13 +974,21 @@ func (this *MatterService) AtomicCreateDirectory(request *http.Request, dirMatte
 	return matter
 }
 
-// copy or move may overwrite.
-func (this *MatterService) handleOverwrite(request *http.Request, user *model.User, space *model.Space, destinationPath string, overwrite bool) {
+// copy or move may overwrite. when a file replaces a file, the inheritor takes over the replaced one's versions.
+func (this *MatterService) handleOverwrite(request *http.Request, user *model.User, space *model.Space, destinationPath string, overwrite bool, inheritor *model.Matter) {
 
-	destMatter := this.matterDao.FindByUserUuidAndPath(user.Uuid, destinationPath)
+	destMatter := this.matterDao.FindBySpaceUuidAndPathIncludeDeleted(space.Uuid, destinationPath)
+	if destMatter != nil && inheritor != nil && destMatter.Uuid == inheritor.Uuid {
+		//move to where it is.
+		return
+	}
 	if destMatter != nil {
 		//if exist
 		if overwrite {
+			if inheritor != nil && !inheritor.Dir && !destMatter.Dir {
+				this.matterVersionService.inherit(request, destMatter, inheritor, user, space)
+			}
+
 			//delete.
 			this.Delete(request, destMatter, user, space)
 		} else {
@@ -819,51 +1013,79 @@ func (this *MatterService) move(request *http.Request, srcMatter *model.Matter,
 	srcPuuid := srcMatter.Puuid
 	destDirUuid := destDirMatter.Uuid
 
+	//move into another space.
+	srcSpace := space
+	crossSpace := srcMatter.SpaceUuid != destDirMatter.SpaceUuid
+	if crossSpace {
+		srcSpace = this.spaceDao.CheckByUuid(srcMatter.SpaceUuid)
+		this.leaveSpace(srcMatter, destDirMatter.SpaceUuid)
+
+		//the root dir of the space may not be created yet.
+		err := core.CONTEXT.GetStorage().MkdirAll(destDirMatter.StoragePath())
+		this.PanicError(err)
+	}
+
 	if srcMatter.Dir {
 
 		//if src is dir.
-		destAbsolutePath := destDirMatter.AbsolutePath() + "/" + srcMatter.Name
-		srcAbsolutePath := srcMatter.AbsolutePath()
+		destStoragePath := destDirMatter.StoragePath() + "/" + srcMatter.Name
 
-		//move src to dest on disk.
-		err := os.Rename(srcAbsolutePath, destAbsolutePath)
+		//move src to dest in storage.
+		err := core.CONTEXT.GetStorage().Rename(srcMatter.StoragePath(), destStoragePath)
 		this.PanicError(err)
 
+		//children are found in the space they are still in.
+		matters := this.matterDao.FindByPuuidAndSpaceUuid(srcMatter.Uuid, srcMatter.SpaceUuid)
+
 		//change info on db.
 		srcMatter.Puuid = destDirMatter.Uuid
 		srcMatter.Path = destDirMatter.Path + "/" + srcMatter.Name
+		srcMatter.SpaceUuid = destDirMatter.SpaceUuid
+		srcMatter.SpaceName = destDirMatter.SpaceName
 		srcMatter = this.matterDao.Save(srcMatter)
 
 		//reCompute the path.
-		matters := this.matterDao.FindByPuuidAndUserUuid(srcMatter.Uuid, srcMatter.UserUuid, nil)
 		for _, m := range matters {
 			this.adjustPath(m, srcMatter)
 		}
 
 	} else {
 
-		//if src is NOT dir.
-		destAbsolutePath := destDirMatter.AbsolutePath() + "/" + srcMatter.Name
-		srcAbsolutePath := srcMatter.AbsolutePath()
+		//if src is NOT dir. blob stays where it is.
+		if !srcMatter.IsBlob() {
+			destStoragePath := destDirMatter.StoragePath() + "/" + srcMatter.Name
 
-		//move src to dest on disk.
-		err := os.Rename(srcAbsolutePath, destAbsolutePath)
-		this.PanicError(err)
+			//move src to dest in storage.
+			err := core.CONTEXT.GetStorage().Rename(srcMatter.StoragePath(), destStoragePath)
+			this.PanicError(err)
+		}
 
 		//delete caches.
-		this.imageCacheDao.DeleteByMatterUuid(srcMatter.Uuid)
+		if !crossSpace {
+			this.imageCacheDao.DeleteByMatterUuid(srcMatter.Uuid)
+		}
 
 		//change info in db.
 		srcMatter.Puuid = destDirMatter.Uuid
 		srcMatter.Path = destDirMatter.Path + "/" + srcMatter.Name
+		srcMatter.SpaceUuid = destDirMatter.SpaceUuid
+		srcMatter.SpaceName = destDirMatter.SpaceName
 		srcMatter = this.matterDao.Save(srcMatter)
 
+		if crossSpace {
+			this.imageCacheService.Relocate(srcMatter)
+		}
+
 	}
 
 	//reCompute the size of src and dest.
-	this.ComputeRouteSize(srcPuuid, user, space)
+	this.ComputeRouteSize(srcPuuid, user, srcSpace)
 	this.ComputeRouteSize(destDirUuid, user, space)
 
+	if crossSpace {
+		this.shareService.Reparent(srcSpace.Uuid, space.Uuid)
+	}
+
 }
 
 // move srcMatter to destMatter(must be dir)
@@ -893,9 +1115,13 @@ func (this *MatterService) AtomicMove(request *http.Request, srcMatter *model.Ma
 		tmpMatter = tmpMatter.Parent
 	}
 
+	if srcMatter.SpaceUuid != destDirMatter.SpaceUuid {
+		this.checkTreeSizeLimit(request, space, []*model.Matter{srcMatter})
+	}
+
 	//handle the overwrite
 	destinationPath := destDirMatter.Path + "/" + srcMatter.Name
-	this.handleOverwrite(request, user, space, destinationPath, overwrite)
+	this.handleOverwrite(request, user, space, destinationPath, overwrite, srcMatter)
 
 	//do the move operation.
 	this.move(request, srcMatter, destDirMatter, user, space)
@@ -932,6 +1158,17 @@ func (this *MatterService) AtomicMoveBatch(request *http.Request, srcMatters []*
 		}
 	}
 
+	//the matters from other spaces take more room.
+	var crossSpaceMatters []*model.Matter
+	for _, srcMatter := range srcMatters {
+		if srcMatter.SpaceUuid != destDirMatter.SpaceUuid {
+			crossSpaceMatters = append(crossSpaceMatters, srcMatter)
+		}
+	}
+	if len(crossSpaceMatters) > 0 {
+		this.checkTreeSizeLimit(request, space, crossSpaceMatters)
+	}
+
 	for _, srcMatter := range srcMatters {
 		this.move(request, srcMatter, destDirMatter, user, space)
 	}
@@ -939,16 +1176,27 @@ func (this *MatterService) AtomicMoveBatch(request *http.Request, srcMatters []*
 }
 
 // copy srcMatter to destMatter. invoker must handled the overwrite and lock.
-func (this *MatterService) copy(request *http.Request, srcMatter *model.Matter, destDirMatter *model.Matter, name string) {
+func (this *MatterService) copy(request *http.Request, srcMatter *model.Matter, destDirMatter *model.Matter, name string, user *model.User) {
 
 	this.Logger.Info("copy srcPath = %s destPath = %s/%s", srcMatter.Path, destDirMatter.Path, name)
 
+	progress := jobProgressOf(request)
+	progress.Check()
+	defer progress.Add(1, srcMatter.Path)
+
+	//the copies in another space belong to who copies them.
+	userUuid := srcMatter.UserUuid
+	if srcMatter.SpaceUuid != destDirMatter.SpaceUuid {
+		userUuid = user.Uuid
+	}
+
 	if srcMatter.Dir {
 
 		newMatter := &model.Matter{
 			Puuid:     destDirMatter.Uuid,
-			UserUuid:  srcMatter.UserUuid,
-			SpaceName: srcMatter.SpaceName,
+			UserUuid:  userUuid,
+			SpaceName: destDirMatter.SpaceName,
+			SpaceUuid: destDirMatter.SpaceUuid,
 			Dir:       srcMatter.Dir,
 			Name:      name,
 			Md5:       "",
@@ -962,37 +1210,43 @@ func (this *MatterService) copy(request *http.Request, srcMatter *model.Matter,
 		newMatter = this.matterDao.Create(newMatter)
 
 		//make the dir
-		util.MakeDirAll(newMatter.AbsolutePath())
+		err := core.CONTEXT.GetStorage().MkdirAll(newMatter.StoragePath())
+		this.PanicError(err)
 
 		//copy children
-		matters := this.matterDao.FindByPuuidAndUserUuid(srcMatter.Uuid, srcMatter.UserUuid, nil)
+		matters := this.matterDao.FindByPuuidAndSpaceUuid(srcMatter.Uuid, srcMatter.SpaceUuid)
 		for _, m := range matters {
-			this.copy(request, m, newMatter, m.Name)
+			this.copy(request, m, newMatter, m.Name, user)
 		}
 
 	} else {
 
-		destAbsolutePath := destDirMatter.AbsolutePath() + "/" + name
-		srcAbsolutePath := srcMatter.AbsolutePath()
-
-		//copy file on disk.
-		util.CopyFile(srcAbsolutePath, destAbsolutePath)
-
 		newMatter := &model.Matter{
 			Puuid:     destDirMatter.Uuid,
-			UserUuid:  srcMatter.UserUuid,
-			SpaceName: srcMatter.SpaceName,
+			UserUuid:  userUuid,
+			SpaceName: destDirMatter.SpaceName,
+			SpaceUuid: destDirMatter.SpaceUuid,
 			Dir:       srcMatter.Dir,
 			Name:      name,
-			Md5:       "",
+			Md5:       srcMatter.Md5,
+			BlobUuid:  srcMatter.BlobUuid,
 			Size:      srcMatter.Size,
 			Privacy:   srcMatter.Privacy,
 			Path:      destDirMatter.Path + "/" + name,
 			Prop:      model.EMPTY_JSON_MAP,
 			VisitTime: time.Now(),
 		}
+
+		//physics file will be hashed into a blob while copying.
+		blob := this.retainBlob(request, srcMatter)
+		newMatter.Md5 = blob.Md5
+		newMatter.BlobUuid = blob.Uuid
+		newMatter.Size = blob.Size
+
 		newMatter = this.matterDao.Create(newMatter)
 
+		this.contentChanged(newMatter)
+
 	}
 }
 
@@ -1011,9 +1265,29 @@ func (this *MatterService) AtomicCopy(request *http.Request, srcMatter *model.Ma
 	}
 
 	destinationPath := destDirMatter.Path + "/" + name
-	this.handleOverwrite(request, user, space, destinationPath, overwrite)
 
-	this.copy(request, srcMatter, destDirMatter, name)
+	this.checkTreeSizeLimit(request, space, []*model.Matter{srcMatter})
+
+	//file overwrites file in place, so that the replaced content goes into history.
+	if overwrite && !srcMatter.Dir {
+		destMatter := this.matterDao.FindBySpaceUuidAndPathIncludeDeleted(space.Uuid, destinationPath)
+		if destMatter != nil && !destMatter.Dir && destMatter.Uuid != srcMatter.Uuid {
+			blob := this.retainBlob(request, srcMatter)
+			this.replaceContent(request, destMatter, blob, user, space)
+			return
+		}
+	}
+
+	this.handleOverwrite(request, user, space, destinationPath, overwrite, nil)
+
+	if progress := jobProgressOf(request); progress != nil {
+		progress.SetTotal(this.matterDao.CountBySpaceUuidAndPath(srcMatter.SpaceUuid, srcMatter.Path))
+	}
+
+	this.copy(request, srcMatter, destDirMatter, name, user)
+
+	//compute the size of dest.
+	this.ComputeRouteSize(destDirMatter.Uuid, user, space)
 }
 
 // rename matter to name
@@ -1042,6 +1316,10 @@ func (this *MatterService) AtomicRename(request *http.Request, matter *model.Mat
 	oldMatter := this.matterDao.FindBySpaceNameAndPuuidAndDirAndName(space.Name, matter.Puuid, "", name)
 	if oldMatter != nil {
 		if overwrite {
+			if !oldMatter.Dir && !matter.Dir {
+				this.matterVersionService.inherit(request, oldMatter, matter, user, space)
+			}
+
 			//delete this one.
 			this.Delete(request, oldMatter, user, space)
 		} else {
@@ -1053,13 +1331,12 @@ func (this *MatterService) AtomicRename(request *http.Request, matter *model.Mat
 	if matter.Dir {
 		//如果源是文件夹
 
-		oldAbsolutePath := matter.AbsolutePath()
-		absoluteDirPath := util.GetDirOfPath(oldAbsolutePath)
+		oldStoragePath := matter.StoragePath()
 		relativeDirPath := util.GetDirOfPath(matter.Path)
-		newAbsolutePath := absoluteDirPath + "/" + name
+		newStoragePath := util.GetDirOfPath(oldStoragePath) + "/" + name
 
 		//物理文件一口气移动
-		err := os.Rename(oldAbsolutePath, newAbsolutePath)
+		err := core.CONTEXT.GetStorage().Rename(oldStoragePath, newStoragePath)
 		this.PanicError(err)
 
 		//修改数据库中信息
@@ -1076,14 +1353,16 @@ func (this *MatterService) AtomicRename(request *http.Request, matter *model.Mat
 	} else {
 		//如果源是普通文件
 
-		oldAbsolutePath := matter.AbsolutePath()
-		absoluteDirPath := util.GetDirOfPath(oldAbsolutePath)
 		relativeDirPath := util.GetDirOfPath(matter.Path)
-		newAbsolutePath := absoluteDirPath + "/" + name
 
-		//物理文件进行移动
-		err := os.Rename(oldAbsolutePath, newAbsolutePath)
-		this.PanicError(err)
+		//物理文件进行移动，blob无需移动
+		if !matter.IsBlob() {
+			oldStoragePath := matter.StoragePath()
+			newStoragePath := util.GetDirOfPath(oldStoragePath) + "/" + name
+
+			err := core.CONTEXT.GetStorage().Rename(oldStoragePath, newStoragePath)
+			this.PanicError(err)
+		}
 
 		//删除对应的缓存。
 		this.imageCacheDao.DeleteByMatterUuid(matter.Uuid)
@@ -1120,6 +1399,16 @@ func (this *MatterService) AtomicMirror(request *http.Request, srcPath string, d
 		panic(result.BadRequest("dest matter has been deleted. Cannot mirror."))
 	}
 
+	//counting the local files costs another walk, which is only worth for a job.
+	if progress := jobProgressOf(request); progress != nil {
+		var total int64 = 0
+		_ = filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+			total++
+			return nil
+		})
+		progress.SetTotal(total)
+	}
+
 	this.mirror(request, srcPath, destDirMatter, overwrite, user, space)
 }
 
@@ -1144,6 +1433,10 @@ func (this *MatterService) mirror(request *http.Request, srcPath string, destDir
 
 	this.Logger.Info("mirror srcPath = %s destPath = %s", srcPath, destDirMatter.Path)
 
+	progress := jobProgressOf(request)
+	progress.Check()
+	defer progress.Add(1, srcPath)
+
 	if fileStat.IsDir() {
 
 		//判断当前文件夹下，文件是否已经存在了。
@@ -1167,14 +1460,9 @@ func (this *MatterService) mirror(request *http.Request, srcPath string, destDir
 
 		//判断当前文件夹下，文件是否已经存在了。
 		matter := this.matterDao.FindBySpaceNameAndPuuidAndDirAndName(space.Name, destDirMatter.Uuid, model.FALSE, fileStat.Name())
-		if matter != nil {
-			//如果是覆盖，那么删除之前的文件
-			if overwrite {
-				this.Delete(request, matter, user, space)
-			} else {
-				//直接完成。
-				return
-			}
+		if matter != nil && !overwrite {
+			//直接完成。
+			return
 		}
 
 		//准备直接从本地上传了。
@@ -1185,7 +1473,12 @@ func (this *MatterService) mirror(request *http.Request, srcPath string, destDir
 			this.PanicError(err)
 		}()
 
-		this.Upload(request, file, nil, user, space, destDirMatter, fileStat.Name(), true)
+		if matter != nil {
+			//如果是覆盖，旧的内容保留为历史版本
+			this.Overwrite(request, matter, file, user, space)
+		} else {
+			this.Upload(request, file, nil, user, space, destDirMatter, fileStat.Name(), true)
+		}
 
 	}
 
@@ -1277,9 +1570,120 @@ func (this *MatterService) WrapChildrenDetail(request *http.Request, matter *mod
 // fetch a matter's detail with parent info.
 func (this *MatterService) Detail(request *http.Request, uuid string) *model.Matter {
 	matter := this.matterDao.CheckByUuid(uuid)
+	if !matter.Dir {
+		matter.Media = this.matterMediaDao.FindByMatterUuid(matter.Uuid)
+	}
 	return this.WrapParentDetail(request, matter)
 }
 
+// preview a piece of a text matter in UTF-8. lines > 0 means the first lines, otherwise the complete lines inside [offset, offset+length).
+func (this *MatterService) PreviewText(matter *model.Matter, lines int, offset int64, length int64) *model.TextPreview {
+
+	if matter.Dir {
+		panic(result.BadRequest("directory cannot be previewed."))
+	}
+	if offset < 0 || offset > matter.Size {
+		panic(result.BadRequest("offset %d out of range.", offset))
+	}
+
+	file, err := core.CONTEXT.GetStorage().Open(matter.StoragePath())
+	this.PanicError(err)
+	defer func() {
+		e := file.Close()
+		this.PanicError(e)
+	}()
+
+	head := this.readText(file, 0, model.TEXT_PREVIEW_DETECT_BYTES)
+	encoding := charset.Detect(head)
+	utf16 := encoding == charset.UTF16LE || encoding == charset.UTF16BE
+	if !utf16 && bytes.IndexByte(head, 0) != -1 {
+		panic(result.BadRequest("%s is not a text file.", matter.Name))
+	}
+
+	var start int64 = 0
+	var data []byte
+	if lines > 0 {
+		data = this.readText(file, 0, model.TEXT_PREVIEW_MAX_BYTES)
+	} else {
+		var unit int64 = 1
+		if utf16 {
+			unit = 2
+		}
+		start = offset - offset%unit
+		if start > 0 {
+			//skip the broken line unless the piece starts right after a line break.
+			data = this.readText(file, start-unit, length+unit)
+			skip := charset.IndexLine(data, encoding, 1)
+			if skip == -1 {
+				skip = int(unit)
+			}
+			data = data[skip:]
+			start += int64(skip) - unit
+		} else {
+			data = this.readText(file, 0, length)
+		}
+	}
+
+	//end at a line break unless the file ends.
+	end := -1
+	if lines > 0 {
+		end = charset.IndexLine(data, encoding, lines)
+	}
+	if end == -1 && start+int64(len(data)) < matter.Size {
+		end = charset.LastIndexLine(data, encoding)
+	}
+	if end != -1 {
+		data = data[:end]
+	}
+
+	content, err := charset.Decode(data, encoding)
+	this.PanicError(err)
+
+	preview := &model.TextPreview{
+		Encoding:       encoding,
+		Content:        content,
+		Offset:         start,
+		Length:         int64(len(data)),
+		Size:           matter.Size,
+		LineCount:      this.countTextLines(data, encoding, 0),
+		TotalLineCount: -1,
+		Eof:            start+int64(len(data)) >= matter.Size,
+	}
+
+	if matter.Size <= model.TEXT_PREVIEW_COUNT_MAX_SIZE {
+		var breaks int64 = 0
+		var last []byte
+		for chunkOffset := int64(0); chunkOffset < matter.Size; chunkOffset += model.TEXT_PREVIEW_DETECT_BYTES {
+			if last != nil {
+				breaks += int64(charset.CountLineBreaks(last, encoding))
+			}
+			last = this.readText(file, chunkOffset, model.TEXT_PREVIEW_DETECT_BYTES)
+		}
+		preview.TotalLineCount = this.countTextLines(last, encoding, breaks)
+	}
+
+	return preview
+}
+
+// read at most length bytes. less at the end of the file.
+func (this *MatterService) readText(file io.ReaderAt, offset int64, length int64) []byte {
+	data := make([]byte, length)
+	n, err := file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		this.PanicError(err)
+	}
+	return data[:n]
+}
+
+// lines of the data. the previous line breaks are added. the last line may not end with a line break.
+func (this *MatterService) countTextLines(data []byte, encoding string, previousBreaks int64) int64 {
+	count := previousBreaks + int64(charset.CountLineBreaks(data, encoding))
+	if len(data) > 0 && charset.LastIndexLine(data, encoding) != len(data) {
+		count++
+	}
+	return count
+}
+
 // crawl a url to dirMatter
 func (this *MatterService) AtomicCrawl(request *http.Request, url string, filename string, user *model.User, space *model.Space, dirMatter *model.Matter, privacy bool) *model.Matter {
 
@@ -1302,160 +1706,342 @@ func (this *MatterService) AtomicCrawl(request *http.Request, url string, filena
 		panic(result.BadRequest("filename cannot be null."))
 	}
 
-	//download from url.
-	resp, err := http.Get(url)
+	//download from url. a cancelled job stops downloading.
+	progress := jobProgressOf(request)
+	crawlRequest, err := http.NewRequestWithContext(progress.Context(), http.MethodGet, url, nil)
 	this.PanicError(err)
+	resp, err := http.DefaultClient.Do(crawlRequest)
+	this.PanicError(err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
 	//if resp is not ok.
 	if resp.StatusCode != 200 {
 		panic(result.BadRequest("error when crawl from url."))
 	}
 
-	return this.Upload(request, resp.Body, nil, user, space, dirMatter, filename, privacy)
+	var body io.Reader = resp.Body
+	if progress != nil {
+		progress.SetTotal(resp.ContentLength)
+		body = &jobProgressReader{reader: resp.Body, progress: progress}
+	}
+
+	return this.Upload(request, body, nil, user, space, dirMatter, filename, privacy)
 }
 
-// adjust a matter's path.
-func (this *MatterService) adjustPath(matter *model.Matter, parentMatter *model.Matter) {
+// open an archive matter. the caller should close the file.
+func (this *MatterService) openArchive(matter *model.Matter) (format string, file storage.File, size int64) {
 
 	if matter.Dir {
+		panic(result.BadRequest("directory is not an archive."))
+	}
+	format = archive.DetectFormat(matter.Name)
+	if format == "" {
+		panic(result.BadRequest("%s is not a zip, tar or tar.gz file.", matter.Name))
+	}
 
-		matter.Path = parentMatter.Path + "/" + matter.Name
-		matter = this.matterDao.Save(matter)
+	driver := core.CONTEXT.GetStorage()
+	fileInfo, err := driver.Stat(matter.StoragePath())
+	this.PanicError(err)
+	file, err = driver.Open(matter.StoragePath())
+	this.PanicError(err)
+	return format, file, fileInfo.Size()
+}
 
-		//adjust children.
-		matters := this.matterDao.FindByPuuidAndUserUuid(matter.Uuid, matter.UserUuid, nil)
-		for _, m := range matters {
-			this.adjustPath(m, matter)
-		}
+// list the entries of an archive matter without extracting. the missing parent directories are added.
+func (this *MatterService) ListArchive(matter *model.Matter) []*model.ArchiveEntry {
 
-	} else {
-		//delete caches.
-		this.imageCacheDao.DeleteByMatterUuid(matter.Uuid)
+	format, file, size := this.openArchive(matter)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
 
-		matter.Path = parentMatter.Path + "/" + matter.Name
-		matter = this.matterDao.Save(matter)
+	entries, err := archive.List(format, file, size)
+	if err != nil {
+		panic(result.BadRequest("cannot read %s. %s", matter.Name, err.Error()))
 	}
 
-}
-
-// delete someone's EyeblueTank files according to physics files.
-func (this *MatterService) DeleteByPhysics(request *http.Request, user *model.User, space *model.Space) {
-
-	if user == nil {
-		panic(result.BadRequest("user cannot be nil."))
+	archiveEntries := make([]*model.ArchiveEntry, 0, len(entries))
+	dirs := make(map[string]bool)
+	var addDir func(dirPath string, modTime time.Time)
+	addDir = func(dirPath string, modTime time.Time) {
+		if dirPath == "." || dirs[dirPath] {
+			return
+		}
+		dirs[dirPath] = true
+		addDir(path.Dir(dirPath), modTime)
+		archiveEntries = append(archiveEntries, &model.ArchiveEntry{Path: dirPath, Name: path.Base(dirPath), Dir: true, ModTime: modTime})
 	}
 
-	//scan user's file. scan level by level.
-	rootMatter := model.NewRootMatter(space)
-	this.deleteFolderByPhysics(request, rootMatter, user, space)
+	for _, entry := range entries {
+		if entry.Dir {
+			addDir(entry.Name, entry.ModTime)
+			continue
+		}
+		addDir(path.Dir(entry.Name), entry.ModTime)
+		archiveEntries = append(archiveEntries, &model.ArchiveEntry{
+			Path:    entry.Name,
+			Name:    path.Base(entry.Name),
+			Size:    entry.Size,
+			ModTime: entry.ModTime,
+		})
+	}
 
+	return archiveEntries
 }
 
-func (this *MatterService) deleteFolderByPhysics(request *http.Request, dirMatter *model.Matter, user *model.User, space *model.Space) {
+// send a single file in an archive matter without extracting. the entry is streamed, so range is not supported.
+func (this *MatterService) DownloadArchiveEntry(
+	writer http.ResponseWriter,
+	request *http.Request,
+	matter *model.Matter,
+	entryPath string,
+	withContentDisposition bool) {
 
-	//scan user's file. scan level by level.
-	this.matterDao.PageHandle(dirMatter.Uuid, "", space.Uuid, "", "", "", nil, nil, func(matter *model.Matter) {
+	cleanPath, err := archive.CleanName(entryPath)
+	if err != nil || cleanPath == "" {
+		panic(result.BadRequest("entry path %s is invalid.", entryPath))
+	}
+	entryPath = cleanPath
 
-		if matter.Dir {
-			//delete children first.
-			this.deleteFolderByPhysics(request, matter, user, space)
+	format, file, size := this.openArchive(matter)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
+
+	//once the response has started, cannot send an error result any more.
+	started := false
+	defer func() {
+		if err := recover(); err != nil {
+			if !started {
+				panic(err)
+			}
+			this.Logger.Error("sending %s in %s aborted. %v", entryPath, matter.Name, err)
+			panic(http.ErrAbortHandler)
+		}
+	}()
+
+	err = archive.Find(format, file, size, entryPath, func(entry *archive.Entry, reader io.Reader) error {
+		if entry.Dir {
+			panic(result.BadRequest("%s is a directory.", entryPath))
 		}
 
-		if !util.PathExists(matter.AbsolutePath()) {
-			this.Logger.Info("physics file not exist. delete from tank. %s", matter.Name)
-			this.AtomicDelete(nil, matter, user, space)
+		filename := path.Base(entry.Name)
+		if withContentDisposition {
+			writer.Header().Set("content-disposition", "attachment; filename=\""+url.QueryEscape(filename)+"\"")
 		}
+		writer.Header().Set("Content-Type", util.GetFallbackMimeType(filename, "application/octet-stream"))
+		writer.Header().Set("Content-Length", fmt.Sprintf("%d", entry.Size))
+		writer.WriteHeader(http.StatusOK)
+		started = true
 
+		_, err := io.Copy(writer, &download.ContextReader{Context: request.Context(), Reader: reader})
+		return err
 	})
+	if err == archive.ErrEntryNotFound {
+		panic(result.NotFound("%s not found in %s.", entryPath, matter.Name))
+	}
+	if err != nil && !started {
+		panic(result.BadRequest("cannot read %s. %s", matter.Name, err.Error()))
+	}
+	this.PanicError(err)
 }
 
-// scan someone's physics files to EyeblueTank
-func (this *MatterService) ScanPhysics(request *http.Request, user *model.User, space *model.Space) {
+// extract an archive file into dirMatter. directories are merged, files with the same name are renamed.
+func (this *MatterService) AtomicExtract(request *http.Request, matter *model.Matter, dirMatter *model.Matter, user *model.User, space *model.Space) []*model.Matter {
 
 	if user == nil {
 		panic(result.BadRequest("user cannot be nil."))
 	}
 
-	rootDirPath := model.GetSpaceMatterRootDir(user.Username)
-	this.Logger.Info("scan %s's root dir %s", user.Username, rootDirPath)
+	if matter.Dir || matter.Deleted {
+		panic(result.BadRequest("only archive file can be extracted."))
+	}
 
-	rootExists := util.PathExists(rootDirPath)
-	if !rootExists {
-		util.MakeDirAll(rootDirPath)
+	if !dirMatter.Dir || dirMatter.Deleted {
+		panic(result.BadRequest("Dir has been deleted. Cannot extract under it."))
 	}
-	rootFileInfo, err := os.Lstat(rootDirPath)
-	if err != nil {
-		panic(result.BadRequest("cannot get root file info."))
+
+	if matter.SpaceUuid != space.Uuid || dirMatter.SpaceUuid != space.Uuid {
+		panic(result.BadRequest("file's space not the same"))
 	}
 
-	rootMatter := model.NewRootMatter(space)
-	this.scanPhysicsFolder(request, rootFileInfo, rootMatter, user, space)
-}
+	format := archive.DetectFormat(matter.Name)
+	if format == "" {
+		panic(result.BadRequest("%s is not a zip, tar or tar.gz file.", matter.Name))
+	}
 
-func (this *MatterService) scanPhysicsFolder(request *http.Request, dirInfo os.FileInfo, dirMatter *model.Matter, user *model.User, space *model.Space) {
-	if !dirInfo.IsDir() {
-		return
+	this.userService.MatterLock(user.Uuid)
+	defer this.userService.MatterUnlock(user.Uuid)
+
+	driver := core.CONTEXT.GetStorage()
+	fileInfo, err := driver.Stat(matter.StoragePath())
+	this.PanicError(err)
+	file, err := driver.Open(matter.StoragePath())
+	this.PanicError(err)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
+
+	//check all the entries before creating anything.
+	entries, err := archive.List(format, file, fileInfo.Size())
+	if err != nil {
+		panic(result.BadRequest("cannot read %s. %s", matter.Name, err.Error()))
 	}
+	this.checkExtractEntries(request, entries, dirMatter, space)
 
-	//fetch all matters under this folder.
-	_, matters := this.matterDao.PlainPage(0, 1000, dirMatter.Uuid, "", space.Uuid, "", "", "", nil, nil, nil)
-	nameMatterMap := make(map[string]*model.Matter)
-	for _, m := range matters {
-		nameMatterMap[m.Name] = m
+	//top level matters extracted.
+	var matters []*model.Matter
+	dirMatters := map[string]*model.Matter{"": dirMatter}
+
+	//create the parent directories of an entry.
+	var ensureDir func(dirPath string) *model.Matter
+	ensureDir = func(dirPath string) *model.Matter {
+		if m, ok := dirMatters[dirPath]; ok {
+			return m
+		}
+		parentPath := path.Dir(dirPath)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		m := this.createDirectory(request, ensureDir(parentPath), path.Base(dirPath), user, space)
+		if m.Deleted {
+			panic(result.BadRequestI18n(request, i18n.MatterRecycleBinExist, m.Name))
+		}
+		if parentPath == "" {
+			matters = append(matters, m)
+		}
+		dirMatters[dirPath] = m
+		return m
 	}
 
-	dirPath := dirMatter.AbsolutePath()
-	names, err := util.ReadDirNames(dirPath)
+	err = archive.Walk(format, file, fileInfo.Size(), func(entry *archive.Entry, reader io.Reader) error {
+
+		if entry.Dir {
+			ensureDir(entry.Name)
+			return nil
+		}
+
+		parentPath := path.Dir(entry.Name)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		parentMatter := ensureDir(parentPath)
+		filename := this.uniqueFilename(space, parentMatter, path.Base(entry.Name))
+
+		blob := this.storeBlob(request, reader, nil, 0)
+		m := this.createNonDirMatter(parentMatter, filename, blob.Size, matter.Privacy, user, space, blob)
+		if parentPath == "" {
+			matters = append(matters, m)
+		}
+		return nil
+	})
 	if err != nil {
-		this.Logger.Error("occur error when ReadDirNames %s %s", dirPath, err.Error())
-		return
+		panic(result.BadRequest("error while extracting %s. %s", matter.Name, err.Error()))
 	}
-	for _, name := range names {
-		fileFullPath := filepath.Join(dirPath, name)
-		fileInfo, err := os.Lstat(fileFullPath)
-		if err != nil {
-			this.Logger.Error("occur error when Lstat %s %s", name, err.Error())
-			continue
-		}
 
-		//find ther matter
-		var matter *model.Matter
-		_, ok := nameMatterMap[name]
-		if ok {
-			//exits. check the basic info.
-			matter = nameMatterMap[name]
-			//only check the fileSize.
-			if !matter.Dir {
-				if matter.Size != fileInfo.Size() {
-					this.Logger.Info("update matter: %s size:%d -> %d", name, matter.Size, fileInfo.Size())
-					this.updateNonDirMatter(matter, fileInfo.Size(), user, space)
-				}
-			} else {
+	this.Logger.Info("extract %s to %s. %d entries", matter.Path, dirMatter.Path, len(entries))
+
+	return matters
+}
+
+// check the names, depth and size of the entries to be extracted.
+func (this *MatterService) checkExtractEntries(request *http.Request, entries []*archive.Entry, dirMatter *model.Matter, space *model.Space) {
+
+	var sumSize int64 = 0
+	for _, entry := range entries {
 
-				//recursive scan this folder.
-				this.scanPhysicsFolder(request, fileInfo, matter, user, space)
+		for _, name := range strings.Split(entry.Name, "/") {
+			model.CheckMatterName(request, name)
+		}
 
+		dirPath := dirMatter.Path + "/" + entry.Name
+		if !entry.Dir {
+			dirPath = path.Dir(dirPath)
+		}
+		folders := strings.Split(dirPath, "/")
+		if len(folders) > model.MATTER_NAME_MAX_DEPTH {
+			panic(result.BadRequestI18n(request, i18n.MatterDepthExceedLimit, len(folders), model.MATTER_NAME_MAX_DEPTH))
+		}
+
+		if !entry.Dir {
+			if space.SizeLimit >= 0 && entry.Size > space.SizeLimit {
+				panic(result.BadRequestI18n(request, i18n.MatterSizeExceedLimit, util.HumanFileSize(entry.Size), util.HumanFileSize(space.SizeLimit)))
 			}
+			sumSize += entry.Size
+		}
+	}
 
-		} else {
+	if space.TotalSizeLimit >= 0 && space.TotalSize+sumSize > space.TotalSizeLimit {
+		panic(result.BadRequestI18n(request, i18n.MatterSizeExceedTotalLimit, util.HumanFileSize(space.TotalSize), util.HumanFileSize(space.TotalSizeLimit)))
+	}
+}
+
+// a file name not used in dirMatter. eg. a.txt -> a(1).txt
+func (this *MatterService) uniqueFilename(space *model.Space, dirMatter *model.Matter, filename string) string {
+
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	name := filename
+	for i := 1; this.matterDao.FindBySpaceUuidAndPuuidAndDirAndName(space.Uuid, dirMatter.Uuid, false, name) != nil; i++ {
+		name = fmt.Sprintf("%s(%d)%s", base, i, ext)
+	}
+	return name
+}
 
-			if fileInfo.IsDir() {
+// adjust a matter's path. the matter follows its parent into another space as well.
+func (this *MatterService) adjustPath(matter *model.Matter, parentMatter *model.Matter) {
 
-				//create folder.
-				matter = this.createDirectory(request, dirMatter, name, user, space)
+	crossSpace := matter.SpaceUuid != parentMatter.SpaceUuid
+	if crossSpace {
+		this.leaveSpace(matter, parentMatter.SpaceUuid)
+	}
 
-				//recursive scan this folder.
-				this.scanPhysicsFolder(request, fileInfo, matter, user, space)
+	if matter.Dir {
 
-			} else {
+		//children are found in the space they are still in.
+		matters := this.matterDao.FindByPuuidAndSpaceUuid(matter.Uuid, matter.SpaceUuid)
 
-				//not exist. add basic info.
-				this.Logger.Info("Create matter: %s size:%d", name, fileInfo.Size())
-				matter = this.createNonDirMatter(dirMatter, name, fileInfo.Size(), true, user, space)
+		matter.Path = parentMatter.Path + "/" + matter.Name
+		matter.SpaceUuid = parentMatter.SpaceUuid
+		matter.SpaceName = parentMatter.SpaceName
+		matter = this.matterDao.Save(matter)
 
-			}
+		//adjust children.
+		for _, m := range matters {
+			this.adjustPath(m, matter)
+		}
+
+	} else {
+		//delete caches.
+		if !crossSpace {
+			this.imageCacheDao.DeleteByMatterUuid(matter.Uuid)
+		}
+
+		matter.Path = parentMatter.Path + "/" + matter.Name
+		matter.SpaceUuid = parentMatter.SpaceUuid
+		matter.SpaceName = parentMatter.SpaceName
+		matter = this.matterDao.Save(matter)
 
+		if crossSpace {
+			this.imageCacheService.Relocate(matter)
 		}
 	}
+
+}
+
+// the favorites and the recent records follow the matter into another space, unless their users cannot read it.
+// tags and albums belong to the old space, so they are dropped.
+func (this *MatterService) leaveSpace(matter *model.Matter, spaceUuid string) {
+	this.favoriteDao.DeleteByMatterUuidAndNotSpaceReader(matter.Uuid, spaceUuid)
+	this.recentDao.DeleteByMatterUuidAndNotSpaceReader(matter.Uuid, spaceUuid)
+	this.favoriteDao.UpdateSpaceUuidByMatterUuid(matter.Uuid, spaceUuid)
+	this.recentDao.UpdateSpaceUuidByMatterUuid(matter.Uuid, spaceUuid)
+	this.matterTagDao.DeleteByMatterUuid(matter.Uuid)
+	this.albumMatterDao.DeleteByMatterUuid(matter.Uuid)
 }
 
 // clean all the expired deleted matters
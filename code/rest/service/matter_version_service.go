@@ -0,0 +1,239 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/download"
+	"box/code/tool/result"
+	"net/http"
+	"time"
+)
+
+/**
+ * history versions of files. a matter has no version until it's overwritten,
+ * then both the replaced content and the new content are recorded. the latest version is the current content.
+ */
+//@Service
+type MatterVersionService struct {
+	bean.BaseBean
+	matterVersionDao *dao.MatterVersionDao
+	matterDao        *dao.MatterDao
+	blobDao          *dao.BlobDao
+	userDao          *dao.UserDao
+	spaceDao         *dao.SpaceDao
+	matterService    *MatterService
+	userService      *UserService
+}
+
+func (this *MatterVersionService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.matterVersionDao)
+	if b, ok := b.(*dao.MatterVersionDao); ok {
+		this.matterVersionDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.blobDao)
+	if b, ok := b.(*dao.BlobDao); ok {
+		this.blobDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.userDao)
+	if b, ok := b.(*dao.UserDao); ok {
+		this.userDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.spaceDao)
+	if b, ok := b.(*dao.SpaceDao); ok {
+		this.spaceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.userService)
+	if b, ok := b.(*UserService); ok {
+		this.userService = b
+	}
+
+}
+
+// list versions of a matter. the latest first.
+func (this *MatterVersionService) List(matter *model.Matter) []*model.MatterVersion {
+
+	versions := this.matterVersionDao.ListByMatterUuid(matter.Uuid)
+	if len(versions) > 0 {
+		versions[0].Current = true
+	}
+
+	return versions
+}
+
+// download the content of a version.
+func (this *MatterVersionService) Download(writer http.ResponseWriter, request *http.Request, version *model.MatterVersion, matter *model.Matter) {
+
+	file, err := core.CONTEXT.GetStorage().Open(model.GetBlobStoragePath(version.Md5))
+	this.PanicError(err)
+	defer func() {
+		err := file.Close()
+		this.PanicError(err)
+	}()
+
+	download.DownloadContent(writer, request, file, version.CreateTime, version.Size, matter.Name, true)
+}
+
+// restore a version. the current content will be kept as a new version.
+func (this *MatterVersionService) AtomicRestore(request *http.Request, version *model.MatterVersion, matter *model.Matter, user *model.User, space *model.Space) *model.Matter {
+
+	this.userService.MatterLock(matter.UserUuid)
+	defer this.userService.MatterUnlock(matter.UserUuid)
+
+	versions := this.matterVersionDao.ListByMatterUuid(matter.Uuid)
+	if len(versions) > 0 && versions[0].Uuid == version.Uuid {
+		panic(result.BadRequest("this version is the current content."))
+	}
+
+	this.blobDao.Retain(version.BlobUuid)
+	blob := this.blobDao.CheckByUuid(version.BlobUuid)
+
+	return this.matterService.replaceContent(request, matter, blob, user, space)
+}
+
+// delete a history version. the current one cannot be deleted.
+func (this *MatterVersionService) Delete(request *http.Request, version *model.MatterVersion) {
+
+	versions := this.matterVersionDao.ListByMatterUuid(version.MatterUuid)
+	if len(versions) > 0 && versions[0].Uuid == version.Uuid {
+		panic(result.BadRequest("this version is the current content."))
+	}
+
+	this.matterVersionDao.Delete(version)
+}
+
+// make sure the current content of a file has a version. return the current version.
+func (this *MatterVersionService) keep(request *http.Request, matter *model.Matter) *model.MatterVersion {
+
+	versions := this.matterVersionDao.ListByMatterUuid(matter.Uuid)
+	if len(versions) > 0 {
+		return versions[0]
+	}
+
+	//first overwrite. the content was written by the owner when the file updated last time.
+	blob := this.matterService.retainBlob(request, matter)
+	version := &model.MatterVersion{
+		Sort:       matter.UpdateTime.UnixNano() / 1e6,
+		CreateTime: matter.UpdateTime,
+		MatterUuid: matter.Uuid,
+		SpaceUuid:  matter.SpaceUuid,
+		UserUuid:   matter.UserUuid,
+		Username:   this.findUsername(matter.UserUuid),
+		BlobUuid:   blob.Uuid,
+		Md5:        blob.Md5,
+		Size:       blob.Size,
+	}
+
+	return this.matterVersionDao.Create(version)
+}
+
+// record the current content of a file written by user.
+func (this *MatterVersionService) record(matter *model.Matter, user *model.User, space *model.Space) *model.MatterVersion {
+
+	this.blobDao.Retain(matter.BlobUuid)
+	version := &model.MatterVersion{
+		Sort:       this.nextSort(matter.Uuid),
+		MatterUuid: matter.Uuid,
+		SpaceUuid:  matter.SpaceUuid,
+		UserUuid:   user.Uuid,
+		Username:   user.Username,
+		BlobUuid:   matter.BlobUuid,
+		Md5:        matter.Md5,
+		Size:       matter.Size,
+	}
+	version = this.matterVersionDao.Create(version)
+
+	this.trim(matter.Uuid, space)
+
+	return version
+}
+
+// the replaced file's versions go to the inheritor, whose content becomes the latest one.
+func (this *MatterVersionService) inherit(request *http.Request, replaced *model.Matter, inheritor *model.Matter, user *model.User, space *model.Space) {
+
+	this.keep(request, replaced)
+	current := this.keep(request, inheritor)
+
+	current.Sort = this.nextSort(replaced.Uuid)
+	this.matterVersionDao.Save(current)
+
+	this.matterVersionDao.UpdateMatterUuid(replaced.Uuid, inheritor.Uuid)
+
+	this.trim(inheritor.Uuid, space)
+}
+
+// sort of a new version, later than all the existing ones.
+func (this *MatterVersionService) nextSort(matterUuid string) int64 {
+	sort := time.Now().UnixNano() / 1e6
+	versions := this.matterVersionDao.ListByMatterUuid(matterUuid)
+	if len(versions) > 0 && versions[0].Sort >= sort {
+		sort = versions[0].Sort + 1
+	}
+	return sort
+}
+
+// delete the versions beyond the space's limit. a version's age counts from when it's replaced.
+func (this *MatterVersionService) trim(matterUuid string, space *model.Space) {
+
+	versions := this.matterVersionDao.ListByMatterUuid(matterUuid)
+	expireSort := time.Now().AddDate(0, 0, int(-space.VersionKeepDays)).UnixNano() / 1e6
+
+	//the first one is the current content.
+	for i := 1; i < len(versions); i++ {
+		exceed := space.VersionLimit >= 0 && int64(i) > space.VersionLimit
+		expired := space.VersionKeepDays >= 0 && versions[i-1].Sort < expireSort
+		if exceed || expired {
+			this.Logger.Info("delete version %s of matter %s", versions[i].Uuid, matterUuid)
+			this.matterVersionDao.Delete(versions[i])
+		}
+	}
+}
+
+// clean the expired versions of all spaces.
+func (this *MatterVersionService) CleanExpiredVersions() {
+
+	this.spaceDao.PageHandle(func(space *model.Space) {
+
+		if space.VersionKeepDays < 0 {
+			return
+		}
+
+		this.Logger.Info("Clean %s 's expired versions", space.Name)
+
+		expireSort := time.Now().AddDate(0, 0, int(-space.VersionKeepDays)).UnixNano() / 1e6
+		matterUuids := this.matterVersionDao.ListMatterUuidsBySpaceUuidAndSortBefore(space.Uuid, expireSort)
+		for _, matterUuid := range matterUuids {
+			if this.matterDao.FindByUuid(matterUuid) == nil {
+				//the matter has gone.
+				this.matterVersionDao.DeleteByMatterUuid(matterUuid)
+			} else {
+				this.trim(matterUuid, space)
+			}
+		}
+	})
+}
+
+func (this *MatterVersionService) findUsername(userUuid string) string {
+	user := this.userDao.FindByUuid(userUuid)
+	if user == nil {
+		return ""
+	}
+	return user.Username
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"net/http"
+	"net/url"
+)
+
+// @Service
+type PhotoService struct {
+	bean.BaseBean
+	photoDao          *dao.PhotoDao
+	matterMediaDao    *dao.MatterMediaDao
+	imageCacheService *ImageCacheService
+}
+
+func (this *PhotoService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.photoDao)
+	if b, ok := b.(*dao.PhotoDao); ok {
+		this.photoDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterMediaDao)
+	if b, ok := b.(*dao.MatterMediaDao); ok {
+		this.matterMediaDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.imageCacheService)
+	if b, ok := b.(*ImageCacheService); ok {
+		this.imageCacheService = b
+	}
+
+}
+
+// group the photos of a space by capture time.
+func (this *PhotoService) Timeline(request *http.Request, space *model.Space, granularity string) []*model.PhotoBucket {
+
+	keyLength, ok := model.PHOTO_GRANULARITY_KEY_LENGTHS[granularity]
+	if !ok {
+		panic(result.BadRequest("granularity can only be year/month/day"))
+	}
+
+	return this.photoDao.Timeline(space.Uuid, keyLength)
+}
+
+// page the photos of a space. key is a bucket of the timeline. empty means all.
+func (this *PhotoService) Page(request *http.Request, page int, pageSize int, space *model.Space, key string) *model.Pager {
+
+	validKey := key == ""
+	for _, keyLength := range model.PHOTO_GRANULARITY_KEY_LENGTHS {
+		validKey = validKey || len(key) == keyLength
+	}
+	if !validKey {
+		panic(result.BadRequest("key should be like 2024, 2024-05 or 2024-05-01"))
+	}
+
+	ir := this.CheckIr(request)
+
+	count, matters := this.photoDao.Page(page, pageSize, space.Uuid, key)
+
+	return model.NewPager(page, pageSize, count, this.Wrap(request, matters, ir))
+}
+
+// the resize parameter of thumbnails. same as the ir of preview.
+func (this *PhotoService) CheckIr(request *http.Request) string {
+	if request.FormValue("ir") == "" {
+		return model.PHOTO_DEFAULT_IR
+	}
+	//panic if the format is wrong.
+	this.imageCacheService.ParseIr(request.FormValue("ir"))
+	return request.FormValue("ir")
+}
+
+// wrap matters with their capture time and thumbnail.
+func (this *PhotoService) Wrap(request *http.Request, matters []*model.Matter, ir string) []*model.Photo {
+
+	var matterUuids []string
+	for _, matter := range matters {
+		matterUuids = append(matterUuids, matter.Uuid)
+	}
+	mediaMap := this.matterMediaDao.FindByMatterUuids(matterUuids)
+
+	photos := make([]*model.Photo, 0)
+	for _, matter := range matters {
+		photo := &model.Photo{
+			Matter:       matter,
+			TakenTime:    matter.CreateTime,
+			ThumbnailUrl: "/api/alien/preview/" + matter.Uuid + "/" + url.PathEscape(matter.Name) + "?ir=" + url.QueryEscape(ir),
+		}
+		if matterMedia, ok := mediaMap[matter.Uuid]; ok {
+			matter.Media = matterMedia
+			if matterMedia.TakenTime != nil {
+				photo.TakenTime = *matterMedia.TakenTime
+			}
+		}
+		photos = append(photos, photo)
+	}
+
+	return photos
+}
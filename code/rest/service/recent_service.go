@@ -0,0 +1,111 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/util"
+	"net/http"
+	"time"
+)
+
+// @Service
+type RecentService struct {
+	bean.BaseBean
+	recentDao     *dao.RecentDao
+	matterDao     *dao.MatterDao
+	preferenceDao *dao.PreferenceDao
+	matterService *MatterService
+}
+
+func (this *RecentService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.recentDao)
+	if b, ok := b.(*dao.RecentDao); ok {
+		this.recentDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.preferenceDao)
+	if b, ok := b.(*dao.PreferenceDao); ok {
+		this.preferenceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+}
+
+// record that a user used a file. anonymous user and directory are ignored.
+func (this *RecentService) Record(request *http.Request, user *model.User, matter *model.Matter, action string) {
+
+	if user == nil || matter == nil || matter.Dir {
+		return
+	}
+
+	//never break the main process.
+	go core.RunWithRecovery(func() {
+
+		recent := this.recentDao.FindByUserUuidAndMatterUuid(user.Uuid, matter.Uuid)
+		if recent == nil {
+			this.recentDao.Create(&model.Recent{
+				UserUuid:   user.Uuid,
+				MatterUuid: matter.Uuid,
+				SpaceUuid:  matter.SpaceUuid,
+				Action:     action,
+				VisitTime:  time.Now(),
+			})
+		} else {
+			recent.SpaceUuid = matter.SpaceUuid
+			recent.Action = action
+			recent.VisitTime = time.Now()
+			this.recentDao.Save(recent)
+		}
+	})
+}
+
+// the earliest visit time to keep. nil means keep forever.
+func (this *RecentService) keepAfter() *time.Time {
+	preference := this.preferenceDao.Fetch()
+	if preference.RecentKeepDays <= 0 {
+		return nil
+	}
+	thenDate := time.Now().AddDate(0, 0, int(-preference.RecentKeepDays))
+	return &thenDate
+}
+
+// page a user's recent files. each matter is wrapped with its parents to show the location.
+func (this *RecentService) Page(request *http.Request, page int, pageSize int, user *model.User, spaceUuid string, action string, sortArray []builder.OrderPair) *model.Pager {
+
+	count, recents := this.recentDao.PlainPage(page, pageSize, user, spaceUuid, action, this.keepAfter(), sortArray)
+	for _, recent := range recents {
+		matter := this.matterDao.FindByUuid(recent.MatterUuid)
+		if matter != nil {
+			recent.Matter = this.matterService.WrapParentDetail(request, matter)
+		}
+	}
+
+	return model.NewPager(page, pageSize, count, recents)
+}
+
+// delete the records out of the retention window.
+func (this *RecentService) CleanExpiredRecents() {
+
+	thenDate := this.keepAfter()
+	if thenDate == nil {
+		return
+	}
+
+	this.Logger.Info("Delete recent files visited before %s", util.ConvertTimeToDateTimeString(*thenDate))
+
+	this.recentDao.DeleteByVisitTimeBefore(*thenDate)
+}
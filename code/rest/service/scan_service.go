@@ -0,0 +1,570 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/result"
+	"box/code/tool/storage"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// state of scanning a space.
+type scanContext struct {
+	request *http.Request
+	user    *model.User
+	space   *model.Space
+	full    bool
+	report  *model.ScanReport
+	//matters whose physics files are gone. removed at the end unless they are found renamed.
+	missing []*model.Matter
+	//paths of the directories reported by the watcher. nil when scanning the whole space.
+	dirty map[string]bool
+	//matters renamed in this scan. uuid -> true
+	renamed map[string]bool
+	//the dry run being applied. only its changes are made.
+	plan *model.ScanReport
+	//modify times of the synced directories, recorded at the end. path -> info
+	dirInfos map[string]os.FileInfo
+	dirUuids map[string]string
+	//directories with changes not made. their modify times are not recorded, so that the next scan reads them again.
+	incomplete map[string]bool
+}
+
+func (this *scanContext) planned(action string, path string, oldPath string) bool {
+	return this.plan == nil || this.plan.Planned(action, path, oldPath)
+}
+
+// a change of the entry is not made.
+func (this *scanContext) skip(entryPath string) {
+	dirPath := path.Dir(entryPath)
+	if dirPath == "/" || dirPath == "." {
+		dirPath = ""
+	}
+	this.incomplete[dirPath] = true
+}
+
+// sync the physics files in spaces' root directories to matters.
+// @Service
+type ScanService struct {
+	bean.BaseBean
+	matterDao         *dao.MatterDao
+	spaceDao          *dao.SpaceDao
+	userDao           *dao.UserDao
+	scanReportDao     *dao.ScanReportDao
+	matterService     *MatterService
+	preferenceService *PreferenceService
+
+	//scanning the same space twice at a time creates duplicated matters.
+	scanMutex sync.Mutex
+}
+
+func (this *ScanService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.spaceDao)
+	if b, ok := b.(*dao.SpaceDao); ok {
+		this.spaceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.userDao)
+	if b, ok := b.(*dao.UserDao); ok {
+		this.userDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.scanReportDao)
+	if b, ok := b.(*dao.ScanReportDao); ok {
+		this.scanReportDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.preferenceService)
+	if b, ok := b.(*PreferenceService); ok {
+		this.preferenceService = b
+	}
+}
+
+// scan the physics files of a space. the directories whose modify time is unchanged are skipped unless full.
+// dry run only computes the changes, which can be applied later.
+func (this *ScanService) Scan(request *http.Request, user *model.User, space *model.Space, full bool, dryRun bool) *model.ScanReport {
+	return this.scan(request, user, space, full, dryRun, nil)
+}
+
+// make the changes found by a dry run. the changes found since then are left to the next scan.
+func (this *ScanService) Apply(request *http.Request, reportUuid string) *model.ScanReport {
+
+	//a dry run is applied only once.
+	plan := this.scanReportDao.FindByUuid(reportUuid)
+	if plan == nil || !plan.DryRun || !this.scanReportDao.Delete(plan) {
+		panic(result.NotFound("dry run %s not found. it may be applied or replaced by a newer one.", reportUuid))
+	}
+
+	space := this.spaceDao.CheckByUuid(plan.SpaceUuid)
+	user := this.userDao.FindByUuid(space.UserUuid)
+	if user == nil {
+		user = this.userDao.FindAnAdmin()
+	}
+
+	return this.scan(request, user, space, plan.Full, false, plan)
+}
+
+func (this *ScanService) scan(request *http.Request, user *model.User, space *model.Space, full bool, dryRun bool, plan *model.ScanReport) *model.ScanReport {
+
+	if user == nil {
+		panic(result.BadRequest("user cannot be nil."))
+	}
+
+	driver := core.CONTEXT.GetStorage()
+	rootDirPath := model.GetSpaceMatterStoragePath(space.Name)
+	this.Logger.Info("scan %s's root dir %s. full = %v, dryRun = %v", space.Name, rootDirPath, full, dryRun)
+
+	this.scanMutex.Lock()
+	defer this.scanMutex.Unlock()
+
+	if !storage.Exists(driver, rootDirPath) {
+		err := driver.MkdirAll(rootDirPath)
+		this.PanicError(err)
+	}
+	rootFileInfo, err := driver.Stat(rootDirPath)
+	if err != nil {
+		panic(result.BadRequest("cannot get root file info."))
+	}
+
+	ctx := this.newContext(request, user, space, full)
+	ctx.report.DryRun = dryRun
+	ctx.plan = plan
+	this.scanDir(ctx, model.NewRootMatter(space), rootFileInfo)
+
+	return this.finish(ctx)
+}
+
+// sync only the directories whose entries are changed. dirPaths are matter paths, "" for the root.
+func (this *ScanService) Sync(request *http.Request, user *model.User, space *model.Space, dirPaths []string) *model.ScanReport {
+
+	if user == nil {
+		panic(result.BadRequest("user cannot be nil."))
+	}
+
+	this.scanMutex.Lock()
+	defer this.scanMutex.Unlock()
+
+	driver := core.CONTEXT.GetStorage()
+	ctx := this.newContext(request, user, space, false)
+	ctx.dirty = make(map[string]bool)
+	for _, dirPath := range dirPaths {
+		ctx.dirty[dirPath] = true
+	}
+
+	//parents first. a new directory is created by its parent.
+	sort.Strings(dirPaths)
+	for _, dirPath := range dirPaths {
+		//already synced along with its parent.
+		if !ctx.dirty[dirPath] {
+			continue
+		}
+		this.safely(ctx, dirPath, func() {
+			dirMatter := model.NewRootMatter(space)
+			if dirPath != "" {
+				dirMatter = this.matterDao.FindBySpaceUuidAndPath(space.Uuid, dirPath)
+				if dirMatter == nil || !dirMatter.Dir {
+					return
+				}
+			}
+			dirInfo, err := driver.Stat(dirMatter.StoragePath())
+			if err != nil {
+				//removed with its parent.
+				if os.IsNotExist(err) {
+					return
+				}
+				panic(err)
+			}
+			this.scanDir(ctx, dirMatter, dirInfo)
+		})
+	}
+
+	return this.finish(ctx)
+}
+
+func (this *ScanService) newContext(request *http.Request, user *model.User, space *model.Space, full bool) *scanContext {
+	return &scanContext{
+		request:    request,
+		user:       user,
+		space:      space,
+		full:       full,
+		renamed:    make(map[string]bool),
+		dirInfos:   make(map[string]os.FileInfo),
+		dirUuids:   make(map[string]string),
+		incomplete: make(map[string]bool),
+		report: &model.ScanReport{
+			SpaceUuid: space.Uuid,
+			SpaceName: space.Name,
+			Full:      full,
+			StartTime: time.Now(),
+			Entries:   []*model.ScanEntry{},
+		},
+	}
+}
+
+// remove the missing matters and keep the report.
+func (this *ScanService) finish(ctx *scanContext) *model.ScanReport {
+
+	this.removeMissing(ctx)
+
+	//the directories whose changes are all made need not be read again.
+	if !ctx.report.DryRun {
+		for dirPath, dirInfo := range ctx.dirInfos {
+			if !ctx.incomplete[dirPath] {
+				this.matterDao.UpdateFileInfo(ctx.dirUuids[dirPath], dirInfo.ModTime().UnixNano(), int64(storage.Inode(dirInfo)))
+			}
+		}
+	}
+
+	ctx.report.EndTime = time.Now()
+	this.Logger.Info("finish scanning %s. dryRun %v, created %d, updated %d, renamed %d, removed %d, skipped %d directories, %d errors.",
+		ctx.space.Name, ctx.report.DryRun, ctx.report.CreatedCount, ctx.report.UpdatedCount, ctx.report.RenamedCount, ctx.report.RemovedCount, ctx.report.SkippedDirCount, ctx.report.ErrorCount)
+
+	//the watcher syncs a few directories every few seconds. only logged, the report of the last scan is kept.
+	if ctx.dirty != nil {
+		return ctx.report
+	}
+
+	//nothing found. the last report is kept, and there is nothing to apply.
+	if ctx.report.Empty() {
+		if ctx.report.DryRun {
+			this.scanReportDao.DeleteBySpaceUuidAndDryRun(ctx.space.Uuid, true)
+		}
+		return ctx.report
+	}
+
+	return this.scanReportDao.Create(ctx.report)
+}
+
+// the last report and the unapplied dry run of every scanned space. ordered by space name.
+func (this *ScanService) Reports() []*model.ScanReport {
+
+	reports := this.scanReportDao.FindAll()
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].SpaceName != reports[j].SpaceName {
+			return reports[i].SpaceName < reports[j].SpaceName
+		}
+		return !reports[i].DryRun && reports[j].DryRun
+	})
+	return reports
+}
+
+// sync a directory. dirInfo is its physics file info.
+func (this *ScanService) scanDir(ctx *scanContext, dirMatter *model.Matter, dirInfo os.FileInfo) {
+
+	//a cancelled scan stops before removing anything, the unvisited matters are not missing.
+	jobProgressOf(ctx.request).Check()
+
+	driver := core.CONTEXT.GetStorage()
+	var children []*model.Matter
+	//a directory found by a dry run is not created.
+	if dirMatter.Uuid != "" {
+		children = this.matterDao.FindByPuuidAndSpaceUuid(dirMatter.Uuid, ctx.space.Uuid)
+	}
+
+	//adding, removing or renaming an entry changes the modify time of the directory. s3 has no modify time.
+	modTime := dirInfo.ModTime().UnixNano()
+	changed := ctx.full || ctx.dirty[dirMatter.Path] || dirMatter.Uuid == model.MATTER_ROOT || dirInfo.ModTime().IsZero() || dirMatter.FileModTime != modTime
+
+	//the watcher reports every changed directory. no need to go deeper.
+	if !changed && ctx.dirty != nil {
+		ctx.report.SkippedDirCount++
+		return
+	}
+
+	if !changed {
+		//the entries are the same. only go deeper.
+		ctx.report.SkippedDirCount++
+		for _, child := range children {
+			if !child.Dir || child.Deleted {
+				continue
+			}
+			this.safely(ctx, child.Path, func() {
+				childInfo, err := driver.Stat(child.StoragePath())
+				if err != nil {
+					if os.IsNotExist(err) {
+						ctx.missing = append(ctx.missing, child)
+					} else {
+						panic(err)
+					}
+					return
+				}
+				this.scanDir(ctx, child, childInfo)
+			})
+		}
+		return
+	}
+
+	delete(ctx.dirty, dirMatter.Path)
+
+	fileInfos, err := driver.List(dirMatter.StoragePath())
+	if err != nil {
+		//never remove anything if the directory cannot be read. eg. the mount is gone.
+		this.Logger.Error("occur error when List %s %s", dirMatter.StoragePath(), err.Error())
+		ctx.report.ErrorCount++
+		return
+	}
+
+	nameMatterMap := make(map[string]*model.Matter)
+	for _, child := range children {
+		nameMatterMap[child.Name] = child
+	}
+
+	for _, fileInfo := range fileInfos {
+		fileInfo := fileInfo
+		this.safely(ctx, dirMatter.Path+"/"+fileInfo.Name(), func() {
+			matter, ok := nameMatterMap[fileInfo.Name()]
+			if !ok {
+				this.create(ctx, dirMatter, fileInfo)
+				return
+			}
+			//the content of blob lives in the blob directory. deleted matters are left to the recycle bin.
+			if matter.IsBlob() || matter.Deleted {
+				return
+			}
+			if matter.Dir != fileInfo.IsDir() {
+				this.Logger.Warn("%s changed between file and directory. skip it.", matter.Path)
+				return
+			}
+			if matter.Dir {
+				this.scanDir(ctx, matter, fileInfo)
+			} else {
+				this.update(ctx, matter, fileInfo)
+			}
+		})
+		delete(nameMatterMap, fileInfo.Name())
+	}
+
+	for _, matter := range nameMatterMap {
+		if !matter.IsBlob() && !matter.Deleted {
+			ctx.missing = append(ctx.missing, matter)
+		}
+	}
+
+	if dirMatter.Uuid != model.MATTER_ROOT && dirMatter.Uuid != "" {
+		ctx.dirInfos[dirMatter.Path] = dirInfo
+		ctx.dirUuids[dirMatter.Path] = dirMatter.Uuid
+	}
+}
+
+// sync a new physics file. it may be renamed from a missing matter.
+func (this *ScanService) create(ctx *scanContext, dirMatter *model.Matter, fileInfo os.FileInfo) {
+
+	modTime := fileInfo.ModTime().UnixNano()
+	inode := int64(storage.Inode(fileInfo))
+	newPath := dirMatter.Path + "/" + fileInfo.Name()
+
+	if renamed := this.findRenamed(ctx, fileInfo); renamed != nil && ctx.planned(model.SCAN_ACTION_RENAME, newPath, renamed.Path) {
+
+		ctx.renamed[renamed.Uuid] = true
+		oldPath := renamed.Path
+
+		//the entries of a renamed directory are not read in a dry run. they are renamed along with it.
+		if ctx.report.DryRun {
+			ctx.report.Add(model.SCAN_ACTION_RENAME, &model.Matter{Path: newPath, Dir: renamed.Dir, Size: renamed.Size}, oldPath, 0)
+			return
+		}
+
+		oldPuuid := renamed.Puuid
+		renamed.Puuid = dirMatter.Uuid
+		renamed.Name = fileInfo.Name()
+		this.matterService.adjustPath(renamed, dirMatter)
+		this.Logger.Info("rename matter: %s -> %s", oldPath, renamed.Path)
+		ctx.report.Add(model.SCAN_ACTION_RENAME, renamed, oldPath, 0)
+
+		if oldPuuid != dirMatter.Uuid {
+			this.matterService.ComputeRouteSize(oldPuuid, ctx.user, ctx.space)
+			this.matterService.ComputeRouteSize(dirMatter.Uuid, ctx.user, ctx.space)
+		}
+
+		if renamed.Dir {
+			this.scanDir(ctx, renamed, fileInfo)
+		}
+		return
+	}
+
+	if !ctx.planned(model.SCAN_ACTION_CREATE, newPath, "") {
+		ctx.skip(newPath)
+		return
+	}
+
+	if ctx.report.DryRun {
+		matter := &model.Matter{SpaceUuid: ctx.space.Uuid, SpaceName: ctx.space.Name, Path: newPath, Name: fileInfo.Name(), Dir: fileInfo.IsDir()}
+		if !matter.Dir {
+			matter.Size = fileInfo.Size()
+		}
+		ctx.report.Add(model.SCAN_ACTION_CREATE, matter, "", 0)
+		if matter.Dir {
+			this.scanDir(ctx, matter, fileInfo)
+		}
+		return
+	}
+
+	if fileInfo.IsDir() {
+		matter := this.matterService.createDirectory(ctx.request, dirMatter, fileInfo.Name(), ctx.user, ctx.space)
+		ctx.report.Add(model.SCAN_ACTION_CREATE, matter, "", 0)
+		this.scanDir(ctx, matter, fileInfo)
+	} else {
+		this.Logger.Info("create matter: %s size:%d", fileInfo.Name(), fileInfo.Size())
+		matter := this.matterService.createNonDirMatter(dirMatter, fileInfo.Name(), fileInfo.Size(), true, ctx.user, ctx.space, nil)
+		this.matterDao.UpdateFileInfo(matter.Uuid, modTime, inode)
+		ctx.report.Add(model.SCAN_ACTION_CREATE, matter, "", 0)
+	}
+}
+
+// a matter with the same inode whose physics file is gone. files should keep the size and modify time.
+func (this *ScanService) findRenamed(ctx *scanContext, fileInfo os.FileInfo) *model.Matter {
+
+	inode := int64(storage.Inode(fileInfo))
+	if inode == 0 {
+		return nil
+	}
+	matter := this.matterDao.FindBySpaceUuidAndFileInode(ctx.space.Uuid, inode)
+	if matter == nil || ctx.renamed[matter.Uuid] || matter.IsBlob() || matter.Dir != fileInfo.IsDir() {
+		return nil
+	}
+	if !matter.Dir && (matter.Size != fileInfo.Size() || matter.FileModTime != fileInfo.ModTime().UnixNano()) {
+		return nil
+	}
+	if storage.Exists(core.CONTEXT.GetStorage(), matter.StoragePath()) {
+		return nil
+	}
+	return matter
+}
+
+// sync an existing file. matters scanned before the modify time is recorded only compare the size.
+func (this *ScanService) update(ctx *scanContext, matter *model.Matter, fileInfo os.FileInfo) {
+
+	modTime := fileInfo.ModTime().UnixNano()
+	inode := int64(storage.Inode(fileInfo))
+
+	if matter.Size != fileInfo.Size() || (matter.FileModTime != 0 && matter.FileModTime != modTime) {
+		if !ctx.planned(model.SCAN_ACTION_UPDATE, matter.Path, "") {
+			ctx.skip(matter.Path)
+			return
+		}
+		oldSize := matter.Size
+		if ctx.report.DryRun {
+			ctx.report.Add(model.SCAN_ACTION_UPDATE, &model.Matter{Path: matter.Path, Size: fileInfo.Size()}, "", oldSize)
+			return
+		}
+		this.Logger.Info("update matter: %s size:%d -> %d", matter.Path, matter.Size, fileInfo.Size())
+		matter.FileModTime = modTime
+		matter.FileInode = inode
+		matter = this.matterService.updateNonDirMatter(matter, fileInfo.Size(), ctx.user, ctx.space)
+		ctx.report.Add(model.SCAN_ACTION_UPDATE, matter, "", oldSize)
+	} else if (matter.FileModTime != modTime || matter.FileInode != inode) && !ctx.report.DryRun {
+		this.matterDao.UpdateFileInfo(matter.Uuid, modTime, inode)
+	}
+}
+
+// remove the matters whose physics files are still gone after the whole space is scanned.
+// nothing is removed if too many matters would be removed.
+func (this *ScanService) removeMissing(ctx *scanContext) {
+
+	driver := core.CONTEXT.GetStorage()
+
+	//the matters inside a removed directory are removed along with it.
+	sort.Slice(ctx.missing, func(i, j int) bool {
+		return ctx.missing[i].Path < ctx.missing[j].Path
+	})
+	removingDirPaths := make(map[string]bool)
+	var removings []*model.Matter
+	for _, missing := range ctx.missing {
+		this.safely(ctx, missing.Path, func() {
+			if ctx.renamed[missing.Uuid] {
+				return
+			}
+			if !ctx.planned(model.SCAN_ACTION_REMOVE, missing.Path, "") {
+				ctx.skip(missing.Path)
+				return
+			}
+			for dirPath := path.Dir(missing.Path); dirPath != "/"; dirPath = path.Dir(dirPath) {
+				if removingDirPaths[dirPath] {
+					return
+				}
+			}
+			//renamed, or removed with its parent.
+			matter := this.matterDao.FindByUuid(missing.Uuid)
+			if matter == nil || matter.Deleted || storage.Exists(driver, matter.StoragePath()) {
+				return
+			}
+			if matter.Dir {
+				removingDirPaths[matter.Path] = true
+			}
+			removings = append(removings, matter)
+		})
+	}
+	if len(removings) == 0 {
+		return
+	}
+
+	var count int64 = 0
+	for _, matter := range removings {
+		if matter.Dir {
+			count += this.matterDao.CountPhysicsBySpaceUuidAndPath(ctx.space.Uuid, matter.Path)
+		} else {
+			count++
+		}
+	}
+	total := this.matterDao.CountPhysicsBySpaceUuidAndPath(ctx.space.Uuid, "")
+	if total > 0 {
+		ctx.report.RemovePercent = count * 100 / total
+	}
+
+	//the changes of a dry run are reviewed by the admin.
+	maxRemovePercent := this.preferenceService.Fetch().FetchScanConfig().MaxRemovePercent
+	if !ctx.report.DryRun && ctx.plan == nil && maxRemovePercent > 0 && count*100 > total*maxRemovePercent {
+		ctx.report.Aborted = true
+		for _, matter := range removings {
+			ctx.skip(matter.Path)
+		}
+		this.Logger.Error("%s: %d of %d matters on disk are missing, more than %d%%. remove nothing.", ctx.space.Name, count, total, maxRemovePercent)
+		return
+	}
+
+	for _, matter := range removings {
+		if ctx.report.DryRun {
+			ctx.report.Add(model.SCAN_ACTION_REMOVE, matter, "", 0)
+			continue
+		}
+		this.safely(ctx, matter.Path, func() {
+			this.Logger.Info("physics file not exist. delete from tank. %s", matter.Path)
+			this.matterService.AtomicDelete(ctx.request, matter, ctx.user, ctx.space)
+			ctx.report.Add(model.SCAN_ACTION_REMOVE, matter, "", 0)
+		})
+	}
+}
+
+// a bad entry should not stop the whole scan.
+func (this *ScanService) safely(ctx *scanContext, path string, f func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			if jobProgressOf(ctx.request).Cancelled() {
+				panic(err)
+			}
+			ctx.report.ErrorCount++
+			ctx.skip(path)
+			this.Logger.Error("occur error when scanning %s. %v", path, err)
+		}
+	}()
+	f()
+}
@@ -116,6 +116,34 @@ func (this *ShareService) ValidateMatter(request *http.Request, shareUuid string
 
 }
 
+// some matters are moved from a space to another. the shares whose matters are all moved go along, so that they can still be browsed.
+func (this *ShareService) Reparent(srcSpaceUuid string, destSpaceUuid string) {
+
+	for _, share := range this.shareDao.FindBySpaceUuid(srcSpaceUuid) {
+
+		bridges := this.bridgeDao.FindByShareUuid(share.Uuid)
+		movedCount := 0
+		for _, bridge := range bridges {
+			matter := this.matterDao.FindByUuid(bridge.MatterUuid)
+			if matter != nil && matter.SpaceUuid == destSpaceUuid {
+				movedCount++
+			}
+		}
+
+		if movedCount == 0 {
+			continue
+		}
+		if movedCount < len(bridges) {
+			this.Logger.Warn("share %s has matters in two spaces now. it stays in space %s.", share.Uuid, srcSpaceUuid)
+			continue
+		}
+
+		this.Logger.Info("share %s follows its matters to space %s", share.Uuid, destSpaceUuid)
+		share.SpaceUuid = destSpaceUuid
+		this.shareDao.Save(share)
+	}
+}
+
 // delete user's shares and corresponding bridges.
 func (this *ShareService) DeleteSharesByUser(request *http.Request, currentUser *model.User) {
 
@@ -94,12 +94,14 @@ func (this *SpaceService) CreateSpace(
 	}
 
 	space := &model.Space{
-		Name:           name,
-		UserUuid:       userUuid,
-		SizeLimit:      sizeLimit,
-		TotalSizeLimit: totalSizeLimit,
-		TotalSize:      0,
-		Type:           spaceType,
+		Name:            name,
+		UserUuid:        userUuid,
+		SizeLimit:       sizeLimit,
+		TotalSizeLimit:  totalSizeLimit,
+		TotalSize:       0,
+		VersionLimit:    model.SPACE_DEFAULT_VERSION_LIMIT,
+		VersionKeepDays: model.SPACE_DEFAULT_VERSION_KEEP_DAYS,
+		Type:            spaceType,
 	}
 
 	space = this.spaceDao.Create(space)
@@ -154,7 +156,7 @@ func (this *SpaceService) CheckReadableByUuid(request *http.Request, user *model
 }
 
 // edit space's info
-func (this *SpaceService) Edit(request *http.Request, user *model.User, spaceUuid string, sizeLimit int64, totalSizeLimit int64) *model.Space {
+func (this *SpaceService) Edit(request *http.Request, user *model.User, spaceUuid string, sizeLimit int64, totalSizeLimit int64, versionLimit int64, versionKeepDays int64) *model.Space {
 	space := this.CheckAdminAbleByUuid(request, user, spaceUuid)
 
 	if sizeLimit < 0 && sizeLimit != -1 {
@@ -165,8 +167,18 @@ func (this *SpaceService) Edit(request *http.Request, user *model.User, spaceUui
 		panic("totalSizeLimit cannot be negative expect -1.")
 	}
 
+	if versionLimit < 0 && versionLimit != -1 {
+		panic("versionLimit cannot be negative expect -1.")
+	}
+
+	if versionKeepDays < 0 && versionKeepDays != -1 {
+		panic("versionKeepDays cannot be negative expect -1.")
+	}
+
 	space.SizeLimit = sizeLimit
 	space.TotalSizeLimit = totalSizeLimit
+	space.VersionLimit = versionLimit
+	space.VersionKeepDays = versionKeepDays
 	space = this.spaceDao.Save(space)
 
 	return space
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/builder"
+	"box/code/tool/result"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// max length of a tag's name.
+const TAG_NAME_MAX_LENGTH = 45
+
+// @Service
+type TagService struct {
+	bean.BaseBean
+	tagDao       *dao.TagDao
+	matterTagDao *dao.MatterTagDao
+	matterDao    *dao.MatterDao
+}
+
+func (this *TagService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.tagDao)
+	if b, ok := b.(*dao.TagDao); ok {
+		this.tagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterTagDao)
+	if b, ok := b.(*dao.MatterTagDao); ok {
+		this.matterTagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+}
+
+// trim and check the tag's name.
+func (this *TagService) CheckTagName(request *http.Request, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		panic(result.BadRequest("tag name cannot be empty."))
+	}
+	if utf8.RuneCountInString(name) > TAG_NAME_MAX_LENGTH {
+		panic(result.BadRequest("tag name cannot be longer than %d.", TAG_NAME_MAX_LENGTH))
+	}
+	if strings.Contains(name, ",") {
+		panic(result.BadRequest("tag name cannot contain ','."))
+	}
+	return name
+}
+
+// list the tags of a space with the number of matters.
+func (this *TagService) List(request *http.Request, space *model.Space) []*model.Tag {
+
+	tags := this.tagDao.ListBySpaceUuid(space.Uuid)
+	countMap := this.matterTagDao.CountBySpaceUuid(space.Uuid)
+	for _, tag := range tags {
+		tag.MatterCount = countMap[tag.Uuid]
+	}
+
+	return tags
+}
+
+func (this *TagService) Create(request *http.Request, user *model.User, space *model.Space, name string) *model.Tag {
+
+	name = this.CheckTagName(request, name)
+	if this.tagDao.FindBySpaceUuidAndName(space.Uuid, name) != nil {
+		panic(result.BadRequest("tag %s exists.", name))
+	}
+
+	tag := &model.Tag{
+		SpaceUuid: space.Uuid,
+		UserUuid:  user.Uuid,
+		Name:      name,
+	}
+	return this.tagDao.Create(tag)
+}
+
+func (this *TagService) Rename(request *http.Request, tag *model.Tag, name string) *model.Tag {
+
+	name = this.CheckTagName(request, name)
+	if name == tag.Name {
+		return tag
+	}
+	if this.tagDao.FindBySpaceUuidAndName(tag.SpaceUuid, name) != nil {
+		panic(result.BadRequest("tag %s exists.", name))
+	}
+
+	tag.Name = name
+	return this.tagDao.Save(tag)
+}
+
+func (this *TagService) Delete(request *http.Request, tag *model.Tag) {
+	this.tagDao.Delete(tag)
+}
+
+// add tags to matters. tags not in the vocabulary will be created.
+func (this *TagService) Add(request *http.Request, user *model.User, space *model.Space, matters []*model.Matter, names []string) []*model.Tag {
+
+	var tags []*model.Tag
+	for _, name := range names {
+		name = this.CheckTagName(request, name)
+		tag := this.tagDao.FindBySpaceUuidAndName(space.Uuid, name)
+		if tag == nil {
+			tag = this.tagDao.Create(&model.Tag{
+				SpaceUuid: space.Uuid,
+				UserUuid:  user.Uuid,
+				Name:      name,
+			})
+		}
+		tags = append(tags, tag)
+	}
+
+	for _, matter := range matters {
+		for _, tag := range tags {
+			if this.matterTagDao.FindByTagUuidAndMatterUuid(tag.Uuid, matter.Uuid) == nil {
+				this.matterTagDao.Create(&model.MatterTag{
+					SpaceUuid:  space.Uuid,
+					TagUuid:    tag.Uuid,
+					MatterUuid: matter.Uuid,
+				})
+			}
+		}
+	}
+
+	return tags
+}
+
+// take tags off from matters. the tags stay in the vocabulary.
+func (this *TagService) Remove(request *http.Request, space *model.Space, matters []*model.Matter, tags []*model.Tag) {
+	for _, matter := range matters {
+		for _, tag := range tags {
+			this.matterTagDao.DeleteByTagUuidAndMatterUuid(tag.Uuid, matter.Uuid)
+		}
+	}
+}
+
+// page the matters with tags.
+func (this *TagService) PageMatters(request *http.Request, page int, pageSize int, space *model.Space, tags []*model.Tag, mode string, deleted string, sortArray []builder.OrderPair) *model.Pager {
+
+	if mode != model.TAG_MODE_AND && mode != model.TAG_MODE_OR {
+		panic(result.BadRequest("mode should be %s or %s.", model.TAG_MODE_AND, model.TAG_MODE_OR))
+	}
+
+	var tagUuids []string
+	for _, tag := range tags {
+		tagUuids = append(tagUuids, tag.Uuid)
+	}
+
+	return this.matterDao.PageByTagUuids(page, pageSize, space.Uuid, tagUuids, mode, deleted, sortArray)
+}
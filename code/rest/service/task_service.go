@@ -5,25 +5,34 @@ import (
 	"box/code/rest/bean"
 	"box/code/rest/dao"
 	"box/code/rest/model"
+	"box/code/tool/result"
 	"box/code/tool/util"
+	"fmt"
 	"github.com/robfig/cron/v3"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 // system tasks service
 // @Service
 type TaskService struct {
 	bean.BaseBean
-	footprintService  *FootprintService
-	dashboardService  *DashboardService
-	preferenceService *PreferenceService
-	matterService     *MatterService
-	userDao           *dao.UserDao
-	spaceDao          *dao.SpaceDao
-
-	//whether scan task is running
-	scanTaskRunning bool
-	scanTaskCron    *cron.Cron
+	footprintService     *FootprintService
+	dashboardService     *DashboardService
+	preferenceService    *PreferenceService
+	matterService        *MatterService
+	scanService          *ScanService
+	tusService           *TusService
+	matterVersionService *MatterVersionService
+	recentService        *RecentService
+	jobService           *JobService
+	userDao              *dao.UserDao
+	spaceDao             *dao.SpaceDao
+
+	//one scan task at a time. the others wait.
+	scanTaskMutex sync.Mutex
+	scanTaskCron  *cron.Cron
 }
 
 func (this *TaskService) Init() {
@@ -48,6 +57,26 @@ func (this *TaskService) Init() {
 	if b, ok := b.(*MatterService); ok {
 		this.matterService = b
 	}
+	b = core.CONTEXT.GetBean(this.scanService)
+	if b, ok := b.(*ScanService); ok {
+		this.scanService = b
+	}
+	b = core.CONTEXT.GetBean(this.tusService)
+	if b, ok := b.(*TusService); ok {
+		this.tusService = b
+	}
+	b = core.CONTEXT.GetBean(this.matterVersionService)
+	if b, ok := b.(*MatterVersionService); ok {
+		this.matterVersionService = b
+	}
+	b = core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*RecentService); ok {
+		this.recentService = b
+	}
+	b = core.CONTEXT.GetBean(this.jobService)
+	if b, ok := b.(*JobService); ok {
+		this.jobService = b
+	}
 	b = core.CONTEXT.GetBean(this.userDao)
 	if b, ok := b.(*dao.UserDao); ok {
 		this.userDao = b
@@ -56,8 +85,6 @@ func (this *TaskService) Init() {
 	if b, ok := b.(*dao.SpaceDao); ok {
 		this.spaceDao = b
 	}
-
-	this.scanTaskRunning = false
 }
 
 // init the clean footprint task.
@@ -97,80 +124,131 @@ func (this *TaskService) InitCleanDeletedMattersTask() {
 	this.Logger.Info("[cron job] Everyday 01:00 Clean deleted matters.")
 }
 
-// scan task.
-func (this *TaskService) DoScanTask() {
+// init the clean expired uploads task.
+func (this *TaskService) InitCleanExpiredUploadsTask() {
 
-	if this.scanTaskRunning {
-		this.Logger.Info("scan task is processing. Give up this invoke.")
-		return
-	} else {
-		this.scanTaskRunning = true
-	}
+	expression := "30 1 * * *"
+	cronJob := cron.New()
+	_, err := cronJob.AddFunc(expression, this.tusService.CleanExpiredUploads)
+	core.PanicError(err)
+	cronJob.Start()
+
+	this.Logger.Info("[cron job] Everyday 01:30 Clean expired uploads.")
+}
+
+// init the clean expired versions task.
+func (this *TaskService) InitCleanExpiredVersionsTask() {
+
+	expression := "40 1 * * *"
+	cronJob := cron.New()
+	_, err := cronJob.AddFunc(expression, this.matterVersionService.CleanExpiredVersions)
+	core.PanicError(err)
+	cronJob.Start()
+
+	this.Logger.Info("[cron job] Everyday 01:40 Clean expired versions.")
+}
+
+// init the clean expired recent files task.
+func (this *TaskService) InitCleanExpiredRecentsTask() {
+
+	expression := "50 1 * * *"
+	cronJob := cron.New()
+	_, err := cronJob.AddFunc(expression, this.recentService.CleanExpiredRecents)
+	core.PanicError(err)
+	cronJob.Start()
+
+	this.Logger.Info("[cron job] Everyday 01:50 Clean expired recent files.")
+}
+
+// scan task. full scan reads every directory, otherwise only the changed directories. dry run changes nothing.
+// return the reports of the scanned spaces. it runs in a job, see JobService.
+func (this *TaskService) DoScanTask(request *http.Request, full bool, dryRun bool) (reports []*model.ScanReport) {
+
+	this.scanTaskMutex.Lock()
+	defer this.scanTaskMutex.Unlock()
 
 	defer func() {
 		if err := recover(); err != nil {
-			this.Logger.Info("occur error when do scan task.")
+			this.Logger.Error("occur error when do scan task. %v", err)
+			//let the job fail.
+			panic(err)
 		}
 		this.Logger.Info("finish the scan task.")
-		this.scanTaskRunning = false
 	}()
 
 	this.Logger.Info("[cron job] do the scan task.")
 	preference := this.preferenceService.Fetch()
 	scanConfig := preference.FetchScanConfig()
 
-	if !scanConfig.Enable {
+	//dry run is allowed before the task is enabled.
+	if !scanConfig.Enable && !dryRun {
 		this.Logger.Info("scan task not enabled.")
 		return
 	}
 
-	//mock a request.
-	request := &http.Request{}
+	progress := jobProgressOf(request)
+
+	//one broken space does not stop the others, but fails the job in the end.
+	failures := make([]string, 0)
+	scanSpace := func(space *model.Space) {
+		defer func() {
+			if err := recover(); err != nil {
+				if progress.Cancelled() {
+					panic(err)
+				}
+				this.Logger.Error("occur error when scan space %s. %v", space.Name, err)
+				failures = append(failures, fmt.Sprintf("%s: %v", space.Name, err))
+			}
+		}()
+
+		//find user by space
+		user := this.userDao.FindByUuid(space.UserUuid)
+		if user == nil {
+			user = this.userDao.FindAnAdmin()
+		}
+		reports = append(reports, this.scanService.Scan(request, user, space, full, dryRun))
+	}
 
 	if scanConfig.Scope == model.SCAN_SCOPE_ALL {
+		progress.SetTotal(this.spaceDao.CountAll())
+
 		//scan all user's root folder.
 		this.spaceDao.PageHandle(func(space *model.Space) {
 
-			core.RunWithRecovery(func() {
+			progress.Check()
+			defer progress.Add(1, space.Name)
 
-				this.Logger.Info("scan spaceName = %s", space.Name)
-
-				//find user by space
-				user := this.userDao.FindByUuid(space.UserUuid)
-				if user == nil {
-					user = this.userDao.FindAnAdmin()
-				}
-				this.matterService.DeleteByPhysics(request, user, space)
-				this.matterService.ScanPhysics(request, user, space)
-
-			})
+			this.Logger.Info("scan spaceName = %s", space.Name)
+			scanSpace(space)
 
 		})
 
 	} else if scanConfig.Scope == model.SCAN_SCOPE_CUSTOM {
 
+		progress.SetTotal(int64(len(scanConfig.SpaceNames)))
+
 		for _, spaceName := range scanConfig.SpaceNames {
-			space := this.spaceDao.FindByName(spaceName)
-			if space == nil {
-				this.Logger.Error("name = %s not exist.", spaceName)
-			} else {
-				this.Logger.Info("scan custom user folder. spaceName = %s", spaceName)
-
-				core.RunWithRecovery(func() {
-					//find user by space
-					user := this.userDao.FindByUuid(space.UserUuid)
-					if user == nil {
-						user = this.userDao.FindAnAdmin()
-					}
-					this.matterService.DeleteByPhysics(request, user, space)
-					this.matterService.ScanPhysics(request, user, space)
-
-				})
+			progress.Check()
 
-			}
+			func() {
+				defer progress.Add(1, spaceName)
+
+				space := this.spaceDao.FindByName(spaceName)
+				if space == nil {
+					this.Logger.Error("name = %s not exist.", spaceName)
+				} else {
+					this.Logger.Info("scan custom user folder. spaceName = %s", spaceName)
+					scanSpace(space)
+				}
+			}()
 		}
 	}
 
+	if len(failures) > 0 {
+		panic(result.Server("scan failed in %d spaces. %s", len(failures), strings.Join(failures, "; ")))
+	}
+
+	return
 }
 
 // init the scan task.
@@ -195,7 +273,13 @@ func (this *TaskService) InitScanTask() {
 	}
 
 	this.scanTaskCron = cron.New()
-	_, err := this.scanTaskCron.AddFunc(scanConfig.Cron, this.DoScanTask)
+	_, err := this.scanTaskCron.AddFunc(scanConfig.Cron, func() {
+		if this.jobService.Unfinished(model.JOB_TYPE_SCAN) {
+			this.Logger.Info("scan task is processing. Give up this invoke.")
+			return
+		}
+		this.jobService.Submit(this.userDao.FindAnAdmin(), "", model.JOB_TYPE_SCAN, &model.JobParams{})
+	})
 	core.PanicError(err)
 	this.scanTaskCron.Start()
 
@@ -213,6 +297,15 @@ func (this *TaskService) Bootstrap() {
 	//load the clean deleted matters task.
 	this.InitCleanDeletedMattersTask()
 
+	//load the clean expired uploads task.
+	this.InitCleanExpiredUploadsTask()
+
+	//load the clean expired versions task.
+	this.InitCleanExpiredVersionsTask()
+
+	//load the clean expired recent files task.
+	this.InitCleanExpiredRecentsTask()
+
 	//load the scan task.
 	this.InitScanTask()
 
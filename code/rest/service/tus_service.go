@@ -0,0 +1,364 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/i18n"
+	"box/code/tool/result"
+	"box/code/tool/util"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/**
+ * resumable upload with tus protocol. https://tus.io/protocols/resumable-upload
+ * each upload is an UploadToken, the partial data stays in the space's cache dir until finished.
+ */
+// @Service
+type TusService struct {
+	bean.BaseBean
+	uploadTokenDao *dao.UploadTokenDao
+	matterDao      *dao.MatterDao
+	spaceDao       *dao.SpaceDao
+	userDao        *dao.UserDao
+	matterService  *MatterService
+	spaceService   *SpaceService
+	recentService  *RecentService
+
+	//uploads which are receiving data.
+	lock      sync.Mutex
+	uploading map[string]bool
+}
+
+func (this *TusService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.uploadTokenDao)
+	if b, ok := b.(*dao.UploadTokenDao); ok {
+		this.uploadTokenDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterDao)
+	if b, ok := b.(*dao.MatterDao); ok {
+		this.matterDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.spaceDao)
+	if b, ok := b.(*dao.SpaceDao); ok {
+		this.spaceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.userDao)
+	if b, ok := b.(*dao.UserDao); ok {
+		this.userDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.matterService)
+	if b, ok := b.(*MatterService); ok {
+		this.matterService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.spaceService)
+	if b, ok := b.(*SpaceService); ok {
+		this.spaceService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentService)
+	if b, ok := b.(*RecentService); ok {
+		this.recentService = b
+	}
+
+	this.uploading = make(map[string]bool)
+}
+
+// create an upload for user. metadata: filename, puuid, spaceUuid, privacy
+func (this *TusService) Create(request *http.Request, user *model.User, uploadLength int64, metadata map[string]string) *model.UploadToken {
+
+	filename := model.CheckMatterName(request, metadata["filename"])
+
+	spaceUuid := metadata["spaceUuid"]
+	if spaceUuid == "" {
+		spaceUuid = user.SpaceUuid
+	}
+	space := this.spaceService.CheckWritableByUuid(request, user, spaceUuid)
+
+	puuid := metadata["puuid"]
+	if puuid == "" {
+		puuid = model.MATTER_ROOT
+	}
+	dirMatter := this.matterDao.CheckWithRootByUuid(puuid, space)
+	if !dirMatter.Dir {
+		panic(result.BadRequest("puuid is not a dir."))
+	}
+
+	privacy := true
+	if value, ok := metadata["privacy"]; ok {
+		privacy = value == model.TRUE
+	}
+
+	uploadToken := &model.UploadToken{
+		UserUuid:   user.Uuid,
+		SpaceUuid:  space.Uuid,
+		FolderUuid: dirMatter.Uuid,
+		MatterUuid: "",
+		ExpireTime: time.Now().Add(model.UPLOAD_RESUMABLE_EXPIRE),
+		Filename:   filename,
+		Privacy:    privacy,
+		Size:       uploadLength,
+		Offset:     0,
+		Ip:         util.GetIpAddress(request),
+	}
+
+	return this.start(request, uploadToken, space, dirMatter)
+}
+
+// create an upload for guest with an upload token fetched by user.
+func (this *TusService) CreateWithToken(request *http.Request, uploadTokenUuid string, uploadLength int64, metadata map[string]string) *model.UploadToken {
+
+	uploadToken := this.uploadTokenDao.CheckByUuid(uploadTokenUuid)
+	if uploadToken.ExpireTime.Before(time.Now()) {
+		panic(result.BadRequest("uploadToken has expired"))
+	}
+	if uploadToken.MatterUuid != "" {
+		panic(result.BadRequest("uploadToken has been used"))
+	}
+	if filename, ok := metadata["filename"]; ok && filename != uploadToken.Filename {
+		panic(result.BadRequest("filename doesn't the one in uploadToken"))
+	}
+	if uploadLength != uploadToken.Size {
+		panic(result.BadRequest("file size doesn't the one in uploadToken"))
+	}
+
+	//upload tokens created before resumable upload have no space.
+	if uploadToken.SpaceUuid == "" {
+		user := this.userDao.CheckByUuid(uploadToken.UserUuid)
+		uploadToken.SpaceUuid = user.SpaceUuid
+	}
+	space := this.spaceDao.CheckByUuid(uploadToken.SpaceUuid)
+	dirMatter := this.matterDao.CheckWithRootByUuid(uploadToken.FolderUuid, space)
+
+	//create again means start over.
+	uploadToken.Offset = 0
+
+	return this.start(request, uploadToken, space, dirMatter)
+}
+
+// check the limits up front and prepare the partial file.
+func (this *TusService) start(request *http.Request, uploadToken *model.UploadToken, space *model.Space, dirMatter *model.Matter) *model.UploadToken {
+
+	this.matterService.CheckSizeLimit(request, space, uploadToken.Size)
+
+	dbMatter := this.matterDao.FindBySpaceUuidAndPuuidAndDirAndName(space.Uuid, dirMatter.Uuid, false, uploadToken.Filename)
+	if dbMatter != nil {
+		if dbMatter.Deleted {
+			panic(result.BadRequestI18n(request, i18n.MatterRecycleBinExist, uploadToken.Filename))
+		} else {
+			panic(result.BadRequestI18n(request, i18n.MatterExist, uploadToken.Filename))
+		}
+	}
+
+	if uploadToken.Uuid == "" {
+		uploadToken = this.uploadTokenDao.Create(uploadToken)
+	} else {
+		uploadToken = this.uploadTokenDao.Save(uploadToken)
+	}
+
+	partialPath := model.GetUploadPartialPath(space.Name, uploadToken.Uuid)
+	util.MakeDirAll(filepath.Dir(partialPath))
+	file, err := os.Create(partialPath)
+	this.PanicError(err)
+	err = file.Close()
+	this.PanicError(err)
+
+	//empty file is finished once created.
+	if uploadToken.Size == 0 {
+		uploadToken = this.finish(request, uploadToken, space)
+	}
+
+	return uploadToken
+}
+
+// find an upload which can be resumed.
+func (this *TusService) CheckUpload(uuid string) *model.UploadToken {
+
+	uploadToken := this.uploadTokenDao.FindByUuid(uuid)
+	if uploadToken == nil || uploadToken.SpaceUuid == "" {
+		panic(result.NotFound("upload %s not exist", uuid))
+	}
+	if uploadToken.MatterUuid == "" && uploadToken.ExpireTime.Before(time.Now()) {
+		panic(result.StatusCodeWebResult(http.StatusGone, fmt.Sprintf("upload %s has expired", uuid)))
+	}
+
+	return uploadToken
+}
+
+// append data at offset. when all the data received, the upload turns into a matter.
+func (this *TusService) Patch(request *http.Request, uploadToken *model.UploadToken, offset int64, reader io.Reader) *model.UploadToken {
+
+	if !this.tryLock(uploadToken.Uuid) {
+		panic(result.StatusCodeWebResult(http.StatusLocked, fmt.Sprintf("upload %s is receiving data", uploadToken.Uuid)))
+	}
+	defer this.unlock(uploadToken.Uuid)
+
+	//reload. offset may be changed by the last request.
+	uploadToken = this.uploadTokenDao.CheckByUuid(uploadToken.Uuid)
+	if uploadToken.MatterUuid != "" {
+		panic(result.BadRequest("upload %s has finished", uploadToken.Uuid))
+	}
+	if offset != uploadToken.Offset {
+		panic(result.StatusCodeWebResult(http.StatusConflict, fmt.Sprintf("offset %d doesn't match the upload's offset %d", offset, uploadToken.Offset)))
+	}
+
+	//more than Upload-Length. refuse before writing anything when the length is known.
+	if request.ContentLength > uploadToken.Size-offset {
+		panic(result.StatusCodeWebResult(http.StatusRequestEntityTooLarge, "data exceed the upload length"))
+	}
+
+	space := this.spaceDao.CheckByUuid(uploadToken.SpaceUuid)
+	partialPath := model.GetUploadPartialPath(space.Name, uploadToken.Uuid)
+
+	file, err := os.OpenFile(partialPath, os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			panic(result.NotFound("upload %s not exist", uploadToken.Uuid))
+		}
+		panic(err)
+	}
+
+	//drop the data not recorded. eg. the server stopped during last request.
+	err = file.Truncate(offset)
+	if err == nil {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		panic(err)
+	}
+
+	written, copyErr := io.Copy(file, io.LimitReader(reader, uploadToken.Size-offset))
+
+	//more than Upload-Length. nothing of this request is kept.
+	if copyErr == nil && offset+written == uploadToken.Size {
+		n, _ := reader.Read(make([]byte, 1))
+		if n > 0 {
+			_ = file.Truncate(offset)
+			_ = file.Close()
+			panic(result.StatusCodeWebResult(http.StatusRequestEntityTooLarge, "data exceed the upload length"))
+		}
+	}
+
+	err = file.Close()
+	this.PanicError(err)
+
+	//keep what we have received even if the connection broke. client will resume from here.
+	uploadToken.Offset = offset + written
+	uploadToken = this.uploadTokenDao.Save(uploadToken)
+	this.PanicError(copyErr)
+
+	if uploadToken.Offset == uploadToken.Size {
+		uploadToken = this.finish(request, uploadToken, space)
+	}
+
+	return uploadToken
+}
+
+// turn the partial file into a matter.
+func (this *TusService) finish(request *http.Request, uploadToken *model.UploadToken, space *model.Space) *model.UploadToken {
+
+	user := this.userDao.CheckByUuid(uploadToken.UserUuid)
+	dirMatter := this.matterDao.CheckWithRootByUuid(uploadToken.FolderUuid, space)
+	partialPath := model.GetUploadPartialPath(space.Name, uploadToken.Uuid)
+
+	file, err := os.Open(partialPath)
+	this.PanicError(err)
+	defer func() {
+		e := file.Close()
+		this.PanicError(e)
+	}()
+
+	//size limit will be checked again, space may changed during uploading.
+	matter := this.matterService.Upload(request, file, nil, user, space, dirMatter, uploadToken.Filename, uploadToken.Privacy)
+	this.recentService.Record(request, user, matter, model.RECENT_ACTION_UPLOAD)
+
+	err = os.Remove(partialPath)
+	if err != nil {
+		this.Logger.Error("occur error when deleting partial upload. %v", err)
+	}
+
+	uploadToken.MatterUuid = matter.Uuid
+	uploadToken.ExpireTime = time.Now()
+	return this.uploadTokenDao.Save(uploadToken)
+}
+
+// terminate an upload, the received data will be deleted.
+func (this *TusService) Terminate(request *http.Request, uploadToken *model.UploadToken) {
+
+	if !this.tryLock(uploadToken.Uuid) {
+		panic(result.StatusCodeWebResult(http.StatusLocked, fmt.Sprintf("upload %s is receiving data", uploadToken.Uuid)))
+	}
+	defer this.unlock(uploadToken.Uuid)
+
+	space := this.spaceDao.FindByUuid(uploadToken.SpaceUuid)
+	if space != nil {
+		err := os.Remove(model.GetUploadPartialPath(space.Name, uploadToken.Uuid))
+		if err != nil && !os.IsNotExist(err) {
+			panic(err)
+		}
+	}
+
+	this.uploadTokenDao.Delete(uploadToken)
+}
+
+// clean the partial data of expired uploads.
+func (this *TusService) CleanExpiredUploads() {
+
+	this.spaceDao.PageHandle(func(space *model.Space) {
+
+		dirPath := model.GetSpaceCacheRootDir(space.Name) + "/" + model.UPLOAD_PARTIAL_DIR
+		entries, err := os.ReadDir(dirPath)
+		if err != nil {
+			return
+		}
+
+		for _, entry := range entries {
+			uploadToken := this.uploadTokenDao.FindByUuid(entry.Name())
+			if uploadToken != nil && uploadToken.ExpireTime.After(time.Now()) {
+				continue
+			}
+
+			this.Logger.Info("clean expired upload %s/%s", space.Name, entry.Name())
+			err = os.Remove(dirPath + "/" + entry.Name())
+			if err != nil {
+				this.Logger.Error("occur error when cleaning expired upload. %v", err)
+			}
+			if uploadToken != nil && uploadToken.MatterUuid == "" {
+				this.uploadTokenDao.Delete(uploadToken)
+			}
+		}
+	})
+}
+
+func (this *TusService) tryLock(uuid string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.uploading[uuid] {
+		return false
+	}
+	this.uploading[uuid] = true
+	return true
+}
+
+func (this *TusService) unlock(uuid string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete(this.uploading, uuid)
+}
@@ -7,6 +7,7 @@ import (
 	"box/code/rest/model"
 	"box/code/tool/cache"
 	"box/code/tool/result"
+	"box/code/tool/storage"
 	"box/code/tool/util"
 	"box/code/tool/uuid"
 	"net/http"
@@ -30,6 +31,12 @@ type UserService struct {
 	imageCacheDao    *dao.ImageCacheDao
 	spaceDao         *dao.SpaceDao
 	spaceMemberDao   *dao.SpaceMemberDao
+	tagDao           *dao.TagDao
+	albumDao         *dao.AlbumDao
+	favoriteDao      *dao.FavoriteDao
+	recentDao        *dao.RecentDao
+	scanReportDao    *dao.ScanReportDao
+	jobDao           *dao.JobDao
 	shareDao         *dao.ShareDao
 	shareService     *ShareService
 	downloadTokenDao *dao.DownloadTokenDao
@@ -79,6 +86,36 @@ func (this *UserService) Init() {
 	if b, ok := b.(*dao.SpaceMemberDao); ok {
 		this.spaceMemberDao = b
 	}
+
+	b = core.CONTEXT.GetBean(this.tagDao)
+	if b, ok := b.(*dao.TagDao); ok {
+		this.tagDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.albumDao)
+	if b, ok := b.(*dao.AlbumDao); ok {
+		this.albumDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.favoriteDao)
+	if b, ok := b.(*dao.FavoriteDao); ok {
+		this.favoriteDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.recentDao)
+	if b, ok := b.(*dao.RecentDao); ok {
+		this.recentDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.scanReportDao)
+	if b, ok := b.(*dao.ScanReportDao); ok {
+		this.scanReportDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.jobDao)
+	if b, ok := b.(*dao.JobDao); ok {
+		this.jobDao = b
+	}
 	b = core.CONTEXT.GetBean(this.shareService)
 	if b, ok := b.(*ShareService); ok {
 		this.shareService = b
@@ -306,6 +343,18 @@ func (this *UserService) DeleteUser(request *http.Request, currentUser *model.Us
 	this.Logger.Info("elete shares and bridges")
 	this.shareService.DeleteSharesByUser(request, currentUser)
 
+	//delete favorites
+	this.Logger.Info("delete favorites")
+	this.favoriteDao.DeleteByUserUuid(currentUser.Uuid)
+
+	//delete recent files
+	this.Logger.Info("delete recent files")
+	this.recentDao.DeleteByUserUuid(currentUser.Uuid)
+
+	//delete jobs
+	this.Logger.Info("delete jobs")
+	this.jobDao.DeleteByUserUuid(currentUser.Uuid)
+
 	//delete caches
 	this.Logger.Info("delete caches")
 	this.imageCacheDao.DeleteByUserUuid(currentUser.Uuid)
@@ -319,6 +368,18 @@ func (this *UserService) DeleteUser(request *http.Request, currentUser *model.Us
 	this.Logger.Info("delete space members")
 	this.spaceMemberDao.DeleteBySpaceUuid(space.Uuid)
 
+	//delete tags
+	this.Logger.Info("delete tags")
+	this.tagDao.DeleteBySpaceUuid(space.Uuid)
+
+	//delete albums
+	this.Logger.Info("delete albums")
+	this.albumDao.DeleteBySpaceUuid(space.Uuid)
+
+	//delete scan reports
+	this.Logger.Info("delete scan reports")
+	this.scanReportDao.DeleteBySpaceUuid(space.Uuid)
+
 	//delete spaces
 	this.Logger.Info("delete spaces")
 	this.spaceDao.DeleteByUserUuid(currentUser.Uuid)
@@ -327,9 +388,14 @@ func (this *UserService) DeleteUser(request *http.Request, currentUser *model.Us
 	this.Logger.Info("delete this user.")
 	this.userDao.Delete(currentUser)
 
-	//delete files from disk.
+	//delete files from storage.
+	this.Logger.Info("delete files from storage. /%s", currentUser.Username)
+	err := storage.RemoveAll(core.CONTEXT.GetStorage(), "/"+currentUser.Username)
+	this.PanicError(err)
+
+	//delete caches from disk.
 	this.Logger.Info("delete files from disk. %s", model.GetUserSpaceRootDir(currentUser.Username))
-	err := os.RemoveAll(model.GetUserSpaceRootDir(currentUser.Username))
+	err = os.RemoveAll(model.GetUserSpaceRootDir(currentUser.Username))
 	this.PanicError(err)
 
 }
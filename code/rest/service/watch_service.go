@@ -0,0 +1,243 @@
+package service
+
+import (
+	"box/code/core"
+	"box/code/rest/bean"
+	"box/code/rest/dao"
+	"box/code/rest/model"
+	"box/code/tool/storage"
+	"box/code/tool/watch"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//sync after the changes stop for a while. eg. rsync writes a file in many times.
+	WATCH_DEBOUNCE = 2 * time.Second
+	//sync anyway if the changes never stop.
+	WATCH_MAX_DELAY = 30 * time.Second
+)
+
+// sync the changes in the watched spaces' root directories in near real time.
+// @Service
+type WatchService struct {
+	bean.BaseBean
+	spaceDao          *dao.SpaceDao
+	userDao           *dao.UserDao
+	preferenceService *PreferenceService
+	scanService       *ScanService
+
+	//spaceName -> watcher
+	watcherMutex sync.Mutex
+	watcherMap   map[string]*watch.Watcher
+}
+
+func (this *WatchService) Init() {
+	this.BaseBean.Init()
+
+	b := core.CONTEXT.GetBean(this.spaceDao)
+	if b, ok := b.(*dao.SpaceDao); ok {
+		this.spaceDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.userDao)
+	if b, ok := b.(*dao.UserDao); ok {
+		this.userDao = b
+	}
+
+	b = core.CONTEXT.GetBean(this.preferenceService)
+	if b, ok := b.(*PreferenceService); ok {
+		this.preferenceService = b
+	}
+
+	b = core.CONTEXT.GetBean(this.scanService)
+	if b, ok := b.(*ScanService); ok {
+		this.scanService = b
+	}
+
+	this.watcherMap = make(map[string]*watch.Watcher)
+}
+
+func (this *WatchService) Bootstrap() {
+
+	//load the watchers.
+	this.Reload()
+}
+
+// watch the spaces in the scan config. the others are not watched any more.
+func (this *WatchService) Reload() {
+
+	preference := this.preferenceService.Fetch()
+	scanConfig := preference.FetchScanConfig()
+
+	spaceNameMap := make(map[string]bool)
+	for _, spaceName := range scanConfig.WatchSpaceNames {
+		spaceNameMap[spaceName] = true
+	}
+
+	this.watcherMutex.Lock()
+	defer this.watcherMutex.Unlock()
+
+	for spaceName, watcher := range this.watcherMap {
+		if !spaceNameMap[spaceName] {
+			this.Logger.Info("stop watching %s", spaceName)
+			_ = watcher.Close()
+			delete(this.watcherMap, spaceName)
+		}
+	}
+
+	for spaceName := range spaceNameMap {
+		if _, ok := this.watcherMap[spaceName]; ok {
+			continue
+		}
+		space := this.spaceDao.FindByName(spaceName)
+		if space == nil {
+			this.Logger.Error("space %s not exist. cannot watch it.", spaceName)
+			continue
+		}
+		core.RunWithRecovery(func() {
+			this.startWatch(space)
+		})
+	}
+}
+
+// watcherMutex is held.
+func (this *WatchService) startWatch(space *model.Space) {
+
+	driver := core.CONTEXT.GetStorage()
+	localPather, ok := driver.(storage.LocalPather)
+	if !ok {
+		this.Logger.Error("%s storage cannot be watched. space = %s", driver.Name(), space.Name)
+		return
+	}
+
+	rootDirPath := model.GetSpaceMatterStoragePath(space.Name)
+	if !storage.Exists(driver, rootDirPath) {
+		err := driver.MkdirAll(rootDirPath)
+		this.PanicError(err)
+	}
+	rootLocalPath := localPather.LocalPath(rootDirPath)
+
+	watcher, err := watch.New(rootLocalPath)
+	if err != nil {
+		this.Logger.Error("cannot watch %s. %s", rootLocalPath, err.Error())
+		return
+	}
+	this.watcherMap[space.Name] = watcher
+	this.Logger.Info("start watching %s", rootLocalPath)
+
+	go core.RunWithRecovery(func() {
+		//the changes before watching.
+		this.sync(space.Uuid, nil, false)
+		this.run(space, rootLocalPath, watcher)
+	})
+}
+
+// collect the changed directories and sync them when the changes stop.
+func (this *WatchService) run(space *model.Space, rootLocalPath string, watcher *watch.Watcher) {
+
+	ticker := time.NewTicker(WATCH_DEBOUNCE / 4)
+	defer ticker.Stop()
+
+	dirPathMap := make(map[string]bool)
+	overflow := false
+	var firstTime, lastTime time.Time
+
+	flush := func() {
+		if len(dirPathMap) == 0 && !overflow {
+			return
+		}
+		this.sync(space.Uuid, dirPathMap, overflow)
+		dirPathMap = make(map[string]bool)
+		overflow = false
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				flush()
+				this.forget(space.Name, watcher)
+				this.Logger.Info("finish watching %s", rootLocalPath)
+				return
+			}
+			if event.Overflow {
+				overflow = true
+			} else if dirPath, ok := this.dirPathOf(rootLocalPath, event.Path); ok {
+				dirPathMap[dirPath] = true
+			} else {
+				continue
+			}
+			lastTime = time.Now()
+			if firstTime.IsZero() {
+				firstTime = lastTime
+			}
+		case <-ticker.C:
+			if firstTime.IsZero() {
+				continue
+			}
+			if time.Since(lastTime) >= WATCH_DEBOUNCE || time.Since(firstTime) >= WATCH_MAX_DELAY {
+				flush()
+				firstTime = time.Time{}
+			}
+		}
+	}
+}
+
+// matter path of the directory containing the changed entry. "" for the root.
+func (this *WatchService) dirPathOf(rootLocalPath string, localPath string) (string, bool) {
+	relativePath, err := filepath.Rel(rootLocalPath, localPath)
+	if err != nil || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+		return "", false
+	}
+	dirPath := path.Dir("/" + filepath.ToSlash(relativePath))
+	if dirPath == "/" {
+		dirPath = ""
+	}
+	return dirPath, true
+}
+
+// the watcher stops by itself.
+func (this *WatchService) forget(spaceName string, watcher *watch.Watcher) {
+	this.watcherMutex.Lock()
+	defer this.watcherMutex.Unlock()
+	if this.watcherMap[spaceName] == watcher {
+		delete(this.watcherMap, spaceName)
+	}
+}
+
+// sync the changed directories. nil dirPathMap scans the whole space incrementally, overflow scans it fully.
+func (this *WatchService) sync(spaceUuid string, dirPathMap map[string]bool, overflow bool) {
+
+	core.RunWithRecovery(func() {
+
+		space := this.spaceDao.FindByUuid(spaceUuid)
+		if space == nil {
+			return
+		}
+		user := this.userDao.FindByUuid(space.UserUuid)
+		if user == nil {
+			user = this.userDao.FindAnAdmin()
+		}
+
+		//mock a request.
+		request := &http.Request{}
+
+		if overflow {
+			this.Logger.Warn("watch events of %s overflowed. rescan the whole space.", space.Name)
+			this.scanService.Scan(request, user, space, true, false)
+		} else if dirPathMap == nil {
+			this.scanService.Scan(request, user, space, false, false)
+		} else {
+			dirPaths := make([]string, 0, len(dirPathMap))
+			for dirPath := range dirPathMap {
+				dirPaths = append(dirPaths, dirPath)
+			}
+			this.scanService.Sync(request, user, space, dirPaths)
+		}
+	})
+}
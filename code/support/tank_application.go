@@ -23,6 +23,8 @@ const (
 	MODE_MIRROR = "mirror"
 	//crawl remote file to EyeblueTank
 	MODE_CRAWL = "crawl"
+	//rebuild the search index.
+	MODE_REINDEX = "reindex"
 	//Current version.
 	MODE_VERSION = "version"
 )
@@ -56,7 +58,7 @@ func (this *TankApplication) Start() {
 		}
 	}()
 
-	modePtr := flag.String("mode", this.mode, "cli mode web/mirror/crawl")
+	modePtr := flag.String("mode", this.mode, "cli mode web/mirror/crawl/reindex")
 	hostPtr := flag.String("host", this.username, "tank host")
 	usernamePtr := flag.String("username", this.username, "username")
 	passwordPtr := flag.String("password", this.password, "password")
@@ -122,6 +124,10 @@ func (this *TankApplication) Start() {
 
 			this.HandleCrawl()
 
+		} else if strings.ToLower(this.mode) == MODE_REINDEX {
+
+			this.HandleReindex()
+
 		} else {
 			panic(result.BadRequest("cannot handle mode %s \r\n", this.mode))
 		}
@@ -248,6 +254,39 @@ func (this *TankApplication) HandleCrawl() {
 
 }
 
+// rebuild the search index. administrator is required.
+func (this *TankApplication) HandleReindex() {
+
+	fmt.Println("rebuild the search index of EyeblueTank")
+
+	urlString := fmt.Sprintf("%s/api/matter/search/rebuild", this.host)
+
+	params := url.Values{
+		core.USERNAME_KEY: {this.username},
+		core.PASSWORD_KEY: {this.password},
+	}
+
+	response, err := http.PostForm(urlString, params)
+	core.PanicError(err)
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+
+	webResult := &result.WebResult{}
+
+	err = jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(bodyBytes, webResult)
+	if err != nil {
+		fmt.Printf("error response format %s \r\n", err.Error())
+		return
+	}
+
+	if webResult.Code == result.OK.Code {
+		fmt.Printf("success. %v matters indexed\r\n", webResult.Data)
+	} else {
+		fmt.Printf("error %s\r\n", webResult.Msg)
+	}
+
+}
+
 // fetch the application version
 func (this *TankApplication) HandleVersion() {
 
@@ -2,6 +2,7 @@ package support
 
 import (
 	"box/code/core"
+	"box/code/tool/storage"
 	"box/code/tool/util"
 	"github.com/json-iterator/go"
 	"gorm.io/gorm/schema"
@@ -51,6 +52,23 @@ type ConfigItem struct {
 	//********sqlite configurations..********
 	//default value is matter/
 	SqliteFolder string
+	//********storage configurations..********
+	//default value is "local". "s3" means s3 compatible object storage.
+	StorageDriver string
+	//eg. https://s3.us-east-1.amazonaws.com
+	S3Endpoint string
+	//eg. us-east-1
+	S3Region string
+	//bucket name
+	S3Bucket string
+	//access key
+	S3AccessKey string
+	//secret key
+	S3SecretKey string
+	//key prefix in the bucket
+	S3Prefix string
+	//whether use path style url. eg. http://127.0.0.1:9000/bucket/key
+	S3PathStyle bool
 }
 
 // validate whether the config file is ok
@@ -61,6 +79,13 @@ func (this *ConfigItem) validate() bool {
 		return false
 	}
 
+	if this.StorageDriver == storage.DRIVER_S3 {
+		if this.S3Endpoint == "" || this.S3Bucket == "" {
+			core.LOGGER.Error("S3Endpoint or S3Bucket is not configured")
+			return false
+		}
+	}
+
 	if this.DbType == "sqlite" {
 
 	} else {
@@ -213,6 +238,30 @@ func (this *TankConfig) MatterPath() string {
 	return this.matterPath
 }
 
+// storage driver
+func (this *TankConfig) StorageDriver() string {
+	if this.item == nil || this.item.StorageDriver == "" {
+		return storage.DRIVER_LOCAL
+	}
+	return this.item.StorageDriver
+}
+
+// s3 storage configs
+func (this *TankConfig) S3Config() storage.S3Config {
+	if this.item == nil {
+		return storage.S3Config{}
+	}
+	return storage.S3Config{
+		Endpoint:  this.item.S3Endpoint,
+		Region:    this.item.S3Region,
+		Bucket:    this.item.S3Bucket,
+		AccessKey: this.item.S3AccessKey,
+		SecretKey: this.item.S3SecretKey,
+		Prefix:    this.item.S3Prefix,
+		PathStyle: this.item.S3PathStyle,
+	}
+}
+
 // matter path
 func (this *TankConfig) NamingStrategy() schema.NamingStrategy {
 	return schema.NamingStrategy{
@@ -238,6 +287,18 @@ func (this *TankConfig) FinishInstall(dbType string, mysqlPort int, mysqlHost st
 		MysqlCharset:  mysqlCharset,
 	}
 
+	//keep the storage configs when reinstall.
+	if this.item != nil {
+		configItem.StorageDriver = this.item.StorageDriver
+		configItem.S3Endpoint = this.item.S3Endpoint
+		configItem.S3Region = this.item.S3Region
+		configItem.S3Bucket = this.item.S3Bucket
+		configItem.S3AccessKey = this.item.S3AccessKey
+		configItem.S3SecretKey = this.item.S3SecretKey
+		configItem.S3Prefix = this.item.S3Prefix
+		configItem.S3PathStyle = this.item.S3PathStyle
+	}
+
 	//pretty json.
 	jsonStr, _ := jsoniter.ConfigCompatibleWithStandardLibrary.MarshalIndent(configItem, "", " ")
 
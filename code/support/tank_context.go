@@ -6,6 +6,7 @@ import (
 	"box/code/rest/dao"
 	"box/code/rest/service"
 	"box/code/tool/cache"
+	"box/code/tool/storage"
 	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -20,6 +21,8 @@ import (
 type TankContext struct {
 	//db connection
 	db *gorm.DB
+	//storage driver of matters
+	storage storage.Driver
 	//session cache
 	SessionCache *cache.Table
 	//bean map.
@@ -57,6 +60,10 @@ func (this *TankContext) GetDB() *gorm.DB {
 	return this.db
 }
 
+func (this *TankContext) GetStorage() storage.Driver {
+	return this.storage
+}
+
 func (this *TankContext) GetSessionCache() *cache.Table {
 	return this.SessionCache
 }
@@ -117,6 +124,23 @@ func (this *TankContext) OpenDb() {
 
 }
 
+func (this *TankContext) OpenStorage() {
+
+	if core.CONFIG.StorageDriver() == storage.DRIVER_S3 {
+
+		driver, err := storage.NewS3Driver(core.CONFIG.S3Config())
+		if err != nil {
+			core.LOGGER.Panic("failed to create s3 storage driver %s", err.Error())
+		}
+		this.storage = driver
+
+	} else {
+		this.storage = storage.NewLocalDriver(core.CONFIG.MatterPath())
+	}
+
+	core.LOGGER.Info("use storage driver: %s", this.storage.Name())
+}
+
 func (this *TankContext) CloseDb() {
 
 	if this.db != nil {
@@ -158,10 +182,19 @@ func (this *TankContext) registerBean(bean core.Bean) {
 
 func (this *TankContext) registerBeans() {
 
+	//album
+	this.registerBean(new(controller.AlbumController))
+	this.registerBean(new(dao.AlbumDao))
+	this.registerBean(new(dao.AlbumMatterDao))
+	this.registerBean(new(service.AlbumService))
+
 	//alien
 	this.registerBean(new(controller.AlienController))
 	this.registerBean(new(service.AlienService))
 
+	//blob
+	this.registerBean(new(dao.BlobDao))
+
 	//bridge
 	this.registerBean(new(dao.BridgeDao))
 	this.registerBean(new(service.BridgeService))
@@ -171,6 +204,11 @@ func (this *TankContext) registerBeans() {
 	this.registerBean(new(dao.DashboardDao))
 	this.registerBean(new(service.DashboardService))
 
+	//duplicate
+	this.registerBean(new(controller.DuplicateController))
+	this.registerBean(new(dao.DuplicateDao))
+	this.registerBean(new(service.DuplicateService))
+
 	//downloadToken
 	this.registerBean(new(dao.DownloadTokenDao))
 
@@ -182,21 +220,59 @@ func (this *TankContext) registerBeans() {
 	//install
 	this.registerBean(new(controller.InstallController))
 
+	//job
+	this.registerBean(new(controller.JobController))
+	this.registerBean(new(dao.JobDao))
+	this.registerBean(new(service.JobService))
+
 	//matter
 	this.registerBean(new(controller.MatterController))
 	this.registerBean(new(dao.MatterDao))
 	this.registerBean(new(service.MatterService))
 
+	//matterMedia
+	this.registerBean(new(dao.MatterMediaDao))
+	this.registerBean(new(service.MatterMediaService))
+
+	//matterSearch
+	this.registerBean(new(dao.MatterSearchDao))
+	this.registerBean(new(service.MatterSearchService))
+
+	//matterVersion
+	this.registerBean(new(controller.MatterVersionController))
+	this.registerBean(new(dao.MatterVersionDao))
+	this.registerBean(new(service.MatterVersionService))
+
+	//photo
+	this.registerBean(new(controller.PhotoController))
+	this.registerBean(new(dao.PhotoDao))
+	this.registerBean(new(service.PhotoService))
+
 	//preference
 	this.registerBean(new(controller.PreferenceController))
 	this.registerBean(new(dao.PreferenceDao))
 	this.registerBean(new(service.PreferenceService))
 
+	//favorite
+	this.registerBean(new(controller.FavoriteController))
+	this.registerBean(new(dao.FavoriteDao))
+	this.registerBean(new(service.FavoriteService))
+
 	//footprint
 	this.registerBean(new(controller.FootprintController))
 	this.registerBean(new(dao.FootprintDao))
 	this.registerBean(new(service.FootprintService))
 
+	//recent
+	this.registerBean(new(controller.RecentController))
+	this.registerBean(new(dao.RecentDao))
+	this.registerBean(new(service.RecentService))
+
+	//scan
+	this.registerBean(new(dao.ScanReportDao))
+	this.registerBean(new(service.ScanService))
+	this.registerBean(new(service.WatchService))
+
 	//session
 	this.registerBean(new(dao.SessionDao))
 	this.registerBean(new(service.SessionService))
@@ -216,6 +292,16 @@ func (this *TankContext) registerBeans() {
 	this.registerBean(new(dao.SpaceMemberDao))
 	this.registerBean(new(service.SpaceMemberService))
 
+	//tag
+	this.registerBean(new(controller.TagController))
+	this.registerBean(new(dao.TagDao))
+	this.registerBean(new(dao.MatterTagDao))
+	this.registerBean(new(service.TagService))
+
+	//tus
+	this.registerBean(new(controller.TusController))
+	this.registerBean(new(service.TusService))
+
 	//uploadToken
 	this.registerBean(new(dao.UploadTokenDao))
 
@@ -258,6 +344,7 @@ func (this *TankContext) InstallOk() {
 
 	if core.CONFIG.Installed() {
 		this.OpenDb()
+		this.OpenStorage()
 
 		for _, bean := range this.BeanMap {
 			bean.Bootstrap()
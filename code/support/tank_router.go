@@ -72,6 +72,11 @@ func NewRouter() *TankRouter {
 func (this *TankRouter) GlobalPanicHandler(writer http.ResponseWriter, request *http.Request, startTime time.Time) {
 	if err := recover(); err != nil {
 
+		//the response has been sent partly. let http server abort the connection.
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+
 		//get panic file and line number.
 		_, file, line, ok := runtime.Caller(2)
 		if !ok {
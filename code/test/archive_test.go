@@ -0,0 +1,228 @@
+package test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"box/code/tool/archive"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var archiveModTime = time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+
+func writeArchive(t *testing.T, format string) []byte {
+	buffer := &bytes.Buffer{}
+	writer, err := archive.NewWriter(format, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []*archive.Entry{
+		{Name: "docs", Dir: true, Mode: os.ModeDir | 0750, ModTime: archiveModTime},
+		{Name: "docs/a.txt", Size: 5, Mode: 0640, ModTime: archiveModTime},
+		{Name: "docs/empty.txt", Size: 0, ModTime: archiveModTime},
+	}
+	contents := []string{"", "hello", ""}
+	for i, entry := range entries {
+		entryWriter, err := writer.Create(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(entryWriter, contents[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestArchiveZip(t *testing.T) {
+	content := writeArchive(t, archive.FORMAT_ZIP)
+
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != 3 {
+		t.Fatalf("expect 3 entries, got %d", len(reader.File))
+	}
+	if reader.File[0].Name != "docs/" || !reader.File[0].Mode().IsDir() {
+		t.Errorf("bad dir entry %s %v", reader.File[0].Name, reader.File[0].Mode())
+	}
+	file := reader.File[1]
+	if file.Name != "docs/a.txt" || file.Mode().Perm() != 0640 || !file.Modified.Equal(archiveModTime) {
+		t.Errorf("bad file entry %s %v %v", file.Name, file.Mode(), file.Modified)
+	}
+	fileReader, err := file.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := io.ReadAll(fileReader)
+	if string(bs) != "hello" {
+		t.Errorf("bad content %s", bs)
+	}
+	if reader.File[2].Mode().Perm() != 0644 {
+		t.Errorf("expect default mode, got %v", reader.File[2].Mode())
+	}
+	for _, entry := range reader.File[1:] {
+		if entry.Method != zip.Deflate {
+			t.Errorf("expect %s deflated, got method %d", entry.Name, entry.Method)
+		}
+	}
+}
+
+func TestArchiveTar(t *testing.T) {
+	for _, format := range []string{archive.FORMAT_TAR, archive.FORMAT_TAR_GZ, archive.FORMAT_TAR_ZST} {
+		var reader io.Reader = bytes.NewReader(writeArchive(t, format))
+		switch format {
+		case archive.FORMAT_TAR_GZ:
+			gzipReader, err := gzip.NewReader(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader = gzipReader
+		case archive.FORMAT_TAR_ZST:
+			zstdReader, err := zstd.NewReader(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zstdReader.Close()
+			reader = zstdReader
+		}
+
+		tarReader := tar.NewReader(reader)
+		var names []string
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+			names = append(names, header.Name)
+			if !header.ModTime.Equal(archiveModTime) {
+				t.Errorf("%s: bad mod time %v", format, header.ModTime)
+			}
+			switch header.Name {
+			case "docs/":
+				if header.Typeflag != tar.TypeDir || header.Mode != 0750 {
+					t.Errorf("%s: bad dir entry %v %o", format, header.Typeflag, header.Mode)
+				}
+			case "docs/a.txt":
+				bs, _ := io.ReadAll(tarReader)
+				if string(bs) != "hello" || header.Mode != 0640 {
+					t.Errorf("%s: bad file entry %s %o", format, bs, header.Mode)
+				}
+			}
+		}
+		if len(names) != 3 {
+			t.Errorf("%s: expect 3 entries, got %v", format, names)
+		}
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	if !archive.IsFormat("tar.zst") || archive.IsFormat("rar") {
+		t.Error("bad format check")
+	}
+	if _, err := archive.NewWriter("rar", &bytes.Buffer{}); err == nil {
+		t.Error("expect error for unsupported format")
+	}
+}
+
+func TestArchiveWalk(t *testing.T) {
+	for _, format := range archive.FORMATS {
+		content := writeArchive(t, format)
+
+		entries, err := archive.List(format, bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(entries) != 3 || entries[0].Name != "docs" || !entries[0].Dir || entries[1].Size != 5 {
+			t.Errorf("%s: bad entries %v", format, entries)
+		}
+
+		contents := map[string]string{}
+		err = archive.Walk(format, bytes.NewReader(content), int64(len(content)), func(entry *archive.Entry, reader io.Reader) error {
+			if entry.Dir {
+				return nil
+			}
+			bs, err := io.ReadAll(reader)
+			contents[entry.Name] = string(bs)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if contents["docs/a.txt"] != "hello" || contents["docs/empty.txt"] != "" || len(contents) != 2 {
+			t.Errorf("%s: bad contents %v", format, contents)
+		}
+	}
+}
+
+func TestArchiveCleanName(t *testing.T) {
+	for name, expect := range map[string]string{"a/b.txt": "a/b.txt", "./a/": "a", "a\\b": "a/b", "a//b/./c": "a/b/c", "./": ""} {
+		if cleaned, err := archive.CleanName(name); err != nil || cleaned != expect {
+			t.Errorf("%s: expect %s, got %s %v", name, expect, cleaned, err)
+		}
+	}
+	for _, name := range []string{"../a", "a/../../b", "/etc/passwd", "C:\\a.txt", "a\\..\\..\\b"} {
+		if _, err := archive.CleanName(name); err == nil {
+			t.Errorf("%s should be rejected", name)
+		}
+	}
+}
+
+func TestArchiveZipGBK(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buffer)
+	//"中文.txt" in GBK, without the utf-8 flag.
+	name := string([]byte{0xd6, 0xd0, 0xce, 0xc4}) + ".txt"
+	if _, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, NonUTF8: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := archive.List(archive.FORMAT_ZIP, bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "中文.txt" {
+		t.Errorf("bad entries %v", entries)
+	}
+}
+
+func TestArchiveFind(t *testing.T) {
+	for _, format := range archive.FORMATS {
+		content := writeArchive(t, format)
+
+		var found string
+		err := archive.Find(format, bytes.NewReader(content), int64(len(content)), "docs/a.txt", func(entry *archive.Entry, reader io.Reader) error {
+			bs, err := io.ReadAll(reader)
+			found = string(bs)
+			return err
+		})
+		if err != nil || found != "hello" {
+			t.Errorf("%s: bad content %s %v", format, found, err)
+		}
+
+		err = archive.Find(format, bytes.NewReader(content), int64(len(content)), "docs/b.txt", func(entry *archive.Entry, reader io.Reader) error {
+			return nil
+		})
+		if err != archive.ErrEntryNotFound {
+			t.Errorf("%s: expect not found, got %v", format, err)
+		}
+	}
+}
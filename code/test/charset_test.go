@@ -0,0 +1,75 @@
+package test
+
+import (
+	"box/code/tool/charset"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	data, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCharsetDetect(t *testing.T) {
+
+	cases := []struct {
+		encoding string
+		data     []byte
+		text     string
+	}{
+		{charset.UTF8, []byte("时间,级别,内容\n2024-05-01,INFO,启动完成\n"), "时间,级别,内容\n2024-05-01,INFO,启动完成\n"},
+		{charset.UTF8, []byte("\xEF\xBB\xBFname\n"), "name\n"},
+		{charset.UTF16LE, encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "日志\n"), "日志\n"},
+		{charset.UTF16BE, encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), "日志\n"), "日志\n"},
+		{charset.GBK, encode(t, simplifiedchinese.GBK, "时间,级别,内容\n2024-05-01,信息,服务启动完成。\n"), "时间,级别,内容\n2024-05-01,信息,服务启动完成。\n"},
+		{charset.GB18030, encode(t, simplifiedchinese.GB18030, "用户名称：张三𠮷\n"), "用户名称：张三𠮷\n"},
+		{charset.BIG5, encode(t, traditionalchinese.Big5, "時間,級別,內容\n服務啟動完成，開始處理請求。\n"), "時間,級別,內容\n服務啟動完成，開始處理請求。\n"},
+		{charset.SHIFT_JIS, encode(t, japanese.ShiftJIS, "日付,ステータス\nサーバーを起動しました。\n"), "日付,ステータス\nサーバーを起動しました。\n"},
+	}
+
+	for _, c := range cases {
+		if encoding := charset.Detect(c.data); encoding != c.encoding {
+			t.Errorf("%s: detected %s", c.text, encoding)
+			continue
+		}
+		text, err := charset.Decode(c.data, c.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != c.text {
+			t.Errorf("%s: decoded %q", c.encoding, text)
+		}
+	}
+
+	//cut in the middle of a character.
+	data := []byte("日志日志")
+	if encoding := charset.Detect(data[:len(data)-1]); encoding != charset.UTF8 {
+		t.Errorf("truncated utf-8 detected as %s", encoding)
+	}
+}
+
+func TestCharsetLines(t *testing.T) {
+
+	data := encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "a\nb\nc")
+	if index := charset.IndexLine(data, charset.UTF16LE, 2); index != 8 {
+		t.Errorf("bad index %d", index)
+	}
+	if index := charset.LastIndexLine(data, charset.UTF16LE); index != 8 {
+		t.Errorf("bad last index %d", index)
+	}
+	if count := charset.CountLineBreaks(data, charset.UTF16LE); count != 2 {
+		t.Errorf("bad count %d", count)
+	}
+	if index := charset.IndexLine([]byte("a\nb"), charset.GBK, 2); index != -1 {
+		t.Errorf("bad index %d", index)
+	}
+}
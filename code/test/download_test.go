@@ -0,0 +1,52 @@
+package test
+
+import (
+	"box/code/tool/download"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownloadContentSingleRange(t *testing.T) {
+
+	content := []byte("0123456789")
+
+	request := httptest.NewRequest(http.MethodGet, "/download", nil)
+	request.Header.Set("Range", "bytes=2-5")
+	recorder := httptest.NewRecorder()
+
+	download.DownloadContent(recorder, request, bytes.NewReader(content), time.Now(), int64(len(content)), "a.txt", true)
+
+	if recorder.Code != http.StatusPartialContent {
+		t.Errorf("status %d, expect %d", recorder.Code, http.StatusPartialContent)
+	}
+	if contentRange := recorder.Header().Get("Content-Range"); contentRange != "bytes 2-5/10" {
+		t.Errorf("Content-Range %s, expect bytes 2-5/10", contentRange)
+	}
+	if contentLength := recorder.Header().Get("Content-Length"); contentLength != "4" {
+		t.Errorf("Content-Length %s, expect 4", contentLength)
+	}
+	//the range is sent once, after the headers.
+	if body := recorder.Body.String(); body != "2345" {
+		t.Errorf("body %s, expect 2345", body)
+	}
+}
+
+func TestDownloadContentWhole(t *testing.T) {
+
+	content := []byte("0123456789")
+
+	request := httptest.NewRequest(http.MethodGet, "/download", nil)
+	recorder := httptest.NewRecorder()
+
+	download.DownloadContent(recorder, request, bytes.NewReader(content), time.Now(), int64(len(content)), "a.txt", true)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status %d, expect %d", recorder.Code, http.StatusOK)
+	}
+	if body := recorder.Body.String(); body != string(content) {
+		t.Errorf("body %s, expect %s", body, content)
+	}
+}
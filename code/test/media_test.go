@@ -0,0 +1,247 @@
+package test
+
+import (
+	"box/code/tool/media"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+)
+
+// a field of the test tiff.
+type tiffField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiField(tag uint16, value string) tiffField {
+	return tiffField{tag: tag, typ: 2, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+func shortField(tag uint16, value uint16) tiffField {
+	return tiffField{tag: tag, typ: 3, count: 1, value: binary.BigEndian.AppendUint16(nil, value)}
+}
+
+func longField(tag uint16, value uint32) tiffField {
+	return tiffField{tag: tag, typ: 4, count: 1, value: binary.BigEndian.AppendUint32(nil, value)}
+}
+
+func rationalField(tag uint16, values ...uint32) tiffField {
+	var bs []byte
+	for _, value := range values {
+		bs = binary.BigEndian.AppendUint32(bs, value)
+	}
+	return tiffField{tag: tag, typ: 5, count: uint32(len(values) / 2), value: bs}
+}
+
+// build a big endian tiff with IFD0, exif IFD and gps IFD.
+func buildExif(ifd0 []tiffField, exifIfd []tiffField, gpsIfd []tiffField) []byte {
+
+	ifdSize := func(fields []tiffField) int {
+		size := 2 + len(fields)*12 + 4
+		for _, field := range fields {
+			if len(field.value) > 4 {
+				size += len(field.value)
+			}
+		}
+		return size
+	}
+	ifd0Offset := 8
+	exifOffset := ifd0Offset + ifdSize(ifd0) + 24
+	gpsOffset := exifOffset + ifdSize(exifIfd)
+	ifd0 = append(ifd0, longField(0x8769, uint32(exifOffset)), longField(0x8825, uint32(gpsOffset)))
+
+	buffer := []byte("MM\x00\x2a")
+	buffer = binary.BigEndian.AppendUint32(buffer, uint32(ifd0Offset))
+	for _, fields := range [][]tiffField{ifd0, exifIfd, gpsIfd} {
+		dataOffset := len(buffer) + 2 + len(fields)*12 + 4
+		var data []byte
+		buffer = binary.BigEndian.AppendUint16(buffer, uint16(len(fields)))
+		for _, field := range fields {
+			buffer = binary.BigEndian.AppendUint16(buffer, field.tag)
+			buffer = binary.BigEndian.AppendUint16(buffer, field.typ)
+			buffer = binary.BigEndian.AppendUint32(buffer, field.count)
+			if len(field.value) <= 4 {
+				buffer = append(buffer, field.value...)
+				buffer = append(buffer, make([]byte, 4-len(field.value))...)
+			} else {
+				buffer = binary.BigEndian.AppendUint32(buffer, uint32(dataOffset+len(data)))
+				data = append(data, field.value...)
+			}
+		}
+		buffer = binary.BigEndian.AppendUint32(buffer, 0)
+		buffer = append(buffer, data...)
+	}
+	return buffer
+}
+
+func testExif() []byte {
+	return buildExif(
+		[]tiffField{asciiField(0x010F, "Canon"), asciiField(0x0110, "EOS R5"), shortField(0x0112, 6)},
+		[]tiffField{asciiField(0x9003, "2024:05:01 08:30:00"), asciiField(0x9011, "+08:00")},
+		[]tiffField{asciiField(0x0001, "N"), rationalField(0x0002, 31, 1, 14, 1, 2400, 100), asciiField(0x0003, "W"), rationalField(0x0004, 121, 1, 30, 1, 0, 1)},
+	)
+}
+
+func checkExif(t *testing.T, metadata *media.Metadata) {
+	if metadata.Make != "Canon" || metadata.Model != "EOS R5" || metadata.Orientation != 6 {
+		t.Errorf("bad camera %v", metadata)
+	}
+	expectTime := time.Date(2024, 5, 1, 0, 30, 0, 0, time.UTC)
+	if metadata.TakenTime == nil || !metadata.TakenTime.Equal(expectTime) {
+		t.Errorf("bad taken time %v", metadata.TakenTime)
+	}
+	if metadata.Latitude == nil || math.Abs(*metadata.Latitude-31.24) > 1e-6 || metadata.Longitude == nil || *metadata.Longitude != -121.5 {
+		t.Errorf("bad gps %v %v", metadata.Latitude, metadata.Longitude)
+	}
+}
+
+func TestMediaJpeg(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := jpeg.Encode(buffer, image.NewGray(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	//insert APP1 after SOI.
+	exif := append([]byte("Exif\x00\x00"), testExif()...)
+	app1 := append([]byte{0xFF, 0xE1}, binary.BigEndian.AppendUint16(nil, uint16(len(exif)+2))...)
+	content := append(append(append([]byte{}, buffer.Bytes()[:2]...), append(app1, exif...)...), buffer.Bytes()[2:]...)
+
+	metadata, err := media.Extract("a.JPG", bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Width != 40 || metadata.Height != 30 {
+		t.Errorf("bad size %dx%d", metadata.Width, metadata.Height)
+	}
+	checkExif(t, metadata)
+}
+
+func TestMediaPng(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := png.Encode(buffer, image.NewGray(image.Rect(0, 0, 7, 5))); err != nil {
+		t.Fatal(err)
+	}
+	//insert eXIf after IHDR. the crc is not checked.
+	exif := testExif()
+	chunk := append(binary.BigEndian.AppendUint32(nil, uint32(len(exif))), []byte("eXIf")...)
+	chunk = append(append(chunk, exif...), 0, 0, 0, 0)
+	content := append(append(append([]byte{}, buffer.Bytes()[:33]...), chunk...), buffer.Bytes()[33:]...)
+
+	metadata, err := media.Extract("a.png", bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Width != 7 || metadata.Height != 5 {
+		t.Errorf("bad size %dx%d", metadata.Width, metadata.Height)
+	}
+	checkExif(t, metadata)
+}
+
+func TestMediaMp3(t *testing.T) {
+	frame := func(id string, text []byte) []byte {
+		bs := append([]byte(id), binary.BigEndian.AppendUint32(nil, uint32(len(text)))...)
+		return append(append(bs, 0, 0), text...)
+	}
+	var frames []byte
+	frames = append(frames, frame("TIT2", append([]byte{3}, "晴天"...))...)
+	//UTF-16 with BOM
+	frames = append(frames, frame("TPE1", []byte{1, 0xFF, 0xFE, 'J', 0, 'a', 0, 'y', 0})...)
+	frames = append(frames, frame("TALB", append([]byte{0}, "Ye\xe9"...))...)
+	frames = append(frames, frame("TYER", append([]byte{0}, "2003"...))...)
+
+	size := len(frames)
+	content := append([]byte("ID3\x03\x00\x00"), byte(size>>21&0x7f), byte(size>>14&0x7f), byte(size>>7&0x7f), byte(size&0x7f))
+	content = append(content, frames...)
+	//a MPEG1 layer III frame header. 128kbps 44100Hz stereo. the rest is silence.
+	content = append(content, 0xFF, 0xFB, 0x90, 0x00)
+	content = append(content, make([]byte, 16000-4)...)
+
+	metadata, err := media.Extract("a.mp3", bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "晴天" || metadata.Artist != "Jay" || metadata.Album != "Yeé" || metadata.Year != "2003" {
+		t.Errorf("bad tags %v", metadata)
+	}
+	if math.Abs(metadata.Duration-1) > 1e-6 {
+		t.Errorf("bad duration %v", metadata.Duration)
+	}
+}
+
+func TestMediaFlac(t *testing.T) {
+	info := make([]byte, 34)
+	//44100Hz, 441000 samples.
+	info[10], info[11], info[12] = 0x0A, 0xC4, 0x42
+	binary.BigEndian.PutUint32(info[14:], 441000)
+
+	comment := binary.LittleEndian.AppendUint32(nil, 3)
+	comment = append(comment, "abc"...)
+	comment = binary.LittleEndian.AppendUint32(comment, 2)
+	for _, value := range []string{"TITLE=Song", "artist=Band"} {
+		comment = binary.LittleEndian.AppendUint32(comment, uint32(len(value)))
+		comment = append(comment, value...)
+	}
+
+	content := []byte("fLaC")
+	content = append(content, 0x00, 0, 0, 34)
+	content = append(content, info...)
+	content = append(content, 0x84, 0, 0, byte(len(comment)))
+	content = append(content, comment...)
+
+	metadata, err := media.Extract("a.flac", bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "Song" || metadata.Artist != "Band" || metadata.Duration != 10 {
+		t.Errorf("bad metadata %v", metadata)
+	}
+}
+
+func TestMediaMp4(t *testing.T) {
+	box := func(boxType string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		return append(append(binary.BigEndian.AppendUint32(nil, uint32(len(body)+8)), boxType...), body...)
+	}
+
+	mvhd := make([]byte, 100)
+	//2024-01-01 00:00:00 UTC
+	binary.BigEndian.PutUint32(mvhd[4:], uint32(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Sub(time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC))/time.Second))
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 12500)
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], 1920<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], 1080<<16)
+	data := box("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Holiday"))
+
+	content := append(box("ftyp", []byte("isom\x00\x00\x02\x00")), box("mdat", make([]byte, 64))...)
+	content = append(content, box("moov", box("mvhd", mvhd), box("trak", box("tkhd", tkhd)), box("udta", box("meta", make([]byte, 4), box("ilst", box("\xa9nam", data)))))...)
+
+	metadata, err := media.Extract("a.mp4", bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Width != 1920 || metadata.Height != 1080 || metadata.Duration != 12.5 || metadata.Title != "Holiday" {
+		t.Errorf("bad metadata %v", metadata)
+	}
+	if metadata.TakenTime == nil || !metadata.TakenTime.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("bad time %v", metadata.TakenTime)
+	}
+}
+
+func TestMediaInvalid(t *testing.T) {
+	if media.IsSupported("a.txt") || !media.IsSupported("a.JPEG") {
+		t.Error("bad support check")
+	}
+	for _, filename := range []string{"a.jpg", "a.png", "a.webp", "a.flac", "a.mp4", "a.tiff"} {
+		content := []byte("not a media file at all")
+		if _, err := media.Extract(filename, bytes.NewReader(content), int64(len(content))); err == nil {
+			t.Errorf("%s: expect error", filename)
+		}
+	}
+}
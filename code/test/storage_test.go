@@ -0,0 +1,279 @@
+package test
+
+import (
+	"box/code/tool/storage"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// in process fake of s3. only the apis used by the storage driver are supported.
+type fakeS3 struct {
+	lock    sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+}
+
+func (this *fakeS3) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if !strings.HasPrefix(request.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") || request.Header.Get("x-amz-date") == "" {
+		writer.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, "/"+this.bucket), "/")
+	query := request.URL.Query()
+
+	switch {
+	case key == "" && request.Method == http.MethodGet:
+		this.list(writer, query)
+	case request.Method == http.MethodHead || request.Method == http.MethodGet:
+		content, ok := this.objects[key]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		status := http.StatusOK
+		if rangeHeader := request.Header.Get("Range"); rangeHeader != "" {
+			parts := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), "-")
+			start, _ := strconv.Atoi(parts[0])
+			end := len(content) - 1
+			if parts[1] != "" {
+				end, _ = strconv.Atoi(parts[1])
+			}
+			content = content[start : end+1]
+			status = http.StatusPartialContent
+		}
+		writer.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		writer.WriteHeader(status)
+		if request.Method == http.MethodGet {
+			writer.Write(content)
+		}
+	case request.Method == http.MethodPut:
+		body, _ := io.ReadAll(request.Body)
+		if uploadId := query.Get("uploadId"); uploadId != "" {
+			partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+			this.uploads[uploadId][partNumber] = body
+			writer.Header().Set("ETag", fmt.Sprintf("\"%d\"", partNumber))
+		} else if source := request.Header.Get("x-amz-copy-source"); source != "" {
+			sourceKey := strings.TrimPrefix(source, "/"+this.bucket+"/")
+			this.objects[key] = this.objects[sourceKey]
+			writer.Write([]byte("<CopyObjectResult></CopyObjectResult>"))
+		} else {
+			this.objects[key] = body
+		}
+	case request.Method == http.MethodPost:
+		if _, ok := query["uploads"]; ok {
+			uploadId := strconv.Itoa(len(this.uploads) + 1)
+			this.uploads[uploadId] = map[int][]byte{}
+			writer.Write([]byte("<InitiateMultipartUploadResult><UploadId>" + uploadId + "</UploadId></InitiateMultipartUploadResult>"))
+			return
+		}
+		complete := &struct {
+			Parts []struct {
+				PartNumber int `xml:"PartNumber"`
+			} `xml:"Part"`
+		}{}
+		body, _ := io.ReadAll(request.Body)
+		xml.Unmarshal(body, complete)
+		var content []byte
+		for _, part := range complete.Parts {
+			content = append(content, this.uploads[query.Get("uploadId")][part.PartNumber]...)
+		}
+		this.objects[key] = content
+		delete(this.uploads, query.Get("uploadId"))
+		writer.Write([]byte("<CompleteMultipartUploadResult></CompleteMultipartUploadResult>"))
+	case request.Method == http.MethodDelete:
+		delete(this.objects, key)
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list two keys a page, so that continuation is covered.
+func (this *fakeS3) list(writer http.ResponseWriter, query map[string][]string) {
+	get := func(name string) string {
+		if values, ok := query[name]; ok {
+			return values[0]
+		}
+		return ""
+	}
+	prefix, delimiter := get("prefix"), get("delimiter")
+
+	var entries []string
+	seen := map[string]bool{}
+	keys := make([]string, 0, len(this.objects))
+	for key := range this.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		entry := key
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				entry = key[:len(prefix)+index+1]
+			}
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	start, _ := strconv.Atoi(get("continuation-token"))
+	end := start + 2
+	if maxKeys, err := strconv.Atoi(get("max-keys")); err == nil && start+maxKeys < end {
+		end = start + maxKeys
+	}
+	truncated := end < len(entries)
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("<ListBucketResult>")
+	if truncated {
+		buffer.WriteString(fmt.Sprintf("<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", end))
+	}
+	for _, entry := range entries[start:end] {
+		if _, ok := this.objects[entry]; ok && (delimiter == "" || !strings.HasSuffix(entry, delimiter) || entry == prefix) {
+			buffer.WriteString(fmt.Sprintf("<Contents><Key>%s</Key><Size>%d</Size><LastModified>2024-01-02T03:04:05.000Z</LastModified></Contents>", entry, len(this.objects[entry])))
+		} else {
+			buffer.WriteString(fmt.Sprintf("<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", entry))
+		}
+	}
+	buffer.WriteString("</ListBucketResult>")
+	writer.Write(buffer.Bytes())
+}
+
+func checkStorageDriver(t *testing.T, driver storage.Driver) {
+
+	_, err := storage.Write(driver, "/space/root/a b/hello.txt", strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("write error %v", err)
+	}
+
+	info, err := driver.Stat("/space/root/a b/hello.txt")
+	if err != nil || info.Size() != 11 || info.IsDir() {
+		t.Fatalf("stat file error %v %v", info, err)
+	}
+	info, err = driver.Stat("/space/root/a b")
+	if err != nil || !info.IsDir() {
+		t.Fatalf("stat dir error %v %v", info, err)
+	}
+	if _, err = driver.Stat("/space/root/none"); !os.IsNotExist(err) {
+		t.Fatalf("should not exist %v", err)
+	}
+
+	file, err := driver.Open("/space/root/a b/hello.txt")
+	if err != nil {
+		t.Fatalf("open error %v", err)
+	}
+	buffer := make([]byte, 5)
+	if _, err = file.ReadAt(buffer, 0); err != nil || string(buffer) != "hello" {
+		t.Fatalf("read at error %s %v", buffer, err)
+	}
+	if _, err = file.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("seek error %v", err)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil || string(content) != "world" {
+		t.Fatalf("read error %s %v", content, err)
+	}
+	file.Close()
+
+	if err = driver.MkdirAll("/space/root/empty"); err != nil {
+		t.Fatalf("mkdir error %v", err)
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if _, err = storage.Write(driver, "/space/root/"+name, strings.NewReader(name)); err != nil {
+			t.Fatalf("write error %v", err)
+		}
+	}
+	infos, err := driver.List("/space/root")
+	if err != nil {
+		t.Fatalf("list error %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, fmt.Sprintf("%s:%v", info.Name(), info.IsDir()))
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a b:true,empty:true,x:false,y:false,z:false" {
+		t.Fatalf("list error %v", names)
+	}
+
+	if err = driver.Rename("/space/root/a b", "/space/root/c"); err != nil {
+		t.Fatalf("rename dir error %v", err)
+	}
+	if err = driver.Rename("/space/root/c/hello.txt", "/space/root/c/world.txt"); err != nil {
+		t.Fatalf("rename file error %v", err)
+	}
+	if !storage.Exists(driver, "/space/root/c/world.txt") || storage.Exists(driver, "/space/root/a b/hello.txt") {
+		t.Fatalf("rename not effected")
+	}
+
+	if !storage.RemoveEmptyDir(driver, "/space/root/empty") || storage.RemoveEmptyDir(driver, "/space/root/c") {
+		t.Fatalf("remove empty dir error")
+	}
+
+	if err = storage.RemoveAll(driver, "/"); err != nil {
+		t.Fatalf("remove all error %v", err)
+	}
+	if infos, err = driver.List("/"); err != nil || len(infos) != 0 {
+		t.Fatalf("should be empty %v %v", infos, err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	checkStorageDriver(t, storage.NewLocalDriver(t.TempDir()))
+}
+
+func TestS3Storage(t *testing.T) {
+
+	fake := newFakeS3("bucket")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	driver, err := storage.NewS3Driver(storage.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		AccessKey: "ak",
+		SecretKey: "sk",
+		Prefix:    "tank",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStorageDriver(t, driver)
+
+	//large file goes through multipart upload.
+	large := bytes.Repeat([]byte("0123456789abcdef"), storage.S3_PART_SIZE/16+1)
+	if _, err = storage.Write(driver, "/large", bytes.NewReader(large)); err != nil {
+		t.Fatalf("multipart upload error %v", err)
+	}
+	if !bytes.Equal(fake.objects["tank/large"], large) {
+		t.Fatalf("multipart content not match")
+	}
+}
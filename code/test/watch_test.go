@@ -0,0 +1,85 @@
+package test
+
+import (
+	"box/code/tool/watch"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// wait until an event of the path arrives.
+func waitEvent(t *testing.T, watcher *watch.Watcher, path string) watch.Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				t.Fatalf("events closed before %s", path)
+			}
+			if event.Path == path {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no event of %s", path)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := watch.New(root)
+	if err == watch.ErrNotSupported {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	//existing subdirectories are watched.
+	path := filepath.Join(root, "a", "b", "f.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitEvent(t, watcher, path); event.Dir {
+		t.Errorf("%s reported as directory", path)
+	}
+
+	//new directories are watched.
+	dir := filepath.Join(root, "c")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitEvent(t, watcher, dir); !event.Dir {
+		t.Errorf("%s reported as file", dir)
+	}
+	path = filepath.Join(dir, "g.txt")
+	if err := os.WriteFile(path, []byte("world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, watcher, path)
+
+	//moved directories are watched at the new place.
+	moved := filepath.Join(root, "a", "c")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, watcher, moved)
+	path = filepath.Join(moved, "h.txt")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, watcher, path)
+
+	if err := watcher.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range watcher.Events {
+	}
+}
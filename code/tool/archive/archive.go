@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	FORMAT_ZIP     = "zip"
+	FORMAT_TAR     = "tar"
+	FORMAT_TAR_GZ  = "tar.gz"
+	FORMAT_TAR_ZST = "tar.zst"
+)
+
+var FORMATS = []string{FORMAT_ZIP, FORMAT_TAR, FORMAT_TAR_GZ, FORMAT_TAR_ZST}
+
+// an entry of the archive.
+type Entry struct {
+	//relative path separated by /
+	Name    string
+	Dir     bool
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// write entries to an archive in order. the archive is streamed, nothing seeks back.
+type Writer interface {
+	//create an entry. content of a file should be written to the returned writer before next Create.
+	Create(entry *Entry) (io.Writer, error)
+	Close() error
+}
+
+func IsFormat(format string) bool {
+	for _, f := range FORMATS {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FORMAT_TAR:
+		return "application/x-tar"
+	case FORMAT_TAR_GZ:
+		return "application/gzip"
+	case FORMAT_TAR_ZST:
+		return "application/zstd"
+	default:
+		return "application/zip"
+	}
+}
+
+func NewWriter(format string, writer io.Writer) (Writer, error) {
+	switch format {
+	case FORMAT_ZIP:
+		//Zip64 will be used automatically when the archive is larger than 4G.
+		return &zipWriter{writer: zip.NewWriter(writer)}, nil
+	case FORMAT_TAR:
+		return &tarWriter{writer: tar.NewWriter(writer)}, nil
+	case FORMAT_TAR_GZ:
+		compressor := gzip.NewWriter(writer)
+		return &tarWriter{writer: tar.NewWriter(compressor), compressor: compressor}, nil
+	case FORMAT_TAR_ZST:
+		compressor, err := zstd.NewWriter(writer)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{writer: tar.NewWriter(compressor), compressor: compressor}, nil
+	default:
+		return nil, fmt.Errorf("archive format %s is not supported", format)
+	}
+}
+
+// the permission bits are kept, or use the common ones.
+func perm(entry *Entry) os.FileMode {
+	if entry.Mode.Perm() != 0 {
+		return entry.Mode.Perm()
+	}
+	if entry.Dir {
+		return 0755
+	}
+	return 0644
+}
+
+type zipWriter struct {
+	writer *zip.Writer
+}
+
+func (this *zipWriter) Create(entry *Entry) (io.Writer, error) {
+
+	fileHeader := &zip.FileHeader{Name: entry.Name, Modified: entry.ModTime, Method: zip.Deflate}
+	if entry.Dir {
+		// directory has suffix /
+		fileHeader.Name += "/"
+		fileHeader.SetMode(os.ModeDir | perm(entry))
+	} else {
+		fileHeader.UncompressedSize64 = uint64(entry.Size)
+		fileHeader.SetMode(perm(entry))
+	}
+
+	return this.writer.CreateHeader(fileHeader)
+}
+
+func (this *zipWriter) Close() error {
+	return this.writer.Close()
+}
+
+type tarWriter struct {
+	writer *tar.Writer
+	//nil if not compressed.
+	compressor io.WriteCloser
+}
+
+func (this *tarWriter) Create(entry *Entry) (io.Writer, error) {
+
+	header := &tar.Header{
+		Name:    entry.Name,
+		Mode:    int64(perm(entry)),
+		ModTime: entry.ModTime,
+	}
+	if entry.Dir {
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+	} else {
+		header.Typeflag = tar.TypeReg
+		header.Size = entry.Size
+	}
+
+	err := this.writer.WriteHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	return this.writer, nil
+}
+
+func (this *tarWriter) Close() error {
+	err := this.writer.Close()
+	if err != nil {
+		return err
+	}
+	if this.compressor != nil {
+		return this.compressor.Close()
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// detect the format by the file name. empty if it's not an archive.
+func DetectFormat(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FORMAT_ZIP
+	case strings.HasSuffix(name, ".tar"):
+		return FORMAT_TAR
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return FORMAT_TAR_GZ
+	case strings.HasSuffix(name, ".tar.zst") || strings.HasSuffix(name, ".tzst"):
+		return FORMAT_TAR_ZST
+	default:
+		return ""
+	}
+}
+
+// list the entries of an archive without reading their content.
+func List(format string, file io.ReaderAt, size int64) ([]*Entry, error) {
+	var entries []*Entry
+	err := walk(format, file, size, false, func(entry *Entry, reader io.Reader) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// read the entries of an archive in order. reader of a file is only valid inside the handler.
+// entries other than directories and regular files are skipped.
+func Walk(format string, file io.ReaderAt, size int64, handler func(entry *Entry, reader io.Reader) error) error {
+	return walk(format, file, size, true, handler)
+}
+
+func walk(format string, file io.ReaderAt, size int64, withContent bool, handler func(entry *Entry, reader io.Reader) error) error {
+
+	if format == FORMAT_ZIP {
+		zipReader, err := zip.NewReader(file, size)
+		if err != nil {
+			return err
+		}
+		for _, f := range zipReader.File {
+			err = walkZipFile(f, withContent, handler)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var reader io.Reader = io.NewSectionReader(file, 0, size)
+	switch format {
+	case FORMAT_TAR:
+	case FORMAT_TAR_GZ:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case FORMAT_TAR_ZST:
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		return fmt.Errorf("archive format %s is not supported", format)
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeDir && header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		name, err := CleanName(header.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		entry := &Entry{
+			Name:    name,
+			Dir:     header.Typeflag == tar.TypeDir,
+			Size:    header.Size,
+			Mode:    header.FileInfo().Mode(),
+			ModTime: header.ModTime,
+		}
+		if entry.Dir {
+			entry.Size = 0
+		}
+		err = handler(entry, tarReader)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func walkZipFile(f *zip.File, withContent bool, handler func(entry *Entry, reader io.Reader) error) error {
+
+	mode := f.Mode()
+	if !mode.IsDir() && !mode.IsRegular() {
+		return nil
+	}
+
+	//old tools on windows write names in GBK without the utf-8 flag.
+	rawName := f.Name
+	if f.NonUTF8 && !utf8.ValidString(rawName) {
+		decoded, err := simplifiedchinese.GBK.NewDecoder().String(rawName)
+		if err == nil {
+			rawName = decoded
+		}
+	}
+
+	name, err := CleanName(rawName)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+	entry := &Entry{
+		Name:    name,
+		Dir:     mode.IsDir(),
+		Size:    int64(f.UncompressedSize64),
+		Mode:    mode,
+		ModTime: f.Modified,
+	}
+
+	if entry.Dir || !withContent {
+		return handler(entry, nil)
+	}
+
+	reader, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return handler(entry, reader)
+}
+
+// clean the name of an entry to a relative path without the trailing /.
+// names escaping the destination are rejected. eg. /etc/passwd, ../a.txt, C:\a.txt
+func CleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", fmt.Errorf("insecure path %s in archive", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("insecure path %s in archive", name)
+		}
+	}
+
+	name = path.Clean(name)
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// the entry is not in the archive.
+var ErrEntryNotFound = errors.New("archive: entry not found")
+
+// stop walking.
+var errStop = errors.New("archive: stop")
+
+// read a single entry by its cleaned name. the archive is read until the entry is found.
+// reader is nil for a directory.
+func Find(format string, file io.ReaderAt, size int64, name string, handler func(entry *Entry, reader io.Reader) error) error {
+
+	found := false
+	err := walk(format, file, size, true, func(entry *Entry, reader io.Reader) error {
+		if entry.Name != name {
+			return nil
+		}
+		found = true
+		if err := handler(entry, reader); err != nil {
+			return err
+		}
+		return errStop
+	})
+	if err == errStop {
+		return nil
+	}
+	if err == nil && !found {
+		return ErrEntryNotFound
+	}
+	return err
+}
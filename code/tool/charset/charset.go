@@ -0,0 +1,276 @@
+package charset
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	UTF8      = "UTF-8"
+	UTF16LE   = "UTF-16LE"
+	UTF16BE   = "UTF-16BE"
+	GBK       = "GBK"
+	GB18030   = "GB18030"
+	BIG5      = "Big5"
+	SHIFT_JIS = "Shift_JIS"
+)
+
+var errEncoding = errors.New("charset: unknown encoding")
+
+// the decoders. the BOM is removed.
+var encodings = map[string]encoding.Encoding{
+	UTF16LE:   unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	UTF16BE:   unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	GBK:       simplifiedchinese.GBK,
+	GB18030:   simplifiedchinese.GB18030,
+	BIG5:      traditionalchinese.Big5,
+	SHIFT_JIS: japanese.ShiftJIS,
+}
+
+// a double byte encoding. scan returns the number of the frequently used characters and the number of the illegal sequences.
+type candidate struct {
+	name string
+	scan func(data []byte) (name string, common int, illegal int)
+}
+
+// in order of priority when the scores are equal.
+var candidates = []candidate{
+	{GBK, scanGb},
+	{BIG5, scanBig5},
+	{SHIFT_JIS, scanShiftJis},
+}
+
+// detect the encoding of the head of a text. the data may end in the middle of a character.
+// UTF-8 is returned if nothing matches.
+func Detect(data []byte) string {
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+
+	if validUtf8(data) {
+		return UTF8
+	}
+
+	result := UTF8
+	best := -1
+	for _, c := range candidates {
+		name, common, illegal := c.scan(data)
+		//tolerate a little garbage.
+		if illegal*100 > common+1 {
+			continue
+		}
+		if common-illegal > best {
+			result = name
+			best = common - illegal
+		}
+	}
+	return result
+}
+
+// the incomplete character at the end is ignored.
+func validUtf8(data []byte) bool {
+	for i := 0; i < utf8.UTFMax && i < len(data); i++ {
+		if utf8.Valid(data[:len(data)-i]) {
+			return true
+		}
+		//only the bytes of a multibyte character can be cut.
+		if data[len(data)-1-i] < 0x80 {
+			return false
+		}
+	}
+	return len(data) == 0
+}
+
+// transcode the data to UTF-8. the BOM is removed and the illegal bytes become U+FFFD.
+func Decode(data []byte, name string) (string, error) {
+
+	if name == UTF8 {
+		return string(bytes.ToValidUTF8(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}), []byte("�"))), nil
+	}
+	enc, ok := encodings[name]
+	if !ok {
+		return "", errEncoding
+	}
+	//the standard decoders replace illegal bytes instead of failing.
+	result, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// bytes of a code unit.
+func unitSize(name string) int {
+	if name == UTF16LE || name == UTF16BE {
+		return 2
+	}
+	return 1
+}
+
+func isLineBreak(data []byte, i int, name string) bool {
+	switch name {
+	case UTF16LE:
+		return data[i] == '\n' && data[i+1] == 0
+	case UTF16BE:
+		return data[i] == 0 && data[i+1] == '\n'
+	default:
+		//'\n' is never a part of a multibyte character in the supported encodings.
+		return data[i] == '\n'
+	}
+}
+
+// index after the n-th line break. -1 if there are not so many lines.
+func IndexLine(data []byte, name string, n int) int {
+	size := unitSize(name)
+	for i := 0; i+size <= len(data); i += size {
+		if isLineBreak(data, i, name) {
+			n--
+			if n == 0 {
+				return i + size
+			}
+		}
+	}
+	return -1
+}
+
+// index after the last line break. -1 if there is no line break.
+func LastIndexLine(data []byte, name string) int {
+	size := unitSize(name)
+	for i := (len(data)/size - 1) * size; i >= 0; i -= size {
+		if isLineBreak(data, i, name) {
+			return i + size
+		}
+	}
+	return -1
+}
+
+// number of the line breaks.
+func CountLineBreaks(data []byte, name string) int {
+	if unitSize(name) == 1 {
+		return bytes.Count(data, []byte{'\n'})
+	}
+	count := 0
+	for i := 0; i+1 < len(data); i += 2 {
+		if isLineBreak(data, i, name) {
+			count++
+		}
+	}
+	return count
+}
+
+// GBK and the 4-byte sequences of GB18030. frequently used: GB2312 symbols and level 1 hanzi.
+func scanGb(data []byte) (name string, common int, illegal int) {
+	name = GBK
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < 0x80 {
+			i++
+			continue
+		}
+		if b == 0x80 || b == 0xFF {
+			illegal++
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			break
+		}
+		t := data[i+1]
+		if t >= 0x30 && t <= 0x39 {
+			if i+3 >= len(data) {
+				break
+			}
+			if data[i+2] >= 0x81 && data[i+2] <= 0xFE && data[i+3] >= 0x30 && data[i+3] <= 0x39 {
+				name = GB18030
+				i += 4
+			} else {
+				illegal++
+				i++
+			}
+			continue
+		}
+		if t < 0x40 || t == 0x7F || t == 0xFF {
+			illegal++
+			i++
+			continue
+		}
+		if t >= 0xA1 && ((b >= 0xA1 && b <= 0xA3) || (b >= 0xB0 && b <= 0xD7)) {
+			common++
+		}
+		i += 2
+	}
+	return
+}
+
+// frequently used: symbols and the frequently used hanzi.
+func scanBig5(data []byte) (name string, common int, illegal int) {
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < 0x80 {
+			i++
+			continue
+		}
+		if b < 0x81 || b == 0xFF {
+			illegal++
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			break
+		}
+		t := data[i+1]
+		if !((t >= 0x40 && t <= 0x7E) || (t >= 0xA1 && t <= 0xFE)) {
+			illegal++
+			i++
+			continue
+		}
+		if b >= 0xA1 && b <= 0xC6 {
+			common++
+		}
+		i += 2
+	}
+	return BIG5, common, illegal
+}
+
+// frequently used: symbols, kana and level 1 kanji. single byte katakana are legal but not counted.
+func scanShiftJis(data []byte) (name string, common int, illegal int) {
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < 0x80 || (b >= 0xA1 && b <= 0xDF) {
+			i++
+			continue
+		}
+		if b == 0x80 || b == 0xA0 || b > 0xFC {
+			illegal++
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			break
+		}
+		t := data[i+1]
+		if t < 0x40 || t == 0x7F || t > 0xFC {
+			illegal++
+			i++
+			continue
+		}
+		if b <= 0x83 || (b >= 0x88 && b <= 0x98) {
+			common++
+		}
+		i += 2
+	}
+	return SHIFT_JIS, common, illegal
+}
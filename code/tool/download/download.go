@@ -1,6 +1,7 @@
 package download
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -42,6 +43,19 @@ func (w *CountingWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// ContextReader stops reading once the context is done. eg. the client has gone.
+type ContextReader struct {
+	Context context.Context
+	Reader  io.Reader
+}
+
+func (r *ContextReader) Read(p []byte) (n int, err error) {
+	if err := r.Context.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
 // 检查Last-Modified头。返回true: 请求已经完成了。（言下之意，文件没有修改过） 返回false：文件修改过。
 func CheckLastModified(w http.ResponseWriter, r *http.Request, modifyTime time.Time) bool {
 	if modifyTime.IsZero() {
@@ -228,18 +242,29 @@ func DownloadFile(
 		PanicError(e)
 	}()
 
-	//content-disposition tell browser to download rather than preview.
-	if withContentDisposition {
-		fileName := url.QueryEscape(filename)
-		writer.Header().Set("content-disposition", "attachment; filename=\""+fileName+"\"")
-	}
-
 	fileInfo, err := diskFile.Stat()
 	if err != nil {
 		panic("cannot load fileInfo from disk." + filePath)
 	}
 
-	modifyTime := fileInfo.ModTime()
+	DownloadContent(writer, request, diskFile, fileInfo.ModTime(), fileInfo.Size(), filename, withContentDisposition)
+}
+
+// download content from any seekable source. eg. a file in storage driver.
+func DownloadContent(
+	writer http.ResponseWriter,
+	request *http.Request,
+	diskFile io.ReadSeeker,
+	modifyTime time.Time,
+	size int64,
+	filename string,
+	withContentDisposition bool) {
+
+	//content-disposition tell browser to download rather than preview.
+	if withContentDisposition {
+		fileName := url.QueryEscape(filename)
+		writer.Header().Set("content-disposition", "attachment; filename=\""+fileName+"\"")
+	}
 
 	if CheckLastModified(writer, request, modifyTime) {
 		return
@@ -267,7 +292,7 @@ func DownloadFile(
 			var buf [sniffLen]byte
 			n, _ := io.ReadFull(diskFile, buf[:])
 			ctype = http.DetectContentType(buf[:n])
-			_, err := diskFile.Seek(0, os.SEEK_SET) // rewind to output whole file
+			_, err := diskFile.Seek(0, io.SeekStart) // rewind to output whole file
 			if err != nil {
 				panic("cannot seek file")
 			}
@@ -277,8 +302,6 @@ func DownloadFile(
 		ctype = ctypes[0]
 	}
 
-	size := fileInfo.Size()
-
 	// handle Content-Range header.
 	sendSize := size
 	var sendContent io.Reader = diskFile
@@ -314,8 +337,6 @@ func DownloadFile(
 			sendSize = ra.length
 			code = http.StatusPartialContent
 			writer.Header().Set("Content-Range", ra.contentRange(size))
-			//  把文件放进去
-			io.CopyN(writer, diskFile, int64(ra.length))
 		case len(ranges) > 1:
 			sendSize = RangesMIMESize(ranges, ctype, size)
 			code = http.StatusPartialContent
@@ -0,0 +1,323 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// bitrates in kbps of MPEG layer III. [MPEG1, MPEG2 and 2.5][index]
+var mp3Bitrates = [2][16]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+// sample rates. [MPEG1, MPEG2, MPEG2.5][index]
+var mp3SampleRates = [3][4]int{
+	{44100, 48000, 32000, 0},
+	{22050, 24000, 16000, 0},
+	{11025, 12000, 8000, 0},
+}
+
+// size in ID3v2 header is 4 bytes of 7 bits.
+func syncsafe(bs []byte) int64 {
+	return int64(bs[0]&0x7f)<<21 | int64(bs[1]&0x7f)<<14 | int64(bs[2]&0x7f)<<7 | int64(bs[3]&0x7f)
+}
+
+// decode the text of ID3 frames. the first byte is the encoding.
+func id3Text(bs []byte) string {
+	if len(bs) == 0 {
+		return ""
+	}
+	encoding, bs := bs[0], bs[1:]
+
+	var text string
+	switch encoding {
+	case 1, 2:
+		var order binary.ByteOrder = binary.BigEndian
+		if len(bs) >= 2 && bs[0] == 0xFF && bs[1] == 0xFE {
+			order, bs = binary.LittleEndian, bs[2:]
+		} else if len(bs) >= 2 && bs[0] == 0xFE && bs[1] == 0xFF {
+			bs = bs[2:]
+		}
+		units := make([]uint16, 0, len(bs)/2)
+		for i := 0; i+1 < len(bs); i += 2 {
+			units = append(units, order.Uint16(bs[i:]))
+		}
+		text = string(utf16.Decode(units))
+	case 3:
+		text = string(bs)
+	default:
+		//ISO-8859-1
+		runes := make([]rune, len(bs))
+		for i, b := range bs {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+
+	//ID3v2.4 separates multiple values with NUL. take the first one.
+	if i := strings.IndexRune(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// read the ID3v2 tag at the beginning. return the size of the tag.
+func readId3v2(reader io.ReaderAt, metadata *Metadata) int64 {
+
+	head, err := readAt(reader, 0, 10)
+	if err != nil || string(head[:3]) != "ID3" {
+		return 0
+	}
+	version := head[3]
+	tagSize := syncsafe(head[6:])
+	if tagSize > 16<<20 {
+		return 10 + tagSize
+	}
+	tag, err := readAt(reader, 10, int(tagSize))
+	if err != nil {
+		return 0
+	}
+
+	offset := 0
+	//skip the extended header.
+	if head[5]&0x40 != 0 && len(tag) >= 4 {
+		if version == 4 {
+			offset = int(syncsafe(tag))
+		} else {
+			offset = int(binary.BigEndian.Uint32(tag)) + 4
+		}
+	}
+
+	idSize, headSize := 4, 10
+	if version == 2 {
+		idSize, headSize = 3, 6
+	}
+	for offset+headSize <= len(tag) {
+		id := string(tag[offset : offset+idSize])
+		if id[0] == 0 {
+			break
+		}
+		var size int
+		switch version {
+		case 2:
+			size = int(tag[offset+3])<<16 | int(tag[offset+4])<<8 | int(tag[offset+5])
+		case 4:
+			size = int(syncsafe(tag[offset+4:]))
+		default:
+			size = int(binary.BigEndian.Uint32(tag[offset+4:]))
+		}
+		offset += headSize
+		if size < 0 || offset+size > len(tag) {
+			break
+		}
+		value := tag[offset : offset+size]
+		offset += size
+
+		switch id {
+		case "TIT2", "TT2":
+			metadata.Title = id3Text(value)
+		case "TPE1", "TP1":
+			metadata.Artist = id3Text(value)
+		case "TALB", "TAL":
+			metadata.Album = id3Text(value)
+		case "TYER", "TYE", "TDRC":
+			if year := id3Text(value); len(year) >= 4 {
+				metadata.Year = year[:4]
+			}
+		case "TCON", "TCO":
+			metadata.Genre = id3Text(value)
+		}
+	}
+
+	return 10 + tagSize
+}
+
+// read the ID3v1 tag at the end.
+func readId3v1(reader io.ReaderAt, size int64, metadata *Metadata) {
+	if size < 128 {
+		return
+	}
+	tag, err := readAt(reader, size-128, 128)
+	if err != nil || string(tag[:3]) != "TAG" {
+		return
+	}
+	field := func(bs []byte) string {
+		return id3Text(append([]byte{0}, bs...))
+	}
+	if metadata.Title == "" {
+		metadata.Title = field(tag[3:33])
+	}
+	if metadata.Artist == "" {
+		metadata.Artist = field(tag[33:63])
+	}
+	if metadata.Album == "" {
+		metadata.Album = field(tag[63:93])
+	}
+	if metadata.Year == "" {
+		metadata.Year = field(tag[93:97])
+	}
+}
+
+// compute the duration by the first frame. use the Xing header if VBR, otherwise assume CBR.
+func mp3Duration(reader io.ReaderAt, offset int64, size int64) float64 {
+
+	//find the frame sync in a small window.
+	window, err := readAt(reader, offset, int(min(4096, size-offset)))
+	if err != nil {
+		return 0
+	}
+	for i := 0; i+4 <= len(window); i++ {
+		if window[i] != 0xFF || window[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		header := binary.BigEndian.Uint32(window[i:])
+		versionBits := (header >> 19) & 0x3
+		layerBits := (header >> 17) & 0x3
+		bitrateIndex := (header >> 12) & 0xF
+		sampleRateIndex := (header >> 10) & 0x3
+		//only layer III.
+		if versionBits == 1 || layerBits != 1 {
+			continue
+		}
+
+		versionIndex, samplesPerFrame := 0, 1152
+		if versionBits == 2 {
+			versionIndex, samplesPerFrame = 1, 576
+		} else if versionBits == 0 {
+			versionIndex, samplesPerFrame = 2, 576
+		}
+		bitrate := mp3Bitrates[min(versionIndex, 1)][bitrateIndex]
+		sampleRate := mp3SampleRates[versionIndex][sampleRateIndex]
+		if bitrate == 0 || sampleRate == 0 {
+			continue
+		}
+
+		//Xing or Info header of VBR.
+		mono := (header>>6)&0x3 == 3
+		sideInfo := 32
+		if versionIndex == 0 && mono {
+			sideInfo = 17
+		} else if versionIndex != 0 && !mono {
+			sideInfo = 17
+		} else if versionIndex != 0 {
+			sideInfo = 9
+		}
+		xingOffset := offset + int64(i) + 4 + int64(sideInfo)
+		if xing, err := readAt(reader, xingOffset, 12); err == nil {
+			if tag := string(xing[:4]); (tag == "Xing" || tag == "Info") && xing[7]&0x1 != 0 {
+				frames := binary.BigEndian.Uint32(xing[8:])
+				return float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+			}
+		}
+
+		return float64(size-offset-int64(i)) * 8 / float64(bitrate*1000)
+	}
+	return 0
+}
+
+func extractMp3(reader io.ReaderAt, size int64) (*Metadata, error) {
+	metadata := &Metadata{}
+	offset := readId3v2(reader, metadata)
+	readId3v1(reader, size, metadata)
+	if offset < size {
+		metadata.Duration = mp3Duration(reader, offset, size)
+	}
+	return metadata, nil
+}
+
+func extractFlac(reader io.ReaderAt, size int64) (*Metadata, error) {
+
+	head, err := readAt(reader, 0, 4)
+	if err != nil {
+		return nil, err
+	}
+	if string(head) != "fLaC" {
+		return nil, errFormat
+	}
+
+	metadata := &Metadata{}
+	var offset int64 = 4
+	for offset+4 <= size {
+		blockHead, err := readAt(reader, offset, 4)
+		if err != nil {
+			return nil, err
+		}
+		last := blockHead[0]&0x80 != 0
+		blockType := blockHead[0] & 0x7f
+		length := int64(blockHead[1])<<16 | int64(blockHead[2])<<8 | int64(blockHead[3])
+		offset += 4
+
+		switch blockType {
+		case 0:
+			//STREAMINFO
+			info, err := readAt(reader, offset, 18)
+			if err != nil {
+				return nil, err
+			}
+			sampleRate := int64(info[10])<<12 | int64(info[11])<<4 | int64(info[12])>>4
+			totalSamples := int64(info[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(info[14:]))
+			if sampleRate > 0 {
+				metadata.Duration = float64(totalSamples) / float64(sampleRate)
+			}
+		case 4:
+			//VORBIS_COMMENT
+			if length < 1<<20 {
+				block, err := readAt(reader, offset, int(length))
+				if err != nil {
+					return nil, err
+				}
+				readVorbisComment(block, metadata)
+			}
+		}
+
+		offset += length
+		if last {
+			break
+		}
+	}
+	return metadata, nil
+}
+
+// vorbis comments are little endian. KEY=value
+func readVorbisComment(block []byte, metadata *Metadata) {
+	if len(block) < 4 {
+		return
+	}
+	position := 4 + int(binary.LittleEndian.Uint32(block))
+	if position+4 > len(block) {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(block[position:]))
+	position += 4
+	for i := 0; i < count && position+4 <= len(block); i++ {
+		length := int(binary.LittleEndian.Uint32(block[position:]))
+		position += 4
+		if length < 0 || position+length > len(block) {
+			return
+		}
+		comment := string(block[position : position+length])
+		position += length
+
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			metadata.Title = value
+		case "ARTIST":
+			metadata.Artist = value
+		case "ALBUM":
+			metadata.Album = value
+		case "DATE":
+			if len(value) >= 4 {
+				metadata.Year = value[:4]
+			}
+		case "GENRE":
+			metadata.Genre = value
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	TAG_IMAGE_WIDTH          = 0x0100
+	TAG_IMAGE_HEIGHT         = 0x0101
+	TAG_MAKE                 = 0x010F
+	TAG_MODEL                = 0x0110
+	TAG_ORIENTATION          = 0x0112
+	TAG_DATE_TIME            = 0x0132
+	TAG_EXIF_IFD             = 0x8769
+	TAG_GPS_IFD              = 0x8825
+	TAG_DATE_TIME_ORIGINAL   = 0x9003
+	TAG_OFFSET_TIME_ORIGINAL = 0x9011
+	TAG_PIXEL_X_DIMENSION    = 0xA002
+	TAG_PIXEL_Y_DIMENSION    = 0xA003
+	TAG_GPS_LATITUDE_REF     = 0x0001
+	TAG_GPS_LATITUDE         = 0x0002
+	TAG_GPS_LONGITUDE_REF    = 0x0003
+	TAG_GPS_LONGITUDE        = 0x0004
+)
+
+// bytes of each tiff field type.
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// a field in an IFD.
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+type tiffReader struct {
+	reader io.ReaderAt
+	size   int64
+	order  binary.ByteOrder
+}
+
+// read an IFD. tag -> entry
+func (this *tiffReader) readIfd(offset int64) (map[uint16]*tiffEntry, error) {
+
+	head, err := readAt(this.reader, offset, 2)
+	if err != nil {
+		return nil, err
+	}
+	count := int(this.order.Uint16(head))
+	if count > 1000 {
+		return nil, errFormat
+	}
+
+	bs, err := readAt(this.reader, offset+2, count*12)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[uint16]*tiffEntry)
+	for i := 0; i < count; i++ {
+		field := bs[i*12 : i*12+12]
+		entry := &tiffEntry{typ: this.order.Uint16(field[2:]), count: this.order.Uint32(field[4:])}
+		typeSize, ok := tiffTypeSizes[entry.typ]
+		if !ok || entry.count > 1<<16 {
+			continue
+		}
+		length := typeSize * int(entry.count)
+		if length <= 4 {
+			entry.value = field[8 : 8+length]
+		} else {
+			valueOffset := int64(this.order.Uint32(field[8:]))
+			if valueOffset+int64(length) > this.size {
+				continue
+			}
+			if entry.value, err = readAt(this.reader, valueOffset, length); err != nil {
+				continue
+			}
+		}
+		entries[this.order.Uint16(field)] = entry
+	}
+	return entries, nil
+}
+
+func (this *tiffReader) uint(entry *tiffEntry) (uint32, bool) {
+	if entry == nil || entry.count == 0 {
+		return 0, false
+	}
+	switch entry.typ {
+	case 1, 7:
+		return uint32(entry.value[0]), true
+	case 3:
+		return uint32(this.order.Uint16(entry.value)), true
+	case 4:
+		return this.order.Uint32(entry.value), true
+	}
+	return 0, false
+}
+
+func (this *tiffReader) string(entry *tiffEntry) string {
+	if entry == nil || entry.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+func (this *tiffReader) rationals(entry *tiffEntry) []float64 {
+	if entry == nil || entry.typ != 5 {
+		return nil
+	}
+	var values []float64
+	for i := 0; i+8 <= len(entry.value); i += 8 {
+		numerator := this.order.Uint32(entry.value[i:])
+		denominator := this.order.Uint32(entry.value[i+4:])
+		if denominator == 0 {
+			return nil
+		}
+		values = append(values, float64(numerator)/float64(denominator))
+	}
+	return values
+}
+
+// parse exif time like "2024:05:01 08:30:00". offset like "+08:00" is optional.
+func parseExifTime(value string, offset string) *time.Time {
+	location := time.Local
+	if offset != "" {
+		if t, err := time.Parse("-07:00", offset); err == nil {
+			location = t.Location()
+		}
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, location)
+	if err != nil || t.Year() < 1900 {
+		return nil
+	}
+	return &t
+}
+
+// degrees, minutes, seconds to degrees.
+func gpsCoordinate(values []float64, ref string) *float64 {
+	if len(values) != 3 {
+		return nil
+	}
+	degrees := values[0] + values[1]/60 + values[2]/3600
+	if ref == "S" || ref == "W" {
+		degrees = -degrees
+	}
+	return &degrees
+}
+
+// parse a tiff structure, it's the exif block of jpeg, png and webp, or the whole tiff file.
+func parseTiff(reader io.ReaderAt, size int64, metadata *Metadata) error {
+
+	head, err := readAt(reader, 0, 8)
+	if err != nil {
+		return err
+	}
+	tiff := &tiffReader{reader: reader, size: size}
+	switch string(head[:2]) {
+	case "II":
+		tiff.order = binary.LittleEndian
+	case "MM":
+		tiff.order = binary.BigEndian
+	default:
+		return errFormat
+	}
+	if tiff.order.Uint16(head[2:]) != 42 {
+		return errFormat
+	}
+
+	ifd0, err := tiff.readIfd(int64(tiff.order.Uint32(head[4:])))
+	if err != nil {
+		return err
+	}
+
+	if width, ok := tiff.uint(ifd0[TAG_IMAGE_WIDTH]); ok && metadata.Width == 0 {
+		metadata.Width = int(width)
+	}
+	if height, ok := tiff.uint(ifd0[TAG_IMAGE_HEIGHT]); ok && metadata.Height == 0 {
+		metadata.Height = int(height)
+	}
+	metadata.Make = tiff.string(ifd0[TAG_MAKE])
+	metadata.Model = tiff.string(ifd0[TAG_MODEL])
+	if orientation, ok := tiff.uint(ifd0[TAG_ORIENTATION]); ok {
+		metadata.Orientation = int(orientation)
+	}
+	metadata.TakenTime = parseExifTime(tiff.string(ifd0[TAG_DATE_TIME]), "")
+
+	if offset, ok := tiff.uint(ifd0[TAG_EXIF_IFD]); ok {
+		if exifIfd, err := tiff.readIfd(int64(offset)); err == nil {
+			if takenTime := parseExifTime(tiff.string(exifIfd[TAG_DATE_TIME_ORIGINAL]), tiff.string(exifIfd[TAG_OFFSET_TIME_ORIGINAL])); takenTime != nil {
+				metadata.TakenTime = takenTime
+			}
+			if width, ok := tiff.uint(exifIfd[TAG_PIXEL_X_DIMENSION]); ok && metadata.Width == 0 {
+				metadata.Width = int(width)
+			}
+			if height, ok := tiff.uint(exifIfd[TAG_PIXEL_Y_DIMENSION]); ok && metadata.Height == 0 {
+				metadata.Height = int(height)
+			}
+		}
+	}
+
+	if offset, ok := tiff.uint(ifd0[TAG_GPS_IFD]); ok {
+		if gpsIfd, err := tiff.readIfd(int64(offset)); err == nil {
+			metadata.Latitude = gpsCoordinate(tiff.rationals(gpsIfd[TAG_GPS_LATITUDE]), tiff.string(gpsIfd[TAG_GPS_LATITUDE_REF]))
+			metadata.Longitude = gpsCoordinate(tiff.rationals(gpsIfd[TAG_GPS_LONGITUDE]), tiff.string(gpsIfd[TAG_GPS_LONGITUDE_REF]))
+			if metadata.Latitude == nil || metadata.Longitude == nil {
+				metadata.Latitude, metadata.Longitude = nil, nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// exif block may start with "Exif\0\0".
+func parseExifBlock(block []byte, metadata *Metadata) error {
+	block = bytes.TrimPrefix(block, []byte("Exif\x00\x00"))
+	return parseTiff(bytes.NewReader(block), int64(len(block)), metadata)
+}
+
+func extractTiff(reader io.ReaderAt, size int64) (*Metadata, error) {
+	metadata := &Metadata{}
+	if err := parseTiff(reader, size, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// walk the segments before the image data.
+func extractJpeg(reader io.ReaderAt, size int64) (*Metadata, error) {
+
+	head, err := readAt(reader, 0, 2)
+	if err != nil {
+		return nil, err
+	}
+	if head[0] != 0xFF || head[1] != 0xD8 {
+		return nil, errFormat
+	}
+
+	metadata := &Metadata{}
+	var width, height int
+	var offset int64 = 2
+	for offset+4 <= size {
+		segment, err := readAt(reader, offset, 4)
+		if err != nil {
+			return nil, err
+		}
+		if segment[0] != 0xFF {
+			return nil, errFormat
+		}
+		marker := segment[1]
+		//padding
+		if marker == 0xFF {
+			offset++
+			continue
+		}
+		//start of scan. image data follows.
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int64(binary.BigEndian.Uint16(segment[2:]))
+
+		if marker == 0xE1 && length > 8 {
+			block, err := readAt(reader, offset+4, int(length-2))
+			if err != nil {
+				return nil, err
+			}
+			if bytes.HasPrefix(block, []byte("Exif\x00\x00")) {
+				_ = parseExifBlock(block, metadata)
+			}
+		} else if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			//start of frame.
+			frame, err := readAt(reader, offset+4, 5)
+			if err != nil {
+				return nil, err
+			}
+			height = int(binary.BigEndian.Uint16(frame[1:]))
+			width = int(binary.BigEndian.Uint16(frame[3:]))
+		}
+
+		offset += 2 + length
+	}
+
+	//the frame tells the real size.
+	if width > 0 {
+		metadata.Width, metadata.Height = width, height
+	}
+	return metadata, nil
+}
+
+func extractPng(reader io.ReaderAt, size int64) (*Metadata, error) {
+
+	head, err := readAt(reader, 0, 8)
+	if err != nil {
+		return nil, err
+	}
+	if string(head) != "\x89PNG\r\n\x1a\n" {
+		return nil, errFormat
+	}
+
+	metadata := &Metadata{}
+	var offset int64 = 8
+	for offset+8 <= size {
+		chunk, err := readAt(reader, offset, 8)
+		if err != nil {
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint32(chunk))
+		switch string(chunk[4:]) {
+		case "IHDR":
+			bs, err := readAt(reader, offset+8, 8)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Width = int(binary.BigEndian.Uint32(bs))
+			metadata.Height = int(binary.BigEndian.Uint32(bs[4:]))
+		case "eXIf":
+			if length < 1<<20 {
+				block, err := readAt(reader, offset+8, int(length))
+				if err != nil {
+					return nil, err
+				}
+				_ = parseExifBlock(block, metadata)
+			}
+		case "IEND":
+			return metadata, nil
+		}
+		offset += 12 + length
+	}
+	return metadata, nil
+}
+
+func extractWebp(reader io.ReaderAt, size int64) (*Metadata, error) {
+
+	head, err := readAt(reader, 0, 12)
+	if err != nil {
+		return nil, err
+	}
+	if string(head[:4]) != "RIFF" || string(head[8:]) != "WEBP" {
+		return nil, errFormat
+	}
+
+	metadata := &Metadata{}
+	var offset int64 = 12
+	for offset+8 <= size {
+		chunk, err := readAt(reader, offset, 8)
+		if err != nil {
+			return nil, err
+		}
+		length := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[:4]) {
+		case "VP8X":
+			bs, err := readAt(reader, offset+8, 10)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Width = int(uint32(bs[4])|uint32(bs[5])<<8|uint32(bs[6])<<16) + 1
+			metadata.Height = int(uint32(bs[7])|uint32(bs[8])<<8|uint32(bs[9])<<16) + 1
+		case "VP8 ":
+			bs, err := readAt(reader, offset+8, 10)
+			if err != nil {
+				return nil, err
+			}
+			if metadata.Width == 0 && bs[3] == 0x9d && bs[4] == 0x01 && bs[5] == 0x2a {
+				metadata.Width = int(binary.LittleEndian.Uint16(bs[6:]) & 0x3fff)
+				metadata.Height = int(binary.LittleEndian.Uint16(bs[8:]) & 0x3fff)
+			}
+		case "VP8L":
+			bs, err := readAt(reader, offset+8, 5)
+			if err != nil {
+				return nil, err
+			}
+			if metadata.Width == 0 && bs[0] == 0x2f {
+				bits := binary.LittleEndian.Uint32(bs[1:])
+				metadata.Width = int(bits&0x3fff) + 1
+				metadata.Height = int((bits>>14)&0x3fff) + 1
+			}
+		case "EXIF":
+			if length < 1<<20 {
+				block, err := readAt(reader, offset+8, int(length))
+				if err != nil {
+					return nil, err
+				}
+				_ = parseExifBlock(block, metadata)
+			}
+		}
+		//chunks are padded to even size.
+		offset += 8 + length + length%2
+	}
+	return metadata, nil
+}
@@ -0,0 +1,80 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// metadata of an image, audio or video. zero value means unknown.
+type Metadata struct {
+	Width       int
+	Height      int
+	Orientation int
+	//camera
+	Make  string
+	Model string
+	//when the photo was taken or the video was recorded. in local time if the file doesn't tell.
+	TakenTime *time.Time
+	//GPS in degrees. south and west are negative.
+	Latitude  *float64
+	Longitude *float64
+	//in seconds.
+	Duration float64
+	Title    string
+	Artist   string
+	Album    string
+	Year     string
+	Genre    string
+}
+
+var errFormat = errors.New("media: invalid format")
+
+// extractors by extension without dot.
+var extractors = map[string]func(reader io.ReaderAt, size int64) (*Metadata, error){
+	"jpg":  extractJpeg,
+	"jpeg": extractJpeg,
+	"png":  extractPng,
+	"tif":  extractTiff,
+	"tiff": extractTiff,
+	"webp": extractWebp,
+	"mp3":  extractMp3,
+	"flac": extractFlac,
+	"mp4":  extractMp4,
+	"m4a":  extractMp4,
+	"m4v":  extractMp4,
+	"mov":  extractMp4,
+}
+
+func extension(filename string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(filename)), ".")
+}
+
+// whether metadata can be extracted from the file.
+func IsSupported(filename string) bool {
+	_, ok := extractors[extension(filename)]
+	return ok
+}
+
+// extract the metadata judged by the filename's extension.
+func Extract(filename string, reader io.ReaderAt, size int64) (*Metadata, error) {
+	extractor, ok := extractors[extension(filename)]
+	if !ok {
+		return nil, errors.New("media: unsupported file " + filename)
+	}
+	return extractor(reader, size)
+}
+
+// read n bytes at offset.
+func readAt(reader io.ReaderAt, offset int64, n int) ([]byte, error) {
+	bs := make([]byte, n)
+	if _, err := reader.ReadAt(bs, offset); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return bs, nil
+}
@@ -0,0 +1,146 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// times in mp4 are seconds since 1904-01-01 UTC.
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// boxes which contain other boxes.
+var mp4Containers = map[string]bool{"moov": true, "trak": true, "udta": true, "meta": true, "ilst": true}
+
+// walk the boxes in [offset, end). containers are descended, handler is called for the other boxes.
+func walkMp4(reader io.ReaderAt, offset int64, end int64, depth int, handler func(boxType string, offset int64, size int64) error) error {
+
+	if depth > 8 {
+		return nil
+	}
+
+	for offset+8 <= end {
+		head, err := readAt(reader, offset, 8)
+		if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(head))
+		boxType := string(head[4:])
+		headSize := int64(8)
+		if size == 1 {
+			large, err := readAt(reader, offset+8, 8)
+			if err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headSize = 16
+		} else if size == 0 {
+			size = end - offset
+		}
+		if size < headSize || offset+size > end {
+			return errFormat
+		}
+
+		if mp4Containers[boxType] {
+			childOffset := offset + headSize
+			//meta is a full box with 4 bytes version and flags.
+			if boxType == "meta" {
+				childOffset += 4
+			}
+			if err := walkMp4(reader, childOffset, offset+size, depth+1, handler); err != nil {
+				return err
+			}
+		} else if err := handler(boxType, offset+headSize, size-headSize); err != nil {
+			return err
+		}
+
+		offset += size
+	}
+	return nil
+}
+
+// the value in the data box of an ilst item.
+func mp4ItemText(reader io.ReaderAt, offset int64, size int64) string {
+	if size < 16 || size > 1<<16 {
+		return ""
+	}
+	bs, err := readAt(reader, offset, int(size))
+	if err != nil || string(bs[4:8]) != "data" {
+		return ""
+	}
+	return string(bs[16:])
+}
+
+func extractMp4(reader io.ReaderAt, size int64) (*Metadata, error) {
+
+	head, err := readAt(reader, 0, 8)
+	if err != nil {
+		return nil, err
+	}
+	if string(head[4:]) != "ftyp" {
+		return nil, errFormat
+	}
+
+	metadata := &Metadata{}
+	err = walkMp4(reader, 0, size, 0, func(boxType string, offset int64, length int64) error {
+		switch boxType {
+		case "mvhd":
+			bs, err := readAt(reader, offset, int(min(length, 32)))
+			if err != nil {
+				return err
+			}
+			var creation, timescale, duration uint64
+			if bs[0] == 1 && len(bs) >= 32 {
+				creation = binary.BigEndian.Uint64(bs[4:])
+				timescale = uint64(binary.BigEndian.Uint32(bs[20:]))
+				duration = binary.BigEndian.Uint64(bs[24:])
+			} else if len(bs) >= 20 {
+				creation = uint64(binary.BigEndian.Uint32(bs[4:]))
+				timescale = uint64(binary.BigEndian.Uint32(bs[12:]))
+				duration = uint64(binary.BigEndian.Uint32(bs[16:]))
+			}
+			if timescale > 0 {
+				metadata.Duration = float64(duration) / float64(timescale)
+			}
+			if creation > 0 {
+				takenTime := mp4Epoch.Add(time.Duration(creation) * time.Second).Local()
+				metadata.TakenTime = &takenTime
+			}
+		case "tkhd":
+			bs, err := readAt(reader, offset, int(min(length, 96)))
+			if err != nil {
+				return err
+			}
+			position := 76
+			if bs[0] == 1 {
+				position = 88
+			}
+			if len(bs) >= position+8 {
+				//16.16 fixed point. audio tracks are 0.
+				width := int(binary.BigEndian.Uint32(bs[position:]) >> 16)
+				height := int(binary.BigEndian.Uint32(bs[position+4:]) >> 16)
+				if width > metadata.Width {
+					metadata.Width, metadata.Height = width, height
+				}
+			}
+		case "\xa9nam":
+			metadata.Title = mp4ItemText(reader, offset, length)
+		case "\xa9ART":
+			metadata.Artist = mp4ItemText(reader, offset, length)
+		case "\xa9alb":
+			metadata.Album = mp4ItemText(reader, offset, length)
+		case "\xa9day":
+			if year := mp4ItemText(reader, offset, length); len(year) >= 4 {
+				metadata.Year = year[:4]
+			}
+		case "\xa9gen":
+			metadata.Genre = mp4ItemText(reader, offset, length)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package storage
+
+import (
+	"os"
+)
+
+// inode is not available on this platform.
+func Inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// store files on local disk under the root directory.
+type LocalDriver struct {
+	root string
+}
+
+func NewLocalDriver(root string) *LocalDriver {
+	return &LocalDriver{root: filepath.Clean(root)}
+}
+
+func (this *LocalDriver) Name() string {
+	return DRIVER_LOCAL
+}
+
+// real path on disk. path cannot escape from the root.
+func (this *LocalDriver) LocalPath(path string) string {
+	return filepath.Join(this.root, filepath.FromSlash(CleanPath(path)))
+}
+
+func (this *LocalDriver) Open(path string) (File, error) {
+	return os.Open(this.LocalPath(path))
+}
+
+func (this *LocalDriver) Create(path string) (io.WriteCloser, error) {
+	localPath := this.LocalPath(path)
+	err := os.MkdirAll(filepath.Dir(localPath), 0777)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+}
+
+func (this *LocalDriver) Rename(oldPath string, newPath string) error {
+	newLocalPath := this.LocalPath(newPath)
+	err := os.MkdirAll(filepath.Dir(newLocalPath), 0777)
+	if err != nil {
+		return err
+	}
+	return os.Rename(this.LocalPath(oldPath), newLocalPath)
+}
+
+func (this *LocalDriver) Remove(path string) error {
+	return os.Remove(this.LocalPath(path))
+}
+
+func (this *LocalDriver) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(this.LocalPath(path))
+}
+
+func (this *LocalDriver) List(path string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(this.LocalPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			//removed during listing.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (this *LocalDriver) MkdirAll(path string) error {
+	return os.MkdirAll(this.LocalPath(path), 0777)
+}
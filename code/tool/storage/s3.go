@@ -0,0 +1,716 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//files larger than this are uploaded by multipart upload.
+	S3_PART_SIZE = 64 * 1024 * 1024
+	//server side copy cannot exceed 5GB in a single request.
+	S3_MAX_COPY_SIZE = 5 * 1024 * 1024 * 1024
+	//payload is not signed, so that large bodies can be streamed.
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// configuration of a s3 compatible object storage.
+type S3Config struct {
+	//eg. https://s3.us-east-1.amazonaws.com or http://127.0.0.1:9000
+	Endpoint string
+	//eg. us-east-1
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	//key prefix in the bucket. eg. tank/
+	Prefix string
+	//use http://endpoint/bucket/key rather than http://bucket.endpoint/key
+	PathStyle bool
+}
+
+/**
+ * store files in a s3 compatible object storage. directories are key prefixes,
+ * empty directories are kept by a marker object whose key ends with /
+ */
+type S3Driver struct {
+	config   S3Config
+	endpoint *url.URL
+	prefix   string
+	client   *http.Client
+}
+
+func NewS3Driver(config S3Config) (*S3Driver, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	prefix := strings.Trim(config.Prefix, "/")
+	if prefix != "" {
+		prefix = prefix + "/"
+	}
+
+	return &S3Driver{
+		config:   config,
+		endpoint: endpoint,
+		prefix:   prefix,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (this *S3Driver) Name() string {
+	return DRIVER_S3
+}
+
+func (this *S3Driver) Open(path string) (File, error) {
+	key := this.key(path)
+	info, err := this.headObject(key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3File{driver: this, key: key, size: info.Size()}, nil
+}
+
+func (this *S3Driver) Create(path string) (io.WriteCloser, error) {
+	//buffer on local disk, so that the length is known when uploading.
+	tempFile, err := os.CreateTemp("", "tank-s3-*")
+	if err != nil {
+		return nil, err
+	}
+	return &s3Writer{driver: this, key: this.key(path), file: tempFile}, nil
+}
+
+func (this *S3Driver) Rename(oldPath string, newPath string) error {
+	oldKey := this.key(oldPath)
+	newKey := this.key(newPath)
+
+	info, err := this.headObject(oldKey)
+	if err == nil {
+		return this.renameObject(oldKey, newKey, info.Size())
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	//directory. move every object under the prefix.
+	objects, _, err := this.listObjects(oldKey+"/", "", 0)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return notExistError("rename", oldPath)
+	}
+	for _, object := range objects {
+		err = this.renameObject(object.Key, newKey+"/"+strings.TrimPrefix(object.Key, oldKey+"/"), object.Size)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *S3Driver) Remove(path string) error {
+	key := this.key(path)
+
+	_, err := this.headObject(key)
+	if err == nil {
+		return this.deleteObject(key)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	objects, _, err := this.listObjects(key+"/", "", 2)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return notExistError("remove", path)
+	}
+	for _, object := range objects {
+		if object.Key != key+"/" {
+			return &os.PathError{Op: "remove", Path: path, Err: errors.New("directory not empty")}
+		}
+	}
+	return this.deleteObject(key + "/")
+}
+
+func (this *S3Driver) Stat(path string) (os.FileInfo, error) {
+	if CleanPath(path) == "/" {
+		return &fileInfo{name: "/", dir: true}, nil
+	}
+
+	key := this.key(path)
+	info, err := this.headObject(key)
+	if err == nil || !os.IsNotExist(err) {
+		return info, err
+	}
+
+	objects, prefixes, err := this.listObjects(key+"/", "/", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 && len(prefixes) == 0 {
+		return nil, notExistError("stat", path)
+	}
+	return &fileInfo{name: pathBase(path), dir: true}, nil
+}
+
+func (this *S3Driver) List(path string) ([]os.FileInfo, error) {
+	prefix := this.prefix
+	if CleanPath(path) != "/" {
+		prefix = this.key(path) + "/"
+	}
+
+	objects, prefixes, err := this.listObjects(prefix, "/", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(objects)+len(prefixes))
+	for _, p := range prefixes {
+		infos = append(infos, &fileInfo{name: strings.TrimSuffix(strings.TrimPrefix(p, prefix), "/"), dir: true})
+	}
+	marked := false
+	for _, object := range objects {
+		if object.Key == prefix {
+			//the marker of directory itself.
+			marked = true
+			continue
+		}
+		infos = append(infos, &fileInfo{name: strings.TrimPrefix(object.Key, prefix), size: object.Size, modTime: object.LastModified})
+	}
+
+	if len(infos) == 0 && !marked && CleanPath(path) != "/" {
+		return nil, notExistError("list", path)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	return infos, nil
+}
+
+func (this *S3Driver) MkdirAll(path string) error {
+	if CleanPath(path) == "/" {
+		return nil
+	}
+	return this.putObject(this.key(path)+"/", http.NoBody, 0)
+}
+
+// object key of a path.
+func (this *S3Driver) key(p string) string {
+	return this.prefix + strings.TrimPrefix(CleanPath(p), "/")
+}
+
+func (this *S3Driver) renameObject(oldKey string, newKey string, size int64) error {
+	if size <= S3_MAX_COPY_SIZE {
+		err := this.copyObject(oldKey, newKey)
+		if err != nil {
+			return err
+		}
+	} else {
+		//too large for server side copy. stream it through.
+		file := &s3File{driver: this, key: oldKey, size: size}
+		writer := &s3Writer{driver: this, key: newKey}
+		tempFile, err := os.CreateTemp("", "tank-s3-*")
+		if err != nil {
+			return err
+		}
+		writer.file = tempFile
+		_, err = io.Copy(writer, file)
+		closeErr := writer.Close()
+		_ = file.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return this.deleteObject(oldKey)
+}
+
+func (this *S3Driver) headObject(key string) (os.FileInfo, error) {
+	response, err := this.do(http.MethodHead, key, nil, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	modTime, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+	return &fileInfo{name: pathBase(key), size: response.ContentLength, modTime: modTime}, nil
+}
+
+// get a range of an object. end < 0 means to the end.
+func (this *S3Driver) getObject(key string, start int64, end int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if end >= 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	} else if start > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+
+	response, err := this.do(http.MethodGet, key, nil, nil, 0, header)
+	if err != nil {
+		return nil, err
+	}
+
+	//range not supported by the server. skip by ourselves.
+	if response.StatusCode == http.StatusOK && start > 0 {
+		_, err = io.CopyN(io.Discard, response.Body, start)
+		if err != nil {
+			response.Body.Close()
+			return nil, err
+		}
+	}
+	return response.Body, nil
+}
+
+func (this *S3Driver) putObject(key string, body io.Reader, size int64) error {
+	response, err := this.do(http.MethodPut, key, nil, body, size, nil)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (this *S3Driver) copyObject(srcKey string, destKey string) error {
+	header := http.Header{}
+	header.Set("x-amz-copy-source", s3Escape("/"+this.config.Bucket+"/"+srcKey, false))
+	response, err := this.do(http.MethodPut, destKey, nil, http.NoBody, 0, header)
+	if err != nil {
+		return err
+	}
+	//copy may fail after 200 returned.
+	return readS3Error(response)
+}
+
+func (this *S3Driver) deleteObject(key string) error {
+	response, err := this.do(http.MethodDelete, key, nil, nil, 0, nil)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return response.Body.Close()
+}
+
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type s3ListResult struct {
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+	Contents              []s3Object `xml:"Contents"`
+	CommonPrefixes        []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// list objects under a prefix. limit <= 0 means all.
+func (this *S3Driver) listObjects(prefix string, delimiter string, limit int) ([]s3Object, []string, error) {
+	var objects []s3Object
+	var prefixes []string
+
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if limit > 0 {
+			query.Set("max-keys", strconv.Itoa(limit))
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		response, err := this.do(http.MethodGet, "", query, nil, 0, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		listResult := &s3ListResult{}
+		err = xml.NewDecoder(response.Body).Decode(listResult)
+		response.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		objects = append(objects, listResult.Contents...)
+		for _, p := range listResult.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+
+		if !listResult.IsTruncated || listResult.NextContinuationToken == "" || limit > 0 {
+			return objects, prefixes, nil
+		}
+		token = listResult.NextContinuationToken
+	}
+}
+
+// upload a local file by multipart upload.
+func (this *S3Driver) multipartUpload(key string, file *os.File, size int64) error {
+	response, err := this.do(http.MethodPost, key, url.Values{"uploads": {""}}, http.NoBody, 0, nil)
+	if err != nil {
+		return err
+	}
+	initResult := &struct {
+		UploadId string `xml:"UploadId"`
+	}{}
+	err = xml.NewDecoder(response.Body).Decode(initResult)
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	type completePart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	complete := &struct {
+		XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+		Parts   []completePart `xml:"Part"`
+	}{}
+
+	abort := func(err error) error {
+		response, abortErr := this.do(http.MethodDelete, key, url.Values{"uploadId": {initResult.UploadId}}, nil, 0, nil)
+		if abortErr == nil {
+			response.Body.Close()
+		}
+		return err
+	}
+
+	for offset, partNumber := int64(0), 1; offset < size; offset, partNumber = offset+S3_PART_SIZE, partNumber+1 {
+		partSize := size - offset
+		if partSize > S3_PART_SIZE {
+			partSize = S3_PART_SIZE
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {initResult.UploadId}}
+		response, err := this.do(http.MethodPut, key, query, io.NewSectionReader(file, offset, partSize), partSize, nil)
+		if err != nil {
+			return abort(err)
+		}
+		response.Body.Close()
+		complete.Parts = append(complete.Parts, completePart{PartNumber: partNumber, ETag: response.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return abort(err)
+	}
+	response, err = this.do(http.MethodPost, key, url.Values{"uploadId": {initResult.UploadId}}, bytes.NewReader(body), int64(len(body)), nil)
+	if err != nil {
+		return abort(err)
+	}
+	err = readS3Error(response)
+	if err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
+// send a signed request. status code >= 300 turns to error.
+func (this *S3Driver) do(method string, key string, query url.Values, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+
+	u := *this.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if this.config.PathStyle {
+		u.Path = basePath + "/" + this.config.Bucket
+		if key != "" {
+			u.Path = u.Path + "/" + key
+		}
+	} else {
+		u.Host = this.config.Bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	}
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	request, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && body != http.NoBody {
+		request.ContentLength = size
+	}
+	if size == 0 && body != nil {
+		request.Body = http.NoBody
+		request.ContentLength = 0
+	}
+	for name, values := range header {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	this.sign(request, time.Now().UTC())
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, notExistError(strings.ToLower(method), "/"+key)
+	}
+	if response.StatusCode >= 300 {
+		return nil, readS3Error(response)
+	}
+	return response, nil
+}
+
+// sign the request with aws signature version 4.
+func (this *S3Driver) sign(request *http.Request, now time.Time) {
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("x-amz-date", amzDate)
+	request.Header.Set("x-amz-content-sha256", s3UnsignedPayload)
+
+	//sign host and all x-amz-* headers.
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders = canonicalHeaders + name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + this.config.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signingKey := s3Hmac([]byte("AWS4"+this.config.SecretKey), date)
+	signingKey = s3Hmac(signingKey, this.config.Region)
+	signingKey = s3Hmac(signingKey, "s3")
+	signingKey = s3Hmac(signingKey, "aws4_request")
+	signature := hex.EncodeToString(s3Hmac(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", this.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func s3Hmac(key []byte, content string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(content))
+	return h.Sum(nil)
+}
+
+// uri encode as aws requires. only unreserved characters are kept.
+func s3Escape(s string, encodeSlash bool) string {
+	var buffer strings.Builder
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			buffer.WriteByte(b)
+		} else {
+			buffer.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return buffer.String()
+}
+
+// query string sorted by key, which is used both in url and signature.
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// turn a s3 error response into error. nil if the body is not an error.
+func readS3Error(response *http.Response) error {
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	s3Error := &struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{}
+	if xml.Unmarshal(content, s3Error) == nil {
+		return fmt.Errorf("s3 error %s: %s", s3Error.Code, s3Error.Message)
+	}
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("s3 error status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func pathBase(p string) string {
+	return path.Base(strings.TrimSuffix(p, "/"))
+}
+
+// readable object. content is fetched by range requests lazily.
+type s3File struct {
+	driver *S3Driver
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (this *s3File) Read(p []byte) (int, error) {
+	if this.offset >= this.size {
+		return 0, io.EOF
+	}
+	if this.body == nil {
+		body, err := this.driver.getObject(this.key, this.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		this.body = body
+	}
+
+	n, err := this.body.Read(p)
+	this.offset += int64(n)
+	if err == io.EOF && this.offset < this.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (this *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if off >= this.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > this.size {
+		end = this.size
+	}
+	if end == off {
+		return 0, nil
+	}
+
+	body, err := this.driver.getObject(this.key, off, end-1)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:end-off])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (this *s3File) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = this.offset + offset
+	case io.SeekEnd:
+		target = this.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if target < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if target != this.offset && this.body != nil {
+		this.body.Close()
+		this.body = nil
+	}
+	this.offset = target
+	return target, nil
+}
+
+func (this *s3File) Close() error {
+	if this.body != nil {
+		err := this.body.Close()
+		this.body = nil
+		return err
+	}
+	return nil
+}
+
+// writable object. content is buffered in a temp file and uploaded when closed.
+type s3Writer struct {
+	driver *S3Driver
+	key    string
+	file   *os.File
+}
+
+func (this *s3Writer) Write(p []byte) (int, error) {
+	return this.file.Write(p)
+}
+
+func (this *s3Writer) Close() error {
+	defer func() {
+		this.file.Close()
+		os.Remove(this.file.Name())
+	}()
+
+	size, err := this.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	_, err = this.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	if size > S3_PART_SIZE {
+		return this.driver.multipartUpload(this.key, this.file, size)
+	}
+	return this.driver.putObject(this.key, this.file, size)
+}
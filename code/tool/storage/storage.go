@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+const (
+	//local file system.
+	DRIVER_LOCAL = "local"
+	//s3 compatible object storage.
+	DRIVER_S3 = "s3"
+)
+
+// a file opened for reading. random access is required by range download and archive reading.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+/**
+ * storage driver of matters. path is a slash separated path relative to the storage root. eg. /space/root/a.txt
+ * errors of missing files satisfy os.IsNotExist.
+ */
+type Driver interface {
+	//name of the driver. eg. local, s3
+	Name() string
+	//open a file for reading.
+	Open(path string) (File, error)
+	//create or truncate a file. the content is visible after Close.
+	Create(path string) (io.WriteCloser, error)
+	//rename a file or a directory.
+	Rename(oldPath string, newPath string) error
+	//remove a file or an empty directory.
+	Remove(path string) error
+	//stat a file or a directory.
+	Stat(path string) (os.FileInfo, error)
+	//list the direct children of a directory.
+	List(path string) ([]os.FileInfo, error)
+	//create a directory along with any necessary parents.
+	MkdirAll(path string) error
+}
+
+// driver which keeps files on local disk. the real path can be used by tools like inotify.
+type LocalPather interface {
+	LocalPath(path string) string
+}
+
+// check whether a file or directory exists.
+func Exists(driver Driver, path string) bool {
+	_, err := driver.Stat(path)
+	return err == nil
+}
+
+// remove a directory if it's empty. true: removed, false: removed nothing.
+func RemoveEmptyDir(driver Driver, dirPath string) bool {
+	infos, err := driver.List(dirPath)
+	if err != nil || len(infos) > 0 {
+		return false
+	}
+	return driver.Remove(dirPath) == nil
+}
+
+// remove a file or a directory with all its children. the root itself is kept.
+func RemoveAll(driver Driver, path string) error {
+	info, err := driver.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if info.IsDir() {
+		children, err := driver.List(path)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			err = RemoveAll(driver, CleanPath(path+"/"+child.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		if CleanPath(path) == "/" {
+			return nil
+		}
+	}
+
+	//directory of object storage disappears with its last child.
+	err = driver.Remove(path)
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// write the whole reader into a file.
+func Write(driver Driver, path string, reader io.Reader) (int64, error) {
+	writer, err := driver.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(writer, reader)
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// uniform a path. always start with / and never end with /
+func CleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func notExistError(op string, path string) error {
+	return &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+}
+
+// simple os.FileInfo for drivers not backed by os.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (this *fileInfo) Name() string {
+	return this.name
+}
+
+func (this *fileInfo) Size() int64 {
+	return this.size
+}
+
+func (this *fileInfo) Mode() os.FileMode {
+	if this.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (this *fileInfo) ModTime() time.Time {
+	return this.modTime
+}
+
+func (this *fileInfo) IsDir() bool {
+	return this.dir
+}
+
+func (this *fileInfo) Sys() interface{} {
+	return nil
+}
@@ -90,6 +90,20 @@ func ExtractRequestOptionalInt(request *http.Request, key string, defaultValue i
 	}
 }
 
+// param is optional. when missing, use the default value.
+func ExtractRequestOptionalInt64(request *http.Request, key string, defaultValue int64) int64 {
+	str := request.FormValue(key)
+	if str == "" {
+		return defaultValue
+	} else {
+		num, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		return num
+	}
+}
+
 // param is required. when missing, panic error.
 func ExtractRequestOptionalString(request *http.Request, key string, defaultValue string) string {
 	str := request.FormValue(key)
@@ -125,3 +139,13 @@ func ExtractRequestOptionalBool(request *http.Request, key string, defaultValue
 		return str == "true"
 	}
 }
+
+// param is optional. when missing, return nil.
+func ExtractRequestOptionalTime(request *http.Request, key string) *time.Time {
+	str := request.FormValue(key)
+	if str == "" {
+		return nil
+	}
+	t := ConvertDateTimeStringToTime(str)
+	return &t
+}
@@ -0,0 +1,19 @@
+package watch
+
+import (
+	"errors"
+)
+
+var ErrNotSupported = errors.New("watch: not supported on this platform")
+
+/**
+ * a change under the watched directory.
+ */
+type Event struct {
+	//absolute path of the changed file or directory.
+	Path string
+	//whether the changed entry is a directory.
+	Dir bool
+	//some events are lost. everything should be checked again.
+	Overflow bool
+}
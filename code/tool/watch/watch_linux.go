@@ -0,0 +1,207 @@
+//go:build linux
+
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	//changes of the entries in a directory.
+	watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+	//room for a few hundred events.
+	bufferSize = 64 * 1024
+)
+
+/**
+ * watch a directory tree by inotify. inotify is not recursive, so every directory is watched on its own.
+ * Events is closed after Close or a read error.
+ */
+type Watcher struct {
+	Events chan Event
+
+	//Fd of os.File turns it back to blocking.
+	fd   int
+	file *os.File
+	//wd -> absolute path, absolute path -> wd
+	mutex   sync.Mutex
+	wdPaths map[int]string
+	pathWds map[string]int
+	done    chan struct{}
+}
+
+// watch root and all the directories in it.
+func New(root string) (*Watcher, error) {
+
+	//non blocking fd is polled by the runtime, so Close can interrupt Read.
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	this := &Watcher{
+		Events:  make(chan Event, 256),
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		wdPaths: make(map[int]string),
+		pathWds: make(map[string]int),
+		done:    make(chan struct{}),
+	}
+
+	if err := this.addTree(filepath.Clean(root)); err != nil {
+		this.file.Close()
+		return nil, err
+	}
+
+	go this.readEvents()
+
+	return this, nil
+}
+
+// stop watching. safe to call more than once.
+func (this *Watcher) Close() error {
+	select {
+	case <-this.done:
+		return nil
+	default:
+		close(this.done)
+	}
+	return this.file.Close()
+}
+
+// watch a directory and its subdirectories. a directory may be removed while walking.
+func (this *Watcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if path != root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if err := this.add(path); err != nil && path == root {
+			return err
+		}
+		return nil
+	})
+}
+
+func (this *Watcher) add(path string) error {
+	wd, err := unix.InotifyAddWatch(this.fd, path, watchMask)
+	if err != nil {
+		return err
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.wdPaths[wd] = path
+	this.pathWds[path] = wd
+	return nil
+}
+
+// stop watching a directory moved away along with its subdirectories.
+func (this *Watcher) removeTree(root string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for path, wd := range this.pathWds {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			_, _ = unix.InotifyRmWatch(this.fd, uint32(wd))
+			delete(this.pathWds, path)
+			delete(this.wdPaths, wd)
+		}
+	}
+}
+
+// the watch is gone. eg. the directory is removed.
+func (this *Watcher) forget(wd int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if path, ok := this.wdPaths[wd]; ok {
+		delete(this.wdPaths, wd)
+		if this.pathWds[path] == wd {
+			delete(this.pathWds, path)
+		}
+	}
+}
+
+func (this *Watcher) pathOf(wd int) (string, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	path, ok := this.wdPaths[wd]
+	return path, ok
+}
+
+func (this *Watcher) readEvents() {
+
+	defer close(this.Events)
+
+	buffer := make([]byte, bufferSize)
+	for {
+		n, err := this.file.Read(buffer)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			//nothing can be trusted any more.
+			this.send(Event{Overflow: true})
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameBytes := buffer[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+
+			event, ok := this.parse(int(raw.Wd), raw.Mask, string(bytes.TrimRight(nameBytes, "\x00")))
+			if ok && !this.send(event) {
+				return
+			}
+		}
+	}
+}
+
+// translate a raw event. false if it should not be sent.
+func (this *Watcher) parse(wd int, mask uint32, name string) (Event, bool) {
+
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return Event{Overflow: true}, true
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		this.forget(wd)
+		return Event{}, false
+	}
+
+	dirPath, ok := this.pathOf(wd)
+	if !ok || name == "" {
+		return Event{}, false
+	}
+
+	event := Event{Path: dirPath + "/" + name, Dir: mask&unix.IN_ISDIR != 0}
+	if event.Dir {
+		if mask&unix.IN_MOVED_FROM != 0 {
+			this.removeTree(event.Path)
+		} else if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			//the new directory may already have children.
+			_ = this.addTree(event.Path)
+		}
+	}
+	return event, true
+}
+
+func (this *Watcher) send(event Event) bool {
+	select {
+	case this.Events <- event:
+		return true
+	case <-this.done:
+		return false
+	}
+}
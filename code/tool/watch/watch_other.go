@@ -0,0 +1,16 @@
+//go:build !linux
+
+package watch
+
+// inotify is only available on linux.
+type Watcher struct {
+	Events chan Event
+}
+
+func New(root string) (*Watcher, error) {
+	return nil, ErrNotSupported
+}
+
+func (this *Watcher) Close() error {
+	return nil
+}